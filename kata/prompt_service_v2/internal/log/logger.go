@@ -7,6 +7,14 @@ import (
 	"go.uber.org/zap/zapcore"
 )
 
+// Level is the minimum level of both outputs; SetLevel changes it while running.
+var Level = zap.NewAtomicLevelAt(zap.InfoLevel)
+
+// SetLevel parses a level such as "debug" or "warn" and applies it.
+func SetLevel(level string) error {
+	return Level.UnmarshalText([]byte(level))
+}
+
 func InitLogger() (*zap.Logger, error) {
 	// Define encoder configuration for human-readable + machine-friendly output
 	encoderCfg := zap.NewProductionEncoderConfig()
@@ -24,8 +32,8 @@ func InitLogger() (*zap.Logger, error) {
 	}
 
 	core := zapcore.NewTee(
-		zapcore.NewCore(consoleEncoder, zapcore.AddSync(os.Stdout), zap.InfoLevel),
-		zapcore.NewCore(fileEncoder, zapcore.AddSync(logFile), zap.InfoLevel),
+		zapcore.NewCore(consoleEncoder, zapcore.AddSync(os.Stdout), Level),
+		zapcore.NewCore(fileEncoder, zapcore.AddSync(logFile), Level),
 	)
 
 	logger := zap.New(core, zap.AddCaller(), zap.AddStacktrace(zap.WarnLevel))
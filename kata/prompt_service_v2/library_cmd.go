@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/walterfan/prompt-service/pkg/audit"
+	"github.com/walterfan/prompt-service/pkg/library"
+)
+
+func newExportCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export the prompt library as yaml, json or csv",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			formatName, _ := cmd.Flags().GetString("format")
+			output, _ := cmd.Flags().GetString("output")
+			tags, _ := cmd.Flags().GetStringSlice("tag")
+
+			if formatName == "" {
+				formatName = library.FormatYAML
+				if output != "" {
+					formatName = library.FormatOf(output)
+				}
+			}
+			format, err := library.ParseFormat(formatName)
+			if err != nil {
+				return err
+			}
+
+			if err := openDatabase(); err != nil {
+				return err
+			}
+			entries, err := library.Export(cmd.Context(), nil, tags)
+			if err != nil {
+				return err
+			}
+
+			var w io.Writer = os.Stdout
+			if output != "" {
+				f, err := os.Create(output)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				w = f
+			}
+
+			if err := library.Encode(w, format, entries); err != nil {
+				return err
+			}
+			// stdout may carry the library itself, so report on stderr
+			fmt.Fprintf(os.Stderr, "Exported %d prompts as %s\n", len(entries), format)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringP("format", "f", "", "Output format: yaml, json or csv (default from the output file name, else yaml)")
+	cmd.Flags().StringP("output", "o", "", "Output file (default stdout)")
+	cmd.Flags().StringSlice("tag", nil, "Only export prompts carrying all of these tags")
+	return cmd
+}
+
+func newImportCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "import [file]",
+		Short: "Import prompts from a yaml, json or csv library, upserting by name",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			formatName, _ := cmd.Flags().GetString("format")
+			dryRun, _ := cmd.Flags().GetBool("dry-run")
+			author, _ := cmd.Flags().GetString("author")
+
+			if formatName == "" {
+				formatName = library.FormatOf(args[0])
+			}
+			format, err := library.ParseFormat(formatName)
+			if err != nil {
+				return err
+			}
+
+			f, err := os.Open(args[0])
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+
+			entries, err := library.Decode(f, format)
+			if err != nil {
+				return err
+			}
+
+			if err := openDatabase(); err != nil {
+				return err
+			}
+			ctx := audit.WithActor(cmd.Context(), author)
+			report, err := library.Import(ctx, entries, nil, author, dryRun)
+			if report != nil {
+				printReport(cmd.OutOrStdout(), report)
+			}
+			return err
+		},
+	}
+
+	cmd.Flags().StringP("format", "f", "", "Input format: yaml, json or csv (default from the file name)")
+	cmd.Flags().Bool("dry-run", false, "Only show what would be created, updated or skipped")
+	cmd.Flags().String("author", "cli", "Author recorded on the new prompt versions and in the audit log")
+	return cmd
+}
+
+func printReport(w io.Writer, report *library.Report) {
+	if report.DryRun {
+		fmt.Fprintln(w, "Dry run, nothing was written.")
+	}
+	for _, group := range []struct {
+		label string
+		names []string
+	}{
+		{"create", report.Created},
+		{"update", report.Updated},
+		{"skip", report.Skipped},
+	} {
+		for _, name := range group.names {
+			fmt.Fprintf(w, "%-7s %s\n", group.label, name)
+		}
+	}
+	for _, e := range report.Errors {
+		fmt.Fprintf(w, "%-7s %s: %s\n", "error", e.Name, e.Error)
+	}
+	fmt.Fprintf(w, "%d created, %d updated, %d skipped\n", len(report.Created), len(report.Updated), len(report.Skipped))
+}
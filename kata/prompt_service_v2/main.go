@@ -1,20 +1,29 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"os/signal"
+	"syscall"
 
-	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"github.com/walterfan/prompt-service/internal/log"
 	"github.com/walterfan/prompt-service/pkg/auth"
 	"github.com/walterfan/prompt-service/pkg/authz"
+	"github.com/walterfan/prompt-service/pkg/cache"
 	"github.com/walterfan/prompt-service/pkg/config"
 	"github.com/walterfan/prompt-service/pkg/database"
 	"github.com/walterfan/prompt-service/pkg/handlers"
+	"github.com/walterfan/prompt-service/pkg/llm"
 	"github.com/walterfan/prompt-service/pkg/metrics"
+	"github.com/walterfan/prompt-service/pkg/ratelimit"
+	"github.com/walterfan/prompt-service/pkg/server"
+	"github.com/walterfan/prompt-service/pkg/store"
+	"github.com/walterfan/prompt-service/pkg/tracing"
 	"go.uber.org/zap"
 )
 
@@ -28,6 +37,8 @@ func init() {
 	if err != nil {
 		panic(err)
 	}
+	// packages log through zap.L()
+	zap.ReplaceGlobals(logger)
 
 	if err := godotenv.Load(); err != nil {
 		zap.L().Warn("No .env file found, using environment variables")
@@ -41,64 +52,120 @@ func init() {
 	}
 }
 
-func startServer(port string) {
+func startServer(port, policyFile string) {
 	cfg, err := config.LoadConfig()
 	if err != nil {
 		logger.Fatal("Failed to load config", zap.Error(err))
 	}
+	if err := log.SetLevel(cfg.LogLevel); err != nil {
+		logger.Fatal("Invalid log level", zap.Error(err))
+	}
 
-	database.InitDB(cfg.DatabasePath)
+	shutdownTracing, err := tracing.Init(cfg.TracingExporter, cfg.ServiceName, cfg.TracingSampleRatio)
+	if err != nil {
+		logger.Fatal("Failed to set up tracing", zap.Error(err))
+	}
+	defer shutdownTracing(context.Background())
+
+	database.InitDB(cfg.DatabaseDriver, cfg.DatabaseDSN)
+	if database.DB.Dialector.Name() == "sqlite" && !database.FTSEnabled {
+		if cfg.SearchRequireFTS {
+			logger.Fatal("Full-text search is unavailable, build with -tags sqlite_fts5")
+		}
+		logger.Warn("Full-text search is unavailable, searching with LIKE; build with -tags sqlite_fts5 to enable it")
+	}
 	auth.InitJwt(cfg.JwtSecret)
+	auth.InitTokens(cfg.AccessTokenTTL, cfg.RefreshTokenTTL)
+	auth.InitAccounts(cfg.AccountTTL, cfg.PasswordResetTTL, cfg.PasswordMinLength, cfg.RegistrationEnabled)
+	if cfg.SMTPHost != "" {
+		auth.ResetNotifier = &auth.SMTPNotifier{
+			Host: cfg.SMTPHost, Port: cfg.SMTPPort, Username: cfg.SMTPUsername, Password: cfg.SMTPPassword,
+			From: cfg.SMTPFrom, ResetURL: cfg.ResetURL,
+		}
+	} else {
+		logger.Warn("SMTP_HOST is not set, password reset is disabled")
+	}
+	if cfg.RedisEnabled {
+		if err := store.InitRedis(cfg.RedisHost, int(cfg.RedisPort), cfg.RedisPassword); err != nil {
+			logger.Fatal("Failed to connect to redis", zap.Error(err))
+		}
+	}
+	auth.InitRevocations(store.Redis)
+	ratelimit.Init(store.Redis, ratelimit.LockoutPolicy{
+		MaxFailures:        cfg.LoginMaxFailures,
+		AccountMaxFailures: cfg.LoginAccountMaxFailures,
+		Window:             cfg.LoginFailureWindow,
+		Backoff:            cfg.LoginLockout,
+		MaxBackoff:         cfg.LoginLockoutMax,
+	})
+	cache.Init(store.Redis, cfg.PromptCacheSize, cfg.PromptCacheTTL)
+	limits, err := ratelimit.ParseLimits(cfg.RateLimitIP, cfg.RateLimitUser, cfg.RateLimitRoutes)
+	if err != nil {
+		logger.Fatal("Invalid rate limits", zap.Error(err))
+	}
 	authz.InitAuthz(cfg.AuthzModelPath)
+	if policyFile != "" {
+		added, err := authz.ImportPoliciesFromCSV(policyFile)
+		if err != nil {
+			logger.Fatal("Failed to import policies", zap.Error(err))
+		}
+		logger.Info("Imported policies", zap.String("file", policyFile), zap.Int("added", added))
+	}
+	if err := authz.InitWatcher(store.Redis); err != nil {
+		logger.Fatal("Failed to watch policy changes", zap.Error(err))
+	}
+	llm.InitProvider(cfg.LlmBaseUrl, cfg.LlmApiKey, cfg.LlmModel)
 
 	metrics.Register()
 
-	r := gin.Default()
-	r.Use(metrics.MetricsMiddleware())
-
-	r.GET("/metrics", gin.WrapH(promhttp.Handler()))
-	r.POST("/login", auth.LoginHandler)
-
-	api := r.Group("/api/v1/prompts")
-	api.Use(auth.JwtMiddleware())
-	api.Use(authz.CasbinMiddleware())
-	{
-		api.POST("/", handlers.CreatePrompt)
-		api.GET("/:id", handlers.GetPrompt)
-		api.PUT("/:id", handlers.UpdatePrompt)
-		api.DELETE("/:id", handlers.DeletePrompt)
-		api.GET("/", handlers.SearchPrompts)
+	r := server.NewRouter(limits)
+	watchConfig()
+
+	// SIGTERM stops accepting connections and lets in-flight requests finish
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	srv := &http.Server{Addr: ":" + port, Handler: r}
+	go func() {
+		logger.Info("Starting server", zap.String("port", port))
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Fatal("Failed to start server", zap.Error(err))
+		}
+	}()
+
+	<-ctx.Done()
+	stop()
+	logger.Info("Shutting down", zap.Duration("timeout", cfg.ShutdownTimeout))
+	handlers.StartDraining()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		logger.Error("Requests were still running at the shutdown timeout", zap.Error(err))
 	}
-
-	// Assuming you've already imported handlers and authz
-
-	userApi := r.Group("/api/v1/users")
-	userApi.Use(auth.JwtMiddleware())
-	userApi.Use(authz.CasbinMiddleware())
-	{
-		userApi.POST("/", handlers.CreateUser)
-		userApi.GET("/:id", handlers.GetUser)
-		userApi.PUT("/:id", handlers.UpdateUser)
-		userApi.DELETE("/:id", handlers.DeleteUser)
-		userApi.GET("/", handlers.SearchUsers)
+	if sqlDB, err := database.DB.DB(); err == nil {
+		sqlDB.Close()
 	}
-
-	logger.Info("Starting server", zap.String("port", port))
-	if err := r.Run(":" + port); err != nil {
-		logger.Fatal("Failed to start server", zap.Error(err))
+	if store.Redis != nil {
+		store.Redis.Close()
 	}
+	logger.Info("Server stopped")
 }
+
 func main() {
 	cmd := &cobra.Command{
 		Use:   "prompt-service",
 		Short: "Prompt Service",
 		Run: func(cmd *cobra.Command, args []string) {
 			port, _ := cmd.Flags().GetString("port")
-			startServer(port)
+			policyFile, _ := cmd.Flags().GetString("import-policies")
+			startServer(port, policyFile)
 		},
 	}
 
 	cmd.Flags().StringP("port", "p", "8080", "Port to listen on")
+	cmd.Flags().String("import-policies", "", "Add the policies of a casbin CSV file (format as in pkg/authz/policy.csv) on start")
+	cmd.AddCommand(newExportCmd(), newImportCmd(), newMigrateCmd())
 
 	if err := cmd.Execute(); err != nil {
 		logger.Fatal("Command execution failed", zap.Error(err))
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/walterfan/prompt-service/pkg/config"
+	"github.com/walterfan/prompt-service/pkg/database"
+)
+
+// openDatabase connects the CLI commands to the database given by DATABASE_URL or
+// DATABASE_PATH, applying pending migrations.
+func openDatabase() error {
+	driver, dsn, err := config.Database()
+	if err != nil {
+		return err
+	}
+	database.InitDB(driver, dsn)
+	return nil
+}
+
+func newMigrateCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "migrate",
+		Short: "Apply pending database migrations and list the applied ones",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := openDatabase(); err != nil {
+				return err
+			}
+			applied, err := database.AppliedMigrations(database.DB)
+			if err != nil {
+				return err
+			}
+			for _, m := range applied {
+				fmt.Fprintf(cmd.OutOrStdout(), "%3d  %-30s %s\n", m.Version, m.Name, m.AppliedAt.Format("2006-01-02 15:04:05"))
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,103 @@
+// pkg/access/access.go
+package access
+
+import (
+	"context"
+
+	"github.com/walterfan/prompt-service/pkg/authz"
+	"github.com/walterfan/prompt-service/pkg/database"
+	"github.com/walterfan/prompt-service/pkg/models"
+	"gorm.io/gorm"
+)
+
+// Level is what a subject may do with a prompt; each level includes the ones below it.
+type Level int
+
+const (
+	None Level = iota
+	View
+	Edit
+	Own // delete, change visibility, share and transfer
+)
+
+// Subject is the caller that prompt access is decided for.
+type Subject struct {
+	Username string
+	Team     string
+	Admin    bool
+
+	db *gorm.DB // bound to the request the subject was looked up for
+}
+
+// SubjectOf looks up the team and admin role of username.
+func SubjectOf(ctx context.Context, username string) *Subject {
+	s := &Subject{Username: username, Admin: authz.HasRole(username, authz.AdminRole), db: database.Ctx(ctx)}
+	var user models.User
+	if err := s.db.Where("username = ?", username).Limit(1).Find(&user).Error; err == nil {
+		s.Team = user.Team
+	}
+	return s
+}
+
+// PromptLevel decides the access of s to p. Admins own every prompt; prompts
+// without an owner, created before ownership existed, are managed by admins only.
+func (s *Subject) PromptLevel(p *models.Prompt) Level {
+	if s.Admin || (p.Owner != "" && p.Owner == s.Username) {
+		return Own
+	}
+
+	level := None
+	switch p.Visibility {
+	case models.VisibilityPublic, "":
+		level = View
+	case models.VisibilityTeam:
+		if s.Team != "" && s.Team == s.ownerTeam(p.Owner) {
+			level = View
+		}
+	}
+
+	var shares []models.PromptShare
+	s.db.Where("prompt_id = ?", p.ID).Where(s.grantees()).Find(&shares)
+	for _, share := range shares {
+		if share.Role == models.ShareEditor {
+			return Edit
+		}
+		level = View
+	}
+	return level
+}
+
+// Visible restricts a prompts query to the prompts s may view.
+func (s *Subject) Visible(query *gorm.DB) *gorm.DB {
+	if s.Admin {
+		return query
+	}
+
+	shared := database.DB.Model(&models.PromptShare{}).Select("prompt_id").Where(s.grantees())
+	cond := database.DB.Where("prompts.visibility = ? OR prompts.visibility = ''", models.VisibilityPublic).
+		Or("prompts.owner = ?", s.Username).
+		Or("prompts.id IN (?)", shared)
+	if s.Team != "" {
+		team := database.DB.Model(&models.User{}).Select("username").Where("team = ?", s.Team)
+		cond = cond.Or("prompts.visibility = ? AND prompts.owner IN (?)", models.VisibilityTeam, team)
+	}
+	return query.Where(cond)
+}
+
+// grantees matches the shares given to s directly or through its team.
+func (s *Subject) grantees() *gorm.DB {
+	cond := database.DB.Where("grantee_type = ? AND grantee = ?", models.GranteeUser, s.Username)
+	if s.Team != "" {
+		cond = cond.Or("grantee_type = ? AND grantee = ?", models.GranteeTeam, s.Team)
+	}
+	return cond
+}
+
+func (s *Subject) ownerTeam(owner string) string {
+	if owner == "" {
+		return ""
+	}
+	var user models.User
+	s.db.Where("username = ?", owner).Limit(1).Find(&user)
+	return user.Team
+}
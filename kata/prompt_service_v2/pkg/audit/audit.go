@@ -0,0 +1,209 @@
+// pkg/audit/audit.go
+package audit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/walterfan/prompt-service/pkg/database"
+	"github.com/walterfan/prompt-service/pkg/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	ActionCreate = "create"
+	ActionUpdate = "update"
+	ActionDelete = "delete"
+
+	EntityPrompt      = "prompt"
+	EntityPromptShare = "prompt_share"
+	EntityTestCase    = "prompt_test_case"
+	EntityTag         = "tag"
+	EntityUser        = "user"
+	EntityAPIKey      = "api_key"
+	EntityPolicy      = "policy"
+	EntityRoleGrant   = "role_grant"
+)
+
+// redacted fields never reach the audit log, whatever the entity serializes.
+var redacted = []string{"password"}
+
+// appendAttempts bounds how often Append links again after losing a race.
+const appendAttempts = 5
+
+// actorKey holds the actor of mutations made outside a request, see WithActor.
+type actorKey struct{}
+
+// WithActor returns a context whose audit entries name actor, for mutations made
+// outside an HTTP request such as CLI commands.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// Record appends an audit entry within tx, the transaction of the mutation it
+// records, so that neither is committed without the other; the caller fails the
+// mutation when it returns an error. before and after are the entity's state, nil
+// for creates and deletes respectively. The actor and request ID come from the
+// context of tx, a request's gin.Context or one made by WithActor.
+func Record(tx *gorm.DB, action, entityType, entityID string, before, after interface{}) error {
+	ctx := tx.Statement.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	requestID, _ := ctx.Value(RequestIDKey).(string)
+	entry := models.AuditEntry{
+		Actor:      actor(ctx),
+		Action:     action,
+		EntityType: entityType,
+		EntityID:   entityID,
+		Before:     snapshot(before),
+		After:      snapshot(after),
+		RequestID:  requestID,
+	}
+	if err := Append(tx, &entry); err != nil {
+		return fmt.Errorf("write audit entry: %w", err)
+	}
+	return nil
+}
+
+// Append links entry to the end of the chain and stores it within tx. Concurrent
+// appends, from this process or other replicas, are serialized by the unique index
+// on prev_hash: the loser of a race is rejected and links again.
+func Append(tx *gorm.DB, entry *models.AuditEntry) error {
+	for attempt := 1; ; attempt++ {
+		// a nested transaction is a savepoint, so a lost race only undoes this attempt
+		err := tx.Transaction(func(tx *gorm.DB) error {
+			return link(tx, entry)
+		})
+		if err == nil || attempt == appendAttempts || !linked(tx, entry.PrevHash) {
+			return err
+		}
+	}
+}
+
+// linked reports whether another entry already follows prevHash, i.e. whether a
+// failed append lost a race rather than failed for another reason.
+func linked(tx *gorm.DB, prevHash string) bool {
+	var next []models.AuditEntry
+	err := tx.Clauses(clause.Locking{Strength: "SHARE"}).Where("prev_hash = ?", prevHash).Limit(1).Find(&next).Error
+	return err == nil && len(next) > 0
+}
+
+func link(tx *gorm.DB, entry *models.AuditEntry) error {
+	entry.ID = 0
+	// a locking read sees the latest committed entry even under repeatable read
+	var last []models.AuditEntry
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Order("id desc").Limit(1).Find(&last).Error; err != nil {
+		return err
+	}
+	entry.PrevHash = ""
+	if len(last) > 0 {
+		entry.PrevHash = last[0].Hash
+	}
+	// databases keep at most microseconds; hash what will be read back
+	entry.CreatedAt = time.Now().UTC().Truncate(time.Microsecond)
+	entry.Hash = Hash(entry)
+	return tx.Create(entry).Error
+}
+
+// Hash computes the chain hash of entry from its fields and PrevHash.
+func Hash(entry *models.AuditEntry) string {
+	data, _ := json.Marshal([]interface{}{
+		entry.PrevHash,
+		entry.CreatedAt.UTC().Format(time.RFC3339Nano),
+		entry.Actor,
+		entry.Action,
+		entry.EntityType,
+		entry.EntityID,
+		string(entry.Before),
+		string(entry.After),
+		entry.RequestID,
+	})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// VerifyResult tells whether the chain is intact and, if not, where it breaks.
+type VerifyResult struct {
+	Valid    bool   `json:"valid"`
+	Entries  int    `json:"entries"`
+	BrokenAt uint   `json:"brokenAt,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// Verify recomputes the chain from the first entry, detecting edited, removed or
+// reordered entries.
+func Verify(ctx context.Context) (*VerifyResult, error) {
+	result := &VerifyResult{Valid: true}
+	prev := ""
+	var batch []models.AuditEntry
+	err := database.Ctx(ctx).Order("id").FindInBatches(&batch, 500, func(tx *gorm.DB, _ int) error {
+		for i := range batch {
+			e := &batch[i]
+			switch {
+			case e.PrevHash != prev:
+				result.Reason = "previous hash doesn't match"
+			case Hash(e) != e.Hash:
+				result.Reason = "hash doesn't match content"
+			default:
+				prev = e.Hash
+				result.Entries++
+				continue
+			}
+			result.Valid, result.BrokenAt = false, e.ID
+			return errStop
+		}
+		return nil
+	}).Error
+	if err != nil && err != errStop {
+		return nil, err
+	}
+	return result, nil
+}
+
+var errStop = errors.New("stop")
+
+// actor names who made a mutation: the actor set by WithActor, or the user the
+// auth middleware stored in the gin.Context of the request.
+func actor(ctx context.Context) string {
+	if name, ok := ctx.Value(actorKey{}).(string); ok {
+		return name
+	}
+	if name, ok := ctx.Value("user").(string); ok {
+		return name
+	}
+	return ""
+}
+
+// snapshot serializes an entity state without its redacted fields.
+func snapshot(v interface{}) json.RawMessage {
+	if v == nil {
+		return nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+
+	var fields map[string]interface{}
+	if json.Unmarshal(data, &fields) != nil {
+		return data
+	}
+	changed := false
+	for _, name := range redacted {
+		if _, ok := fields[name]; ok {
+			delete(fields, name)
+			changed = true
+		}
+	}
+	if changed {
+		data, _ = json.Marshal(fields)
+	}
+	return data
+}
@@ -0,0 +1,35 @@
+// pkg/audit/request_id.go
+package audit
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/gin-gonic/gin"
+	"github.com/walterfan/prompt-service/internal/log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	RequestIDHeader = "X-Request-ID"
+	RequestIDKey    = "requestId"
+)
+
+// RequestIDMiddleware keeps the caller's X-Request-ID or assigns one, exposing it in the
+// context for audit entries and log lines, on the request's span and in the response header.
+func RequestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if id == "" || len(id) > 128 {
+			b := make([]byte, 16)
+			_, _ = rand.Read(b)
+			id = hex.EncodeToString(b)
+		}
+		c.Set(RequestIDKey, id)
+		c.Request = c.Request.WithContext(log.WithRequestID(c.Request.Context(), id))
+		trace.SpanFromContext(c.Request.Context()).SetAttributes(attribute.String("request.id", id))
+		c.Header(RequestIDHeader, id)
+		c.Next()
+	}
+}
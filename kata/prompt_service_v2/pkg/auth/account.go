@@ -0,0 +1,305 @@
+// pkg/auth/account.go
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/walterfan/prompt-service/internal/log"
+	"github.com/walterfan/prompt-service/pkg/audit"
+	"github.com/walterfan/prompt-service/pkg/authz"
+	"github.com/walterfan/prompt-service/pkg/database"
+	"github.com/walterfan/prompt-service/pkg/models"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+var errInvalidResetToken = errors.New("invalid reset token")
+
+// usernamePattern allows 3 to 32 letters, digits, ".", "_", "-" and "@", starting
+// with a letter or digit.
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._@-]{2,31}$`)
+
+// ValidateUsername checks a trimmed username against usernamePattern.
+func ValidateUsername(username string) error {
+	if username == "" {
+		return errors.New("username is required")
+	}
+	if !usernamePattern.MatchString(username) {
+		return errors.New(`username must be 3 to 32 letters, digits, ".", "_", "-" or "@", starting with a letter or digit`)
+	}
+	return nil
+}
+
+var (
+	// AccountTTL is how long a self-registered account stays valid.
+	AccountTTL          = 365 * 24 * time.Hour
+	PasswordResetTTL    = time.Hour
+	RegistrationEnabled = true
+)
+
+func InitAccounts(accountTTL, resetTTL time.Duration, minPasswordLength int, registration bool) {
+	AccountTTL = accountTTL
+	PasswordResetTTL = resetTTL
+	MinPasswordLength = minPasswordLength
+	RegistrationEnabled = registration
+}
+
+// WritePasswordError answers 422 for password policy violations and 500 otherwise.
+func WritePasswordError(c *gin.Context, err error) {
+	var perr *PasswordError
+	if errors.As(err, &perr) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": perr.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
+
+// revokeSessions revokes every refresh token of the user, logging out other devices.
+func revokeSessions(tx *gorm.DB, userID uint) error {
+	return tx.Model(&models.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// RegisterHandler creates an account with the user role: POST /register.
+func RegisterHandler(c *gin.Context) {
+	if !RegistrationEnabled {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Registration is disabled"})
+		return
+	}
+
+	var req struct {
+		Username string `json:"username" binding:"required"`
+		Email    string `json:"email" binding:"required,email"`
+		Password string `json:"password" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.Username = strings.TrimSpace(req.Username)
+	if err := ValidateUsername(req.Username); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	hash, err := HashPassword(req.Password, req.Username)
+	if err != nil {
+		WritePasswordError(c, err)
+		return
+	}
+
+	var count int64
+	database.Ctx(c).Model(&models.User{}).Where("username = ? OR email = ?", req.Username, req.Email).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Username or email already registered"})
+		return
+	}
+
+	user := models.User{
+		Username:  req.Username,
+		Password:  hash,
+		Email:     req.Email,
+		Role:      "user",
+		ExpiredAt: time.Now().Add(AccountTTL),
+	}
+	// the new user is the actor of their own registration
+	c.Set("user", user.Username)
+	err = database.Ctx(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.ActionCreate, audit.EntityUser, fmt.Sprint(user.ID), nil, user)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := authz.SetUserRole(user.Username, user.Role); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, user)
+}
+
+// ChangePasswordHandler changes the caller's password and logs out their other
+// sessions: POST /password {"currentPassword": "...", "newPassword": "..."}.
+func ChangePasswordHandler(c *gin.Context) {
+	var req struct {
+		CurrentPassword string `json:"currentPassword" binding:"required"`
+		NewPassword     string `json:"newPassword" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var user models.User
+	if err := database.Ctx(c).Where("username = ?", c.GetString("user")).First(&user).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+	if !CheckPassword(user.Password, req.CurrentPassword) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		return
+	}
+
+	hash, err := HashPassword(req.NewPassword, user.Username)
+	if err != nil {
+		WritePasswordError(c, err)
+		return
+	}
+	err = database.Ctx(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Update("password", hash).Error; err != nil {
+			return err
+		}
+		if err := revokeSessions(tx, user.ID); err != nil {
+			return err
+		}
+		return audit.Record(tx, "change_password", audit.EntityUser, fmt.Sprint(user.ID), nil, nil)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Password changed"})
+}
+
+// writeResetUnavailable answers the reset endpoints while no ResetNotifier is
+// configured: tokens couldn't reach their users.
+func writeResetUnavailable(c *gin.Context) {
+	c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Password reset is not configured"})
+}
+
+// RequestPasswordResetHandler sends a reset token through ResetNotifier:
+// POST /password/reset/request {"email": "..."} or {"username": "..."}.
+// It answers the same whether or not the account exists.
+func RequestPasswordResetHandler(c *gin.Context) {
+	if ResetNotifier == nil {
+		writeResetUnavailable(c)
+		return
+	}
+
+	var req struct {
+		Username string `json:"username"`
+		Email    string `json:"email"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || (req.Username == "" && req.Email == "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "username or email is required"})
+		return
+	}
+
+	accepted := gin.H{"message": "If the account exists, a reset token has been sent"}
+
+	var users []models.User
+	query := database.Ctx(c).Limit(1)
+	if req.Username != "" {
+		query = query.Where("username = ?", req.Username)
+	} else {
+		query = query.Where("email = ?", req.Email)
+	}
+	if err := query.Find(&users).Error; err != nil || len(users) == 0 {
+		c.JSON(http.StatusAccepted, accepted)
+		return
+	}
+	user := users[0]
+
+	token, err := randomToken(32)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	reset := models.PasswordResetToken{
+		UserID:    user.ID,
+		TokenHash: HashToken(token),
+		ExpiresAt: time.Now().Add(PasswordResetTTL),
+	}
+	err = database.Ctx(c).Transaction(func(tx *gorm.DB) error {
+		// a new request supersedes the tokens sent before
+		if err := tx.Model(&models.PasswordResetToken{}).
+			Where("user_id = ? AND used_at IS NULL", user.ID).
+			Update("used_at", time.Now()).Error; err != nil {
+			return err
+		}
+		return tx.Create(&reset).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := ResetNotifier.SendPasswordReset(&user, token, reset.ExpiresAt); err != nil {
+		log.Ctx(c).Error("Failed to send password reset", zap.String("username", user.Username), zap.Error(err))
+	}
+	c.JSON(http.StatusAccepted, accepted)
+}
+
+// ResetPasswordHandler sets a new password with a reset token and logs out every
+// session: POST /password/reset {"token": "...", "newPassword": "..."}.
+func ResetPasswordHandler(c *gin.Context) {
+	if ResetNotifier == nil {
+		writeResetUnavailable(c)
+		return
+	}
+
+	var req struct {
+		Token       string `json:"token" binding:"required"`
+		NewPassword string `json:"newPassword" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var reset models.PasswordResetToken
+	var user models.User
+	if database.Ctx(c).Where("token_hash = ?", HashToken(req.Token)).First(&reset).Error != nil ||
+		reset.UsedAt != nil || reset.ExpiresAt.Before(time.Now()) ||
+		database.Ctx(c).First(&user, reset.UserID).Error != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired reset token"})
+		return
+	}
+
+	hash, err := HashPassword(req.NewPassword, user.Username)
+	if err != nil {
+		WritePasswordError(c, err)
+		return
+	}
+
+	// the user resetting their password is the actor, as nobody is logged in
+	c.Set("user", user.Username)
+	err = database.Ctx(c).Transaction(func(tx *gorm.DB) error {
+		// the used_at guard makes the token single-use under concurrent requests
+		result := tx.Model(&models.PasswordResetToken{}).
+			Where("id = ? AND used_at IS NULL", reset.ID).
+			Update("used_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errInvalidResetToken
+		}
+		if err := tx.Model(&user).Update("password", hash).Error; err != nil {
+			return err
+		}
+		if err := revokeSessions(tx, user.ID); err != nil {
+			return err
+		}
+		return audit.Record(tx, "reset_password", audit.EntityUser, fmt.Sprint(user.ID), nil, nil)
+	})
+	if errors.Is(err, errInvalidResetToken) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired reset token"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Password reset"})
+}
@@ -0,0 +1,122 @@
+// pkg/auth/apikey.go
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/walterfan/prompt-service/pkg/database"
+	"github.com/walterfan/prompt-service/pkg/models"
+)
+
+// APIKeyPrefix marks keys issued by this service so they are easy to spot in logs and scanners.
+const APIKeyPrefix = "psk_"
+
+// NewAPIKey returns a new random key and the prefix shown in listings.
+func NewAPIKey() (key, prefix string, err error) {
+	secret, err := randomToken(32)
+	if err != nil {
+		return "", "", err
+	}
+	key = APIKeyPrefix + secret
+	return key, key[:len(APIKeyPrefix)+8], nil
+}
+
+// ValidateScopes checks scopes of the form "<resource>:read", "<resource>:write" or "*".
+func ValidateScopes(scopes []string) error {
+	for _, scope := range scopes {
+		if scope == "*" {
+			continue
+		}
+		resource, access, ok := strings.Cut(scope, ":")
+		if !ok || resource == "" || (access != "read" && access != "write") {
+			return fmt.Errorf("invalid scope %q, expected <resource>:read, <resource>:write or *", scope)
+		}
+	}
+	return nil
+}
+
+// requiredScope maps a request to the scope it needs, e.g. GET /api/v1/prompts/1 -> prompts:read.
+func requiredScope(r *http.Request) string {
+	resource := strings.TrimPrefix(r.URL.Path, "/api/v1/")
+	resource, _, _ = strings.Cut(resource, "/")
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return resource + ":read"
+	}
+	return resource + ":write"
+}
+
+func hasScope(granted string, required string) bool {
+	for _, scope := range strings.Split(granted, ",") {
+		scope = strings.TrimSpace(scope)
+		if scope == "*" || scope == required {
+			return true
+		}
+		// write implies read
+		if resource, access, _ := strings.Cut(required, ":"); access == "read" && scope == resource+":write" {
+			return true
+		}
+	}
+	return false
+}
+
+// apiKeyFromRequest reads the X-API-Key header or an "Authorization: ApiKey <key>" header.
+func apiKeyFromRequest(c *gin.Context) (string, bool) {
+	if key := c.GetHeader("X-API-Key"); key != "" {
+		return key, true
+	}
+	if scheme, key, ok := strings.Cut(c.GetHeader("Authorization"), " "); ok && strings.EqualFold(scheme, "ApiKey") {
+		return strings.TrimSpace(key), true
+	}
+	return "", false
+}
+
+// authenticateAPIKey verifies the key and sets the owner's username as "user", the same
+// context value a JWT subject sets, so CasbinMiddleware treats both alike.
+func authenticateAPIKey(c *gin.Context, key string) {
+	var apiKey models.APIKey
+	if err := database.Ctx(c).Where("key_hash = ?", HashToken(key)).First(&apiKey).Error; err != nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid API key"})
+		return
+	}
+
+	now := time.Now()
+	if apiKey.RevokedAt != nil || (apiKey.ExpiresAt != nil && apiKey.ExpiresAt.Before(now)) {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "API key revoked or expired"})
+		return
+	}
+
+	var user models.User
+	if err := database.Ctx(c).First(&user, apiKey.UserID).Error; err != nil || user.ExpiredAt.Before(now) {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Account expired"})
+		return
+	}
+
+	if !hasScope(apiKey.Scopes, requiredScope(c.Request)) {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "API key scope does not allow this request"})
+		return
+	}
+
+	database.Ctx(c).Model(&apiKey).UpdateColumn("last_used_at", now)
+
+	c.Set("user", user.Username)
+	c.Set("apiKeyId", apiKey.ID)
+	c.Next()
+}
+
+// AuthMiddleware accepts either an API key or a JWT bearer token.
+func AuthMiddleware() gin.HandlerFunc {
+	jwtMiddleware := JwtMiddleware()
+	return func(c *gin.Context) {
+		if key, ok := apiKeyFromRequest(c); ok {
+			authenticateAPIKey(c, key)
+			return
+		}
+		jwtMiddleware(c)
+	}
+}
@@ -2,10 +2,12 @@
 package auth
 
 import (
-	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt"
+	"fmt"
 	"net/http"
 	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt"
 )
 
 var JwtSecret []byte
@@ -30,6 +32,9 @@ func JwtMiddleware() gin.HandlerFunc {
 
 		tokenString := parts[1]
 		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+			}
 			return JwtSecret, nil
 		})
 
@@ -44,6 +49,27 @@ func JwtMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		// Tokens issued before revocation support carry no jti and could never be
+		// revoked, so they aren't accepted any more; their users log in again.
+		jti, ok := claims["jti"].(string)
+		if !ok || jti == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Token can't be revoked, log in again"})
+			return
+		}
+		revoked, err := Revocations.IsRevoked(c.Request.Context(), jti)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "Failed to check token revocation"})
+			return
+		}
+		if revoked {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Token has been revoked"})
+			return
+		}
+		c.Set("jti", jti)
+		if exp, ok := claims["exp"].(float64); ok {
+			c.Set("tokenExp", int64(exp))
+		}
+
 		// Optional: Set user info in context
 		if sub, ok := claims["sub"].(string); ok {
 			c.Set("user", sub)
@@ -5,10 +5,12 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt"
+	"github.com/walterfan/prompt-service/internal/log"
 	"github.com/walterfan/prompt-service/pkg/database"
+	"github.com/walterfan/prompt-service/pkg/metrics"
 	"github.com/walterfan/prompt-service/pkg/models"
-	"golang.org/x/crypto/bcrypt"
+	"github.com/walterfan/prompt-service/pkg/ratelimit"
+	"go.uber.org/zap"
 )
 
 func LoginHandler(c *gin.Context) {
@@ -22,18 +24,25 @@ func LoginHandler(c *gin.Context) {
 		return
 	}
 
-	var user models.User
-	result := database.DB.Where("username = ?", req.Username).First(&user)
-	if result.Error != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+	ctx := c.Request.Context()
+	if wait, err := ratelimit.Lockouts.Locked(ctx, req.Username, c.ClientIP()); err != nil {
+		log.Ctx(c).Error("Failed to check login lockout", zap.Error(err))
+	} else if wait > 0 {
+		metrics.RateLimitedRequests.WithLabelValues("lockout").Inc()
+		ratelimit.TooManyRequests(c, wait, "Too many failed logins, account temporarily locked")
 		return
 	}
 
-	// Compare password hash
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+	// Unknown users count as failures too, so lockouts don't reveal which accounts exist
+	var user models.User
+	result := database.Ctx(c).Where("username = ?", req.Username).First(&user)
+	if result.Error != nil || !CheckPassword(user.Password, req.Password) {
+		loginFailed(c, req.Username)
 		return
 	}
+	if err := ratelimit.Lockouts.Reset(ctx, req.Username, c.ClientIP()); err != nil {
+		log.Ctx(c).Error("Failed to reset login failures", zap.Error(err))
+	}
 
 	// Check if user account has expired
 	if user.ExpiredAt.Before(time.Now()) {
@@ -41,14 +50,27 @@ func LoginHandler(c *gin.Context) {
 		return
 	}
 
-	// Generate JWT token
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"sub":  user.Username,
-		"role": user.Role,
-		"exp":  time.Now().Add(time.Hour * 72).Unix(),
-	})
+	// Generate the access and refresh tokens
+	pair, err := IssueTokens(database.Ctx(c), &user, "")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue token"})
+		return
+	}
 
-	tokenString, _ := token.SignedString(JwtSecret)
+	c.JSON(http.StatusOK, pair)
+}
 
-	c.JSON(http.StatusOK, gin.H{"token": tokenString})
+// loginFailed records a failed login, locking the client out of the account after too
+// many of them, and the account for everyone after too many from all clients.
+func loginFailed(c *gin.Context, username string) {
+	locked, err := ratelimit.Lockouts.Fail(c.Request.Context(), username, c.ClientIP())
+	if err != nil {
+		log.Ctx(c).Error("Failed to record login failure", zap.Error(err))
+	}
+	if locked > 0 {
+		metrics.LoginLockouts.Inc()
+		log.Ctx(c).Warn("Account locked after failed logins",
+			zap.String("username", username), zap.String("ip", c.ClientIP()), zap.Duration("for", locked))
+	}
+	c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 }
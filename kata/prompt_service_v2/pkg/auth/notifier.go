@@ -0,0 +1,74 @@
+// pkg/auth/notifier.go
+package auth
+
+import (
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/walterfan/prompt-service/pkg/models"
+	"go.uber.org/zap"
+)
+
+// Notifier delivers password reset tokens to users, e.g. by email.
+type Notifier interface {
+	SendPasswordReset(user *models.User, token string, expiresAt time.Time) error
+}
+
+// ResetNotifier delivers the tokens created by RequestPasswordResetHandler. While it
+// is nil, password reset is unavailable and both reset endpoints answer 503.
+var ResetNotifier Notifier
+
+// SMTPNotifier emails reset tokens through an SMTP server, authenticating with
+// Username and Password when set.
+type SMTPNotifier struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+	// ResetURL is prepended to the token in the email, e.g.
+	// "https://prompts.example.com/reset?token="; without it the bare token is sent.
+	ResetURL string
+}
+
+func (n *SMTPNotifier) SendPasswordReset(user *models.User, token string, expiresAt time.Time) error {
+	if user.Email == "" {
+		return fmt.Errorf("user %s has no email address", user.Username)
+	}
+
+	var body strings.Builder
+	fmt.Fprintf(&body, "From: %s\r\nTo: %s\r\nSubject: Password reset\r\n", n.From, user.Email)
+	body.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	fmt.Fprintf(&body, "A password reset was requested for %s.\r\n\r\n", user.Username)
+	if n.ResetURL != "" {
+		fmt.Fprintf(&body, "Reset your password at %s%s\r\n", n.ResetURL, token)
+	} else {
+		fmt.Fprintf(&body, "Your reset token: %s\r\n", token)
+	}
+	fmt.Fprintf(&body, "\r\nIt expires at %s. Ignore this email if you didn't ask for it.\r\n",
+		expiresAt.UTC().Format(time.RFC1123))
+
+	var auth smtp.Auth
+	if n.Username != "" {
+		auth = smtp.PlainAuth("", n.Username, n.Password, n.Host)
+	}
+	addr := net.JoinHostPort(n.Host, strconv.Itoa(n.Port))
+	return smtp.SendMail(addr, auth, n.From, []string{user.Email}, []byte(body.String()))
+}
+
+// LogNotifier writes reset tokens to the log instead of sending them. It is meant
+// for tests only, since anyone reading the log can reset the password.
+type LogNotifier struct{}
+
+func (LogNotifier) SendPasswordReset(user *models.User, token string, expiresAt time.Time) error {
+	zap.L().Info("Password reset requested",
+		zap.String("username", user.Username),
+		zap.String("email", user.Email),
+		zap.String("token", token),
+		zap.Time("expiresAt", expiresAt))
+	return nil
+}
@@ -0,0 +1,67 @@
+// pkg/auth/password.go
+package auth
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// MinPasswordLength is the shortest password ValidatePassword accepts.
+var MinPasswordLength = 8
+
+// PasswordError explains why a password was rejected by the password policy.
+type PasswordError struct {
+	Reason string
+}
+
+func (e *PasswordError) Error() string {
+	return "password " + e.Reason
+}
+
+// ValidatePassword enforces the password policy: at least MinPasswordLength characters,
+// 72 bytes at most (bcrypt ignores the rest), a letter and a digit, and not the username.
+func ValidatePassword(password, username string) error {
+	if len([]rune(password)) < MinPasswordLength {
+		return &PasswordError{fmt.Sprintf("must be at least %d characters", MinPasswordLength)}
+	}
+	if len(password) > 72 {
+		return &PasswordError{"must be at most 72 bytes"}
+	}
+
+	var letter, digit bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLetter(r):
+			letter = true
+		case unicode.IsDigit(r):
+			digit = true
+		}
+	}
+	if !letter || !digit {
+		return &PasswordError{"must contain a letter and a digit"}
+	}
+	if username != "" && strings.EqualFold(password, username) {
+		return &PasswordError{"must not be the username"}
+	}
+	return nil
+}
+
+// HashPassword validates password against the policy and returns its bcrypt hash.
+func HashPassword(password, username string) (string, error) {
+	if err := ValidatePassword(password, username); err != nil {
+		return "", err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// CheckPassword reports whether password matches the stored bcrypt hash.
+func CheckPassword(hash, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
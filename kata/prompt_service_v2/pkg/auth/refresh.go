@@ -0,0 +1,114 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/walterfan/prompt-service/pkg/database"
+	"github.com/walterfan/prompt-service/pkg/models"
+	"gorm.io/gorm"
+)
+
+var errInvalidRefreshToken = errors.New("invalid refresh token")
+
+// RefreshHandler exchanges a refresh token for a new token pair. The presented token is
+// revoked; presenting an already revoked token revokes its whole family, since that
+// means it was stolen or replayed.
+func RefreshHandler(c *gin.Context) {
+	var req struct {
+		RefreshToken string `json:"refreshToken" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var token models.RefreshToken
+	if err := database.Ctx(c).Where("token_hash = ?", HashToken(req.RefreshToken)).First(&token).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired refresh token"})
+		return
+	}
+
+	if token.RevokedAt != nil {
+		if err := revokeFamily(database.Ctx(c), token.FamilyID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token reuse detected, please log in again"})
+		return
+	}
+
+	var user models.User
+	if token.ExpiresAt.Before(time.Now()) ||
+		database.Ctx(c).First(&user, token.UserID).Error != nil ||
+		user.ExpiredAt.Before(time.Now()) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired refresh token"})
+		return
+	}
+
+	var pair *TokenPair
+	err := database.Ctx(c).Transaction(func(tx *gorm.DB) error {
+		// the revoked_at guard makes concurrent refreshes of the same token fail
+		result := tx.Model(&models.RefreshToken{}).
+			Where("id = ? AND revoked_at IS NULL", token.ID).
+			Update("revoked_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errInvalidRefreshToken
+		}
+
+		var err error
+		pair, err = IssueTokens(tx, &user, token.FamilyID)
+		return err
+	})
+	if errors.Is(err, errInvalidRefreshToken) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired refresh token"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, pair)
+}
+
+// LogoutHandler revokes the access token used for the call and, when given, the
+// refresh token family it belongs to. It must run behind JwtMiddleware.
+func LogoutHandler(c *gin.Context) {
+	var req struct {
+		RefreshToken string `json:"refreshToken"`
+	}
+	// the body is optional
+	_ = c.ShouldBindJSON(&req)
+
+	if jti := c.GetString("jti"); jti != "" {
+		ttl := time.Until(time.Unix(c.GetInt64("tokenExp"), 0))
+		if ttl > 0 {
+			if err := Revocations.Revoke(c.Request.Context(), jti, ttl); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+		}
+	}
+
+	if req.RefreshToken != "" {
+		var token models.RefreshToken
+		err := database.Ctx(c).Where("token_hash = ?", HashToken(req.RefreshToken)).First(&token).Error
+		if err == nil {
+			var user models.User
+			// only the owner may revoke it
+			if database.Ctx(c).First(&user, token.UserID).Error == nil && user.Username == c.GetString("user") {
+				if err := revokeFamily(database.Ctx(c), token.FamilyID); err != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+					return
+				}
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out"})
+}
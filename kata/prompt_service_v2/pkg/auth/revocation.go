@@ -0,0 +1,76 @@
+// pkg/auth/revocation.go
+package auth
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RevocationStore remembers revoked access token ids (jti) until the tokens expire.
+type RevocationStore interface {
+	Revoke(ctx context.Context, jti string, ttl time.Duration) error
+	IsRevoked(ctx context.Context, jti string) (bool, error)
+}
+
+// Revocations is checked by JwtMiddleware; InitRevocations picks the implementation.
+var Revocations RevocationStore = NewMemoryRevocationStore()
+
+// InitRevocations uses Redis when a client is given, so every instance sees the same list.
+func InitRevocations(client *redis.Client) {
+	if client != nil {
+		Revocations = &redisRevocationStore{client: client}
+		return
+	}
+	Revocations = NewMemoryRevocationStore()
+}
+
+type memoryRevocationStore struct {
+	mu      sync.Mutex
+	revoked map[string]time.Time // jti -> expiry
+}
+
+func NewMemoryRevocationStore() RevocationStore {
+	return &memoryRevocationStore{revoked: map[string]time.Time{}}
+}
+
+func (m *memoryRevocationStore) Revoke(_ context.Context, jti string, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	for id, expiry := range m.revoked {
+		if now.After(expiry) {
+			delete(m.revoked, id)
+		}
+	}
+	m.revoked[jti] = now.Add(ttl)
+	return nil
+}
+
+func (m *memoryRevocationStore) IsRevoked(_ context.Context, jti string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	expiry, ok := m.revoked[jti]
+	return ok && time.Now().Before(expiry), nil
+}
+
+type redisRevocationStore struct {
+	client *redis.Client
+}
+
+func revokedKey(jti string) string {
+	return "prompt-service:revoked:" + jti
+}
+
+func (r *redisRevocationStore) Revoke(ctx context.Context, jti string, ttl time.Duration) error {
+	return r.client.Set(ctx, revokedKey(jti), 1, ttl).Err()
+}
+
+func (r *redisRevocationStore) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	n, err := r.client.Exists(ctx, revokedKey(jti)).Result()
+	return n > 0, err
+}
@@ -0,0 +1,108 @@
+// pkg/auth/tokens.go
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/walterfan/prompt-service/pkg/models"
+	"gorm.io/gorm"
+)
+
+var (
+	AccessTokenTTL  = 15 * time.Minute
+	RefreshTokenTTL = 7 * 24 * time.Hour
+)
+
+// TokenPair is returned by login and refresh. Token duplicates AccessToken for
+// clients written against the original login response.
+type TokenPair struct {
+	Token        string `json:"token"`
+	AccessToken  string `json:"accessToken"`
+	RefreshToken string `json:"refreshToken"`
+	ExpiresIn    int64  `json:"expiresIn"` // seconds until the access token expires
+}
+
+func InitTokens(accessTTL, refreshTTL time.Duration) {
+	AccessTokenTTL = accessTTL
+	RefreshTokenTTL = refreshTTL
+}
+
+// randomToken returns n random bytes, base64url encoded.
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// HashToken is how refresh tokens are stored: a leaked table can't be replayed.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func newAccessToken(user *models.User) (string, error) {
+	jti, err := randomToken(16)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub":  user.Username,
+		"role": user.Role,
+		"jti":  jti,
+		"iat":  now.Unix(),
+		"exp":  now.Add(AccessTokenTTL).Unix(),
+	})
+	return token.SignedString(JwtSecret)
+}
+
+// IssueTokens creates an access token and a refresh token in the given family.
+// An empty familyID starts a new family, as on login.
+func IssueTokens(tx *gorm.DB, user *models.User, familyID string) (*TokenPair, error) {
+	access, err := newAccessToken(user)
+	if err != nil {
+		return nil, err
+	}
+
+	refresh, err := randomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	if familyID == "" {
+		if familyID, err = randomToken(16); err != nil {
+			return nil, err
+		}
+	}
+
+	record := models.RefreshToken{
+		UserID:    user.ID,
+		TokenHash: HashToken(refresh),
+		FamilyID:  familyID,
+		ExpiresAt: time.Now().Add(RefreshTokenTTL),
+	}
+	if err := tx.Create(&record).Error; err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		Token:        access,
+		AccessToken:  access,
+		RefreshToken: refresh,
+		ExpiresIn:    int64(AccessTokenTTL.Seconds()),
+	}, nil
+}
+
+// revokeFamily revokes every live refresh token rotated from the same login.
+func revokeFamily(tx *gorm.DB, familyID string) error {
+	return tx.Model(&models.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", time.Now()).Error
+}
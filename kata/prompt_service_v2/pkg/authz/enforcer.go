@@ -3,18 +3,33 @@ package authz
 
 import (
 	"bufio"
-
+	"bytes"
+	_ "embed"
+	"fmt"
+	"io"
 	"os"
-
 	"strings"
 
-
 	"github.com/casbin/casbin/v2"
 	gormadapter "github.com/casbin/gorm-adapter/v3"
 	"github.com/walterfan/prompt-service/pkg/database"
+	"github.com/walterfan/prompt-service/pkg/models"
 )
 
-var Enforcer *casbin.Enforcer
+// AdminRole may do everything; its policy is restored on every start so admins
+// can't lock themselves out through the policy API.
+const AdminRole = "admin"
+
+var Enforcer *casbin.SyncedEnforcer
+
+// adminPolicy lets AdminRole do everything.
+var adminPolicy = []string{AdminRole, "/api/v1/*", "*"}
+
+// defaultPolicies are seeded when the casbin_rule table has no policies yet. The
+// same file documents the format accepted by ImportPoliciesFromCSV.
+//
+//go:embed policy.csv
+var defaultPolicies []byte
 
 // refer to https://github.com/casbin/gorm-adapter , the table named casbin_rules
 // Gorm Adapter is the Gorm adapter for Casbin. With this library,
@@ -30,7 +45,7 @@ func InitAuthz(modelConfigfile string) {
 	adapter, err := gormadapter.NewAdapterByDB(database.DB)
 	must(err)
 
-	enforcer, err := casbin.NewEnforcer(modelConfigfile, adapter)
+	enforcer, err := casbin.NewSyncedEnforcer(modelConfigfile, adapter)
 	must(err)
 
 	err = enforcer.LoadPolicy()
@@ -41,37 +56,123 @@ func InitAuthz(modelConfigfile string) {
 	must(err)
 
 	if len(policies) == 0 {
-		_, err := Enforcer.AddPolicy("admin", "/api/v1/prompts/*", "*")
+		_, err := importPolicies("policy.csv", bytes.NewReader(defaultPolicies))
 		must(err)
 	}
+	_, err = Enforcer.AddPolicy(adminPolicy)
+	must(err)
+
+	must(SyncUserRoles())
+}
+
+// SetUserRole makes role the only role of username, mirroring models.User.Role.
+// A user named like their role needs no grouping (e.g. the default admin user).
+func SetUserRole(username, role string) error {
+	if _, err := Enforcer.DeleteRolesForUser(username); err != nil {
+		return err
+	}
+	if role == "" || role == username {
+		return nil
+	}
+	_, err := Enforcer.AddRoleForUser(username, role)
+	return err
+}
+
+// RemoveUser drops the role grouping of a deleted user.
+func RemoveUser(username string) error {
+	_, err := Enforcer.DeleteRolesForUser(username)
+	return err
+}
+
+// SyncUserRoles rebuilds the user groupings from the users table, so roles changed
+// directly in the database or before this feature existed take effect.
+func SyncUserRoles() error {
+	var users []models.User
+	if err := database.DB.Find(&users).Error; err != nil {
+		return err
+	}
+	for _, user := range users {
+		if err := SetUserRole(user.Username, user.Role); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// HasRole reports whether sub is role or inherits it, directly or through other roles.
+func HasRole(sub, role string) bool {
+	if sub == role {
+		return true
+	}
+	roles, err := Enforcer.GetImplicitRolesForUser(sub)
+	if err != nil {
+		return false
+	}
+	for _, r := range roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
 }
 
-func loadPoliciesFromCSV(enforcer *casbin.Enforcer, filePath string) {
+// IsRole reports whether role grants any permission, directly or through the roles it
+// inherits, so that assigning it to a user means something.
+func IsRole(role string) bool {
+	if role == AdminRole {
+		return true
+	}
+	permissions, err := Enforcer.GetImplicitPermissionsForUser(role)
+	return err == nil && len(permissions) > 0
+}
+
+// ImportPoliciesFromCSV adds the "p, sub, obj, act" and "g, sub, role" lines of a
+// casbin policy file; a line without the type prefix is read as a policy. Existing
+// rules are kept. It returns the number of rules that were added.
+func ImportPoliciesFromCSV(filePath string) (int, error) {
 	file, err := os.Open(filePath)
 	if err != nil {
-		panic(err)
+		return 0, err
 	}
 	defer file.Close()
+	return importPolicies(filePath, file)
+}
 
-	scanner := bufio.NewScanner(file)
+// importPolicies adds the rules of the policy file r, reporting errors under name.
+func importPolicies(name string, r io.Reader) (int, error) {
+	var err error
+	added := 0
+	lineNum := 0
+	scanner := bufio.NewScanner(r)
 	for scanner.Scan() {
+		lineNum++
 		line := strings.TrimSpace(scanner.Text())
 		if line == "" || strings.HasPrefix(line, "#") {
 			continue
 		}
 
 		parts := strings.Split(line, ",")
-		if len(parts) < 4 || strings.ToLower(strings.TrimSpace(parts[0])) != "p" {
-			continue // only process 'p' type policies
+		for i := range parts {
+			parts[i] = strings.TrimSpace(parts[i])
 		}
 
-		subject := strings.TrimSpace(parts[1])
-		object := strings.TrimSpace(parts[2])
-		action := strings.TrimSpace(parts[3])
-
-		_, err := enforcer.AddPolicy(subject, object, action)
+		var ok bool
+		switch {
+		case strings.EqualFold(parts[0], "p") && len(parts) == 4:
+			ok, err = Enforcer.AddPolicy(parts[1], parts[2], parts[3])
+		case strings.EqualFold(parts[0], "g") && len(parts) == 3:
+			ok, err = Enforcer.AddGroupingPolicy(parts[1], parts[2])
+		case len(parts) == 3:
+			ok, err = Enforcer.AddPolicy(parts[0], parts[1], parts[2])
+		default:
+			return added, fmt.Errorf("%s:%d: unrecognized policy line %q", name, lineNum, line)
+		}
 		if err != nil {
-			panic(err)
+			return added, err
+		}
+		if ok {
+			added++
 		}
 	}
+	return added, scanner.Err()
 }
@@ -14,7 +14,7 @@ func CasbinMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		sub := user.(string)           // e.g., "alice"
+		sub := user.(string)           // e.g., "alice", who inherits the policies of her role
 		obj := c.Request.URL.Path      // e.g., "/api/v1/prompts/1"
 		act := c.Request.Method        // e.g., "GET"
 
@@ -24,4 +24,16 @@ func CasbinMiddleware() gin.HandlerFunc {
 			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Access denied"})
 		}
 	}
-}
\ No newline at end of file
+}
+
+// RequireRole only lets through users that have role, whatever the policies say.
+func RequireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, exists := c.Get("user")
+		if !exists || !HasRole(user.(string), role) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,74 @@
+// pkg/authz/watcher.go
+package authz
+
+import (
+	"context"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// policyChannel carries a notification whenever an instance changes the policy.
+const policyChannel = "prompt-service:casbin:update"
+
+// InitWatcher makes every instance sharing the Redis client reload its policy after
+// another one changes it. Without Redis a single instance already sees its own
+// changes, since the enforcer updates its in-memory model together with the database.
+func InitWatcher(client *redis.Client) error {
+	if client == nil {
+		return nil
+	}
+	w := &redisWatcher{client: client, pubsub: client.Subscribe(context.Background(), policyChannel)}
+	if err := Enforcer.SetWatcher(w); err != nil {
+		w.Close()
+		return err
+	}
+	// the default callback reloads the unsynchronized inner enforcer; go through the lock
+	w.SetUpdateCallback(func(string) {
+		if err := Enforcer.LoadPolicy(); err != nil {
+			zap.L().Error("Failed to reload authorization policy", zap.Error(err))
+			return
+		}
+		zap.L().Info("Reloaded authorization policy")
+	})
+	go w.listen()
+	return nil
+}
+
+// redisWatcher implements persist.Watcher over Redis pub/sub.
+type redisWatcher struct {
+	client *redis.Client
+	pubsub *redis.PubSub
+
+	mu       sync.Mutex
+	callback func(string)
+}
+
+func (w *redisWatcher) SetUpdateCallback(callback func(string)) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.callback = callback
+	return nil
+}
+
+func (w *redisWatcher) Update() error {
+	return w.client.Publish(context.Background(), policyChannel, "update").Err()
+}
+
+func (w *redisWatcher) Close() {
+	w.pubsub.Close()
+}
+
+func (w *redisWatcher) listen() {
+	for range w.pubsub.Channel() {
+		w.mu.Lock()
+		callback := w.callback
+		w.mu.Unlock()
+		if callback == nil {
+			continue
+		}
+		// the publishing instance reloads too, which is harmless
+		callback("update")
+	}
+}
@@ -0,0 +1,88 @@
+// pkg/cache/cache.go
+package cache
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/walterfan/prompt-service/internal/log"
+	"github.com/walterfan/prompt-service/pkg/metrics"
+	"go.uber.org/zap"
+)
+
+// Store keeps cached values by key. The generation is part of every key Fetch
+// uses, so bumping it invalidates them all at once; it is never evicted.
+type Store interface {
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Generation(ctx context.Context) (int64, error)
+	Bump(ctx context.Context) error
+}
+
+var (
+	// Prompts caches prompt reads; nil disables caching. Init picks the implementation.
+	Prompts Store = NewLRU(1000)
+	// TTL bounds how stale an entry can get when another process changes the
+	// prompts, e.g. the import command, or another instance without Redis.
+	TTL = 5 * time.Minute
+)
+
+// Init keeps the cache in Redis when a client is given, so instances share it and its
+// invalidations; otherwise in an LRU of size entries. A size of 0 disables caching.
+func Init(client *redis.Client, size int, ttl time.Duration) {
+	TTL = ttl
+	switch {
+	case size <= 0:
+		Prompts = nil
+	case client != nil:
+		Prompts = &redisStore{client: client}
+	default:
+		Prompts = NewLRU(size)
+	}
+}
+
+// Fetch returns the value cached under name and key, or calls load and caches what it
+// returns. Values are cached per generation, read before load, so a value loaded while
+// the prompts change is never served after Invalidate. An unavailable store is bypassed.
+func Fetch(ctx context.Context, name, key string, load func() ([]byte, error)) ([]byte, error) {
+	if Prompts == nil {
+		return load()
+	}
+
+	generation, err := Prompts.Generation(ctx)
+	if err != nil {
+		log.Ctx(ctx).Error("Cache unavailable", zap.String("cache", name), zap.Error(err))
+		return load()
+	}
+	full := name + ":" + strconv.FormatInt(generation, 10) + ":" + key
+	if value, ok, err := Prompts.Get(ctx, full); err != nil {
+		log.Ctx(ctx).Error("Cache unavailable", zap.String("cache", name), zap.Error(err))
+		return load()
+	} else if ok {
+		metrics.CacheRequests.WithLabelValues(name, "hit").Inc()
+		return value, nil
+	}
+
+	metrics.CacheRequests.WithLabelValues(name, "miss").Inc()
+	value, err := load()
+	if err != nil {
+		return nil, err
+	}
+	if err := Prompts.Set(ctx, full, value, TTL); err != nil {
+		log.Ctx(ctx).Error("Cache unavailable", zap.String("cache", name), zap.Error(err))
+	}
+	return value, nil
+}
+
+// Invalidate drops every cached prompt read; call it after prompts, their shares or
+// the teams of their owners change.
+func Invalidate(ctx context.Context) {
+	if Prompts == nil {
+		return
+	}
+	if err := Prompts.Bump(ctx); err != nil {
+		log.Ctx(ctx).Error("Cache invalidation failed", zap.Error(err))
+	}
+}
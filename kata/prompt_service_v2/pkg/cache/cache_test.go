@@ -0,0 +1,65 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLRU(t *testing.T) {
+	ctx := context.Background()
+	c := NewLRU(2)
+	_ = c.Set(ctx, "a", []byte("1"), time.Minute)
+	_ = c.Set(ctx, "b", []byte("2"), time.Minute)
+	c.Get(ctx, "a") // b is now the least recently used
+	_ = c.Set(ctx, "c", []byte("3"), time.Minute)
+
+	if _, ok, _ := c.Get(ctx, "b"); ok {
+		t.Error("b was not evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok, _ := c.Get(ctx, key); !ok {
+			t.Errorf("%s was evicted", key)
+		}
+	}
+
+	_ = c.Set(ctx, "d", []byte("4"), -time.Second)
+	if _, ok, _ := c.Get(ctx, "d"); ok {
+		t.Error("expired entry was returned")
+	}
+}
+
+func TestFetch(t *testing.T) {
+	ctx := context.Background()
+	Init(nil, 10, time.Minute)
+	t.Cleanup(func() { Init(nil, 1000, 5*time.Minute) })
+
+	loads := 0
+	load := func() ([]byte, error) {
+		loads++
+		return []byte{byte('0' + loads)}, nil
+	}
+	fetch := func() string {
+		value, err := Fetch(ctx, "test", "key", load)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(value)
+	}
+
+	if got := fetch(); got != "1" {
+		t.Errorf("first fetch = %q, want a load", got)
+	}
+	if got := fetch(); got != "1" {
+		t.Errorf("second fetch = %q, want the cached value", got)
+	}
+	Invalidate(ctx)
+	if got := fetch(); got != "2" {
+		t.Errorf("fetch after Invalidate = %q, want a load", got)
+	}
+
+	Init(nil, 0, time.Minute)
+	if got := fetch(); got != "3" {
+		t.Errorf("fetch with caching disabled = %q, want a load", got)
+	}
+}
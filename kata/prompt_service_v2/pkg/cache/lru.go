@@ -0,0 +1,83 @@
+// pkg/cache/lru.go
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type lruEntry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+// lru is an in-process Store holding at most size entries, evicting the least
+// recently used one first.
+type lru struct {
+	mu         sync.Mutex
+	size       int
+	order      *list.List // front is the most recently used
+	entries    map[string]*list.Element
+	generation atomic.Int64
+}
+
+func NewLRU(size int) Store {
+	return &lru{size: size, order: list.New(), entries: map[string]*list.Element{}}
+}
+
+func (c *lru) Get(_ context.Context, key string) ([]byte, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	entry := el.Value.(*lruEntry)
+	if time.Now().After(entry.expires) {
+		c.order.Remove(el)
+		delete(c.entries, key)
+		return nil, false, nil
+	}
+	c.order.MoveToFront(el)
+	return entry.value, true, nil
+}
+
+func (c *lru) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expires := time.Now().Add(ttl)
+	if el, ok := c.entries[key]; ok {
+		entry := el.Value.(*lruEntry)
+		entry.value, entry.expires = value, expires
+		c.order.MoveToFront(el)
+		return nil
+	}
+	c.entries[key] = c.order.PushFront(&lruEntry{key: key, value: value, expires: expires})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*lruEntry).key)
+	}
+	return nil
+}
+
+func (c *lru) Generation(context.Context) (int64, error) {
+	return c.generation.Load(), nil
+}
+
+// Bump also drops the entries right away rather than waiting for them to be evicted.
+func (c *lru) Bump(context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation.Add(1)
+	c.order.Init()
+	c.entries = map[string]*list.Element{}
+	return nil
+}
@@ -0,0 +1,48 @@
+// pkg/cache/redis.go
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	keyPrefix     = "prompt-service:cache:"
+	generationKey = keyPrefix + "generation"
+)
+
+// redisStore shares the cache between instances. Entries expire by TTL and through
+// Redis eviction; the generation key has no TTL.
+type redisStore struct {
+	client *redis.Client
+}
+
+func (r *redisStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	value, err := r.client.Get(ctx, keyPrefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+func (r *redisStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return r.client.Set(ctx, keyPrefix+key, value, ttl).Err()
+}
+
+func (r *redisStore) Generation(ctx context.Context) (int64, error) {
+	generation, err := r.client.Get(ctx, generationKey).Int64()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	return generation, err
+}
+
+func (r *redisStore) Bump(ctx context.Context) error {
+	return r.client.Incr(ctx, generationKey).Err()
+}
@@ -0,0 +1,69 @@
+package database
+
+import (
+	"fmt"
+
+	"github.com/walterfan/prompt-service/pkg/models"
+	"gorm.io/gorm"
+)
+
+// auditLogSchema makes audit_entries append-only for everything going through the
+// database; the hash chain still catches edits made around it.
+var auditLogSchema = map[string][]string{
+	"sqlite": {
+		`CREATE TRIGGER IF NOT EXISTS audit_entries_no_update BEFORE UPDATE ON audit_entries BEGIN
+			SELECT RAISE(ABORT, 'audit log is append-only');
+		END`,
+		`CREATE TRIGGER IF NOT EXISTS audit_entries_no_delete BEFORE DELETE ON audit_entries BEGIN
+			SELECT RAISE(ABORT, 'audit log is append-only');
+		END`,
+	},
+	"postgres": {
+		`CREATE OR REPLACE FUNCTION audit_entries_append_only() RETURNS trigger AS $$
+		BEGIN
+			RAISE EXCEPTION 'audit log is append-only';
+		END;
+		$$ LANGUAGE plpgsql`,
+		`DROP TRIGGER IF EXISTS audit_entries_no_change ON audit_entries`,
+		`CREATE TRIGGER audit_entries_no_change BEFORE UPDATE OR DELETE ON audit_entries
+			FOR EACH ROW EXECUTE PROCEDURE audit_entries_append_only()`,
+	},
+	"mysql": {
+		`DROP TRIGGER IF EXISTS audit_entries_no_update`,
+		`CREATE TRIGGER audit_entries_no_update BEFORE UPDATE ON audit_entries FOR EACH ROW
+			SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'audit log is append-only'`,
+		`DROP TRIGGER IF EXISTS audit_entries_no_delete`,
+		`CREATE TRIGGER audit_entries_no_delete BEFORE DELETE ON audit_entries FOR EACH ROW
+			SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'audit log is append-only'`,
+	},
+}
+
+func appendOnlyAuditLog(tx *gorm.DB) error {
+	stmts, ok := auditLogSchema[tx.Dialector.Name()]
+	if !ok {
+		return fmt.Errorf("no audit log triggers for %s", tx.Dialector.Name())
+	}
+	for _, stmt := range stmts {
+		if err := tx.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// uniqueAuditLinks lets the database reject a second entry linked to the same
+// predecessor, which replicas appending at the same time would otherwise write.
+func uniqueAuditLinks(tx *gorm.DB) error {
+	migrator := tx.Migrator()
+	if migrator.HasIndex(&models.AuditEntry{}, "PrevHash") {
+		return nil
+	}
+	if tx.Dialector.Name() == "mysql" {
+		// MySQL can't index the text column created before; SQLite would rebuild
+		// the table and drop its triggers, so only alter it here
+		if err := migrator.AlterColumn(&models.AuditEntry{}, "PrevHash"); err != nil {
+			return err
+		}
+	}
+	return migrator.CreateIndex(&models.AuditEntry{}, "PrevHash")
+}
@@ -0,0 +1,106 @@
+package database
+
+import (
+	"context"
+	"log"
+	"os"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/walterfan/prompt-service/pkg/models"
+	"github.com/walterfan/prompt-service/pkg/tracing"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+var DB *gorm.DB
+
+// Ctx returns DB bound to ctx, e.g. a *gin.Context, so its queries are traced as
+// part of the request.
+func Ctx(ctx context.Context) *gorm.DB {
+	return DB.WithContext(ctx)
+}
+
+// InitDB connects to the database given by config.Database and migrates it to
+// the latest schema version.
+func InitDB(driver, dsn string) {
+	dialector, err := Dialector(driver, dsn)
+	if err != nil {
+		log.Fatal(err)
+	}
+	DB, err = gorm.Open(dialector, &gorm.Config{})
+	if err != nil {
+		log.Fatal("Failed to connect database: ", err)
+	}
+	if err := DB.Use(tracing.GormPlugin()); err != nil {
+		log.Fatal("Failed to trace database queries: ", err)
+	}
+
+	if _, err := Migrate(DB); err != nil {
+		log.Fatal("Migration failed: ", err)
+	}
+
+	initSearch()
+	InitData()
+	backfillPromptVersions()
+	backfillPromptTags()
+}
+
+// backfillPromptVersions gives prompts created before versioning existed their first revision.
+func backfillPromptVersions() {
+	var prompts []models.Prompt
+	DB.Where("id NOT IN (?)", DB.Model(&models.PromptVersion{}).Select("prompt_id")).Find(&prompts)
+	for i := range prompts {
+		version := models.NewPromptVersion(&prompts[i], "system", "initial version")
+		if err := DB.Create(&version).Error; err != nil {
+			log.Fatal("Failed to backfill prompt version: ", err)
+		}
+	}
+}
+
+func InitData() {
+	var count int64
+	DB.Model(&models.Prompt{}).Count(&count)
+	if count == 0 {
+		// Load prompts from config
+		var prompts []models.Prompt
+		if err := viper.UnmarshalKey("prompts", &prompts); err != nil {
+			log.Fatalf("Unable to decode into struct: %v", err)
+		}
+
+		result := DB.Create(&prompts)
+		if result.Error != nil {
+			log.Fatal("Failed to insert initial prompt data: ", result.Error)
+		}
+
+		for i := range prompts {
+			version := models.NewPromptVersion(&prompts[i], "system", "initial data")
+			if err := DB.Create(&version).Error; err != nil {
+				log.Fatal("Failed to insert initial prompt version: ", err)
+			}
+		}
+	}
+
+	defaultUsername := os.Getenv("DEFAULT_USERNAME")
+	defaultPassword := os.Getenv("DEFAULT_PASSWORD")
+	defaultEmail := os.Getenv("DEFAULT_EMAIL")
+
+	pwdHash, _ := bcrypt.GenerateFromPassword([]byte(defaultPassword), bcrypt.DefaultCost)
+	// Check if users already exist
+	DB.Model(&models.User{}).Count(&count)
+	if count == 0 {
+		users := []models.User{
+			{
+				Username:  defaultUsername,
+				Password:  string(pwdHash),
+				Email:     defaultEmail,
+				Role:      "admin",
+				ExpiredAt: time.Now().AddDate(1, 0, 0), // valid for 1 year
+			},
+		}
+		DB.Create(&users)
+		log.Println("Initialized database with sample users.")
+	}
+
+	log.Println("Initialized database with sample Go-related prompts.")
+}
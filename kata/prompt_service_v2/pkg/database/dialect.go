@@ -0,0 +1,46 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// datetimePrecision keeps microseconds on MySQL, which would otherwise round
+// timestamps to milliseconds and break the audit hash chain.
+var datetimePrecision = 6
+
+// Dialector returns the gorm dialector of driver, see config.Database.
+func Dialector(driver, dsn string) (gorm.Dialector, error) {
+	switch driver {
+	case "sqlite":
+		return sqlite.Open(dsn), nil
+	case "postgres":
+		return postgres.Open(dsn), nil
+	case "mysql":
+		// time.Time columns need parseTime, and UTC keeps them comparable across servers
+		if !strings.Contains(dsn, "parseTime=") {
+			dsn += separator(dsn) + "parseTime=true"
+		}
+		if !strings.Contains(dsn, "loc=") {
+			dsn += separator(dsn) + "loc=UTC"
+		}
+		return mysql.New(mysql.Config{
+			DSN:                      dsn,
+			DefaultDatetimePrecision: &datetimePrecision,
+		}), nil
+	default:
+		return nil, fmt.Errorf("unsupported database driver: %s", driver)
+	}
+}
+
+func separator(dsn string) string {
+	if strings.Contains(dsn, "?") {
+		return "&"
+	}
+	return "?"
+}
@@ -0,0 +1,85 @@
+package database
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/walterfan/prompt-service/pkg/models"
+	"gorm.io/gorm"
+)
+
+// SchemaMigration records a migration applied to the database.
+type SchemaMigration struct {
+	Version   int       `json:"version" gorm:"primaryKey;autoIncrement:false"`
+	Name      string    `json:"name"`
+	AppliedAt time.Time `json:"appliedAt"`
+}
+
+// migration changes the schema from version-1 to version. Migrations are applied
+// in order and never edited once released; change the schema by appending one.
+type migration struct {
+	version int
+	name    string
+	up      func(tx *gorm.DB) error
+}
+
+var migrations = []migration{
+	{1, "initial schema", func(tx *gorm.DB) error {
+		// databases created before versioned migrations already have these
+		// tables; AutoMigrate leaves them as they are
+		return tx.AutoMigrate(&models.Prompt{}, &models.PromptVersion{}, &models.PromptRun{}, &models.Tag{},
+			&models.User{}, &models.RefreshToken{}, &models.APIKey{}, &models.PromptShare{},
+			&models.AuditEntry{}, &models.PasswordResetToken{})
+	}},
+	{2, "append-only audit log", appendOnlyAuditLog},
+	{3, "prompt evaluations", func(tx *gorm.DB) error {
+		return tx.AutoMigrate(&models.PromptTestCase{}, &models.PromptEvaluation{})
+	}},
+	{4, "prompt inheritance", func(tx *gorm.DB) error {
+		return tx.AutoMigrate(&models.Prompt{}, &models.PromptVersion{})
+	}},
+	{5, "unique audit chain links", uniqueAuditLinks},
+}
+
+// Migrate applies the pending migrations, each in its own transaction where the
+// database supports transactional DDL. It returns the schema version.
+func Migrate(db *gorm.DB) (int, error) {
+	if err := db.AutoMigrate(&SchemaMigration{}); err != nil {
+		return 0, err
+	}
+
+	var applied []int
+	if err := db.Model(&SchemaMigration{}).Pluck("version", &applied).Error; err != nil {
+		return 0, err
+	}
+	done := make(map[int]bool, len(applied))
+	for _, v := range applied {
+		done[v] = true
+	}
+
+	version := 0
+	for _, m := range migrations {
+		if !done[m.version] {
+			err := db.Transaction(func(tx *gorm.DB) error {
+				if err := m.up(tx); err != nil {
+					return err
+				}
+				return tx.Create(&SchemaMigration{Version: m.version, Name: m.name, AppliedAt: time.Now()}).Error
+			})
+			if err != nil {
+				return version, fmt.Errorf("migration %d (%s): %w", m.version, m.name, err)
+			}
+			log.Printf("Applied migration %d: %s", m.version, m.name)
+		}
+		version = m.version
+	}
+	return version, nil
+}
+
+// AppliedMigrations lists the migrations recorded in the database.
+func AppliedMigrations(db *gorm.DB) ([]SchemaMigration, error) {
+	var applied []SchemaMigration
+	err := db.Order("version").Find(&applied).Error
+	return applied, err
+}
@@ -0,0 +1,149 @@
+package database
+
+import (
+	"log"
+	"strings"
+
+	"github.com/walterfan/prompt-service/pkg/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// FTSEnabled reports whether the prompts_fts index is available. It needs SQLite
+// built with FTS5 (go build -tags sqlite_fts5); otherwise search falls back to LIKE.
+var FTSEnabled bool
+
+// prompts_fts is an external-content FTS5 table kept in sync with prompts by triggers.
+var ftsSchema = []string{
+	`CREATE VIRTUAL TABLE IF NOT EXISTS prompts_fts USING fts5(
+		name, description, system_prompt, user_prompt,
+		content='prompts', content_rowid='id'
+	)`,
+	`CREATE TRIGGER IF NOT EXISTS prompts_fts_ai AFTER INSERT ON prompts BEGIN
+		INSERT INTO prompts_fts(rowid, name, description, system_prompt, user_prompt)
+		VALUES (new.id, new.name, new.description, new.system_prompt, new.user_prompt);
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS prompts_fts_ad AFTER DELETE ON prompts BEGIN
+		INSERT INTO prompts_fts(prompts_fts, rowid, name, description, system_prompt, user_prompt)
+		VALUES ('delete', old.id, old.name, old.description, old.system_prompt, old.user_prompt);
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS prompts_fts_au AFTER UPDATE ON prompts BEGIN
+		INSERT INTO prompts_fts(prompts_fts, rowid, name, description, system_prompt, user_prompt)
+		VALUES ('delete', old.id, old.name, old.description, old.system_prompt, old.user_prompt);
+		INSERT INTO prompts_fts(rowid, name, description, system_prompt, user_prompt)
+		VALUES (new.id, new.name, new.description, new.system_prompt, new.user_prompt);
+	END`,
+}
+
+func initSearch() {
+	FTSEnabled = false
+	if DB.Dialector.Name() != "sqlite" {
+		return
+	}
+
+	var exists int64
+	DB.Raw("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'prompts_fts'").Scan(&exists)
+
+	// probing for FTS5 is expected to fail on some builds, so keep gorm quiet about it
+	quiet := DB.Session(&gorm.Session{Logger: logger.Default.LogMode(logger.Silent)})
+	err := quiet.Transaction(func(tx *gorm.DB) error {
+		for _, stmt := range ftsSchema {
+			if err := tx.Exec(stmt).Error; err != nil {
+				return err
+			}
+		}
+		if exists == 0 {
+			// index the rows written before the table existed
+			return tx.Exec("INSERT INTO prompts_fts(prompts_fts) VALUES ('rebuild')").Error
+		}
+		return nil
+	})
+	if err != nil {
+		log.Println("Full-text search disabled, falling back to LIKE: ", err)
+		return
+	}
+	FTSEnabled = true
+}
+
+// FTSQuery turns free text into an FTS5 query that matches every word as a prefix.
+func FTSQuery(text string) string {
+	var terms []string
+	for _, word := range strings.Fields(text) {
+		terms = append(terms, `"`+strings.ReplaceAll(word, `"`, `""`)+`"*`)
+	}
+	return strings.Join(terms, " ")
+}
+
+// WithTags restricts a prompts query to prompts carrying all of the given tags.
+func WithTags(query *gorm.DB, tags []string) *gorm.DB {
+	if len(tags) == 0 {
+		return query
+	}
+	sub := DB.Table("prompt_tags").
+		Select("prompt_tags.prompt_id").
+		Joins("JOIN tags ON tags.id = prompt_tags.tag_id").
+		Where("tags.name IN ?", tags).
+		Group("prompt_tags.prompt_id").
+		Having("COUNT(DISTINCT tags.name) = ?", len(tags))
+	return query.Where("prompts.id IN (?)", sub)
+}
+
+// SyncPromptTags normalizes p.Tags and replaces the prompt's rows in prompt_tags to match it.
+func SyncPromptTags(tx *gorm.DB, p *models.Prompt) error {
+	names := models.ParseTags(p.Tags)
+	normalized := strings.Join(names, ",")
+	if normalized != p.Tags {
+		if err := tx.Model(p).UpdateColumn("tags", normalized).Error; err != nil {
+			return err
+		}
+	}
+
+	tags := make([]models.Tag, 0, len(names))
+	for _, name := range names {
+		tag := models.Tag{Name: name}
+		if err := tx.Where(models.Tag{Name: name}).FirstOrCreate(&tag).Error; err != nil {
+			return err
+		}
+		tags = append(tags, tag)
+	}
+	return tx.Model(p).Association("TagList").Replace(tags)
+}
+
+// TagCounts lists the tags of the prompts selected by visible, a query of prompt
+// IDs, with the number of live prompts using each.
+func TagCounts(db, visible *gorm.DB) ([]models.TagCount, error) {
+	var counts []models.TagCount
+	err := db.Table("tags").
+		Select("tags.name AS name, COUNT(prompts.id) AS count").
+		Joins("JOIN prompt_tags ON prompt_tags.tag_id = tags.id").
+		Joins("JOIN prompts ON prompts.id = prompt_tags.prompt_id AND prompts.deleted_at IS NULL").
+		Where("prompts.id IN (?)", visible).
+		Group("tags.name").
+		Order("count DESC, tags.name").
+		Scan(&counts).Error
+	return counts, err
+}
+
+// TaggedPromptIDs selects the IDs of the prompts carrying tag name.
+func TaggedPromptIDs(db *gorm.DB, name string) *gorm.DB {
+	return db.Table("prompt_tags").Select("prompt_tags.prompt_id").
+		Joins("JOIN tags ON tags.id = prompt_tags.tag_id").
+		Where("tags.name = ?", name)
+}
+
+// DeleteUnusedTag deletes tag name once no prompt, deleted ones included, carries it.
+func DeleteUnusedTag(tx *gorm.DB, name string) error {
+	used := tx.Table("prompt_tags").Select("prompt_tags.tag_id")
+	return tx.Where("name = ? AND id NOT IN (?)", name, used).Delete(&models.Tag{}).Error
+}
+
+// backfillPromptTags links prompts created before the tags table existed.
+func backfillPromptTags() {
+	var prompts []models.Prompt
+	DB.Where("tags <> '' AND id NOT IN (?)", DB.Table("prompt_tags").Select("prompt_id")).Find(&prompts)
+	for i := range prompts {
+		if err := SyncPromptTags(DB, &prompts[i]); err != nil {
+			log.Fatal("Failed to backfill prompt tags: ", err)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+// pkg/eval/assert.go
+package eval
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/walterfan/prompt-service/pkg/llm"
+	"github.com/walterfan/prompt-service/pkg/models"
+	"github.com/walterfan/prompt-service/pkg/openapi"
+)
+
+// ValidateAssertions rejects unknown assertion types, invalid regular expressions and
+// schemas, so a test case fails on its output rather than on its definition.
+func ValidateAssertions(assertions []models.Assertion) error {
+	for i, a := range assertions {
+		switch a.Type {
+		case models.AssertEquals:
+		case models.AssertContains, models.AssertLLMJudge:
+			if a.Value == "" {
+				return fmt.Errorf("assertion %d: %s needs a value", i+1, a.Type)
+			}
+		case models.AssertRegex:
+			if _, err := regexp.Compile(a.Value); err != nil {
+				return fmt.Errorf("assertion %d: %v", i+1, err)
+			}
+		case models.AssertJSONSchema:
+			if _, err := parseSchema(a.Schema); err != nil {
+				return fmt.Errorf("assertion %d: %v", i+1, err)
+			}
+		default:
+			return fmt.Errorf("assertion %d: unknown type %q", i+1, a.Type)
+		}
+	}
+	return nil
+}
+
+// parseSchema reads the OpenAPI subset of JSON Schema that openapi.ValidateJSON supports:
+// type, properties, required, items, enum, format date-time and nullable.
+func parseSchema(raw json.RawMessage) (*openapi.Schema, error) {
+	if len(raw) == 0 {
+		return nil, errors.New("json_schema needs a schema")
+	}
+	var schema openapi.Schema
+	if err := json.Unmarshal(raw, &schema); err != nil {
+		return nil, fmt.Errorf("invalid schema: %v", err)
+	}
+	if schema.Ref != "" {
+		return nil, errors.New("invalid schema: $ref is not supported")
+	}
+	return &schema, nil
+}
+
+// check runs one assertion against output; input is the rendered user message,
+// shown to the judge.
+func (r *Runner) check(ctx context.Context, a models.Assertion, input, output string) models.AssertionResult {
+	result := models.AssertionResult{Type: a.Type}
+	fail := func(format string, args ...interface{}) models.AssertionResult {
+		result.Message = fmt.Sprintf(format, args...)
+		return result
+	}
+
+	switch a.Type {
+	case models.AssertEquals:
+		if strings.TrimSpace(output) != strings.TrimSpace(a.Value) {
+			return fail("output is not %q", a.Value)
+		}
+	case models.AssertContains:
+		if !strings.Contains(output, a.Value) {
+			return fail("output does not contain %q", a.Value)
+		}
+	case models.AssertRegex:
+		re, err := regexp.Compile(a.Value)
+		if err != nil {
+			return fail("%v", err)
+		}
+		if !re.MatchString(output) {
+			return fail("output does not match %s", a.Value)
+		}
+	case models.AssertJSONSchema:
+		schema, err := parseSchema(a.Schema)
+		if err != nil {
+			return fail("%v", err)
+		}
+		if err := openapi.ValidateJSON(schema, []byte(stripCodeFence(output))); err != nil {
+			return fail("%v", err)
+		}
+	case models.AssertLLMJudge:
+		passed, reason, err := r.judge(ctx, a.Value, input, output)
+		if err != nil {
+			return fail("judge failed: %v", err)
+		}
+		result.Message = reason
+		result.Passed = passed
+		return result
+	default:
+		return fail("unknown type %q", a.Type)
+	}
+	result.Passed = true
+	return result
+}
+
+// stripCodeFence unwraps output like ```json ... ```, which models often add around JSON.
+func stripCodeFence(output string) string {
+	s := strings.TrimSpace(output)
+	if !strings.HasPrefix(s, "```") || !strings.HasSuffix(s, "```") || len(s) < 6 {
+		return s
+	}
+	s = strings.TrimSuffix(strings.TrimPrefix(s, "```"), "```")
+	if newline := strings.IndexByte(s, '\n'); newline >= 0 {
+		s = s[newline+1:] // the language tag
+	}
+	return s
+}
+
+const judgeInstructions = `You grade the output another model gave for an input against a criterion.
+Reply with a JSON object only: {"pass": true or false, "reason": "one sentence"}.`
+
+type verdict struct {
+	Pass   *bool  `json:"pass"`
+	Reason string `json:"reason"`
+}
+
+// judge asks the judge model whether output meets criterion.
+func (r *Runner) judge(ctx context.Context, criterion, input, output string) (bool, string, error) {
+	temperature := 0.0
+	req := llm.ChatRequest{
+		Model:       r.judgeModel(),
+		Temperature: &temperature,
+		Messages: []llm.Message{
+			{Role: "system", Content: judgeInstructions},
+			{Role: "user", Content: fmt.Sprintf("Criterion:\n%s\n\nInput:\n%s\n\nOutput:\n%s", criterion, input, output)},
+		},
+	}
+	resp, err := r.Provider.ChatStream(ctx, req, ignoreTokens)
+	if err != nil {
+		return false, "", err
+	}
+	return parseVerdict(resp.Content)
+}
+
+// parseVerdict reads the judge's JSON reply, falling back to a leading PASS or FAIL.
+func parseVerdict(reply string) (bool, string, error) {
+	if start, end := strings.Index(reply, "{"), strings.LastIndex(reply, "}"); start >= 0 && end > start {
+		var v verdict
+		if err := json.Unmarshal([]byte(reply[start:end+1]), &v); err == nil && v.Pass != nil {
+			return *v.Pass, v.Reason, nil
+		}
+	}
+	text := strings.TrimSpace(reply)
+	switch upper := strings.ToUpper(text); {
+	case strings.HasPrefix(upper, "PASS"):
+		return true, strings.TrimSpace(text[4:]), nil
+	case strings.HasPrefix(upper, "FAIL"):
+		return false, strings.TrimSpace(text[4:]), nil
+	}
+	return false, "", fmt.Errorf("no verdict in %q", reply)
+}
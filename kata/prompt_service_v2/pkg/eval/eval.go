@@ -0,0 +1,129 @@
+// pkg/eval/eval.go
+package eval
+
+import (
+	"context"
+	"time"
+
+	"github.com/walterfan/prompt-service/pkg/llm"
+	"github.com/walterfan/prompt-service/pkg/models"
+	"github.com/walterfan/prompt-service/pkg/render"
+)
+
+// Runner evaluates prompts against one model. Tests give it a fake llm.Provider.
+type Runner struct {
+	Provider    llm.Provider
+	Model       string
+	JudgeModel  string // grades llm_judge assertions, Model when empty
+	Temperature *float64
+	MaxTokens   int
+}
+
+func (r *Runner) judgeModel() string {
+	if r.JudgeModel != "" {
+		return r.JudgeModel
+	}
+	return r.Model
+}
+
+func ignoreTokens(string) error {
+	return nil
+}
+
+// Run renders p with the variables of every case in turn, sends it to the model and
+// checks the output. The report is for p.Version and is not stored. A case whose
+// variables don't render or whose call fails scores 0; a failed call also marks the
+// whole evaluation failed, since its score then says little about the prompt.
+func (r *Runner) Run(ctx context.Context, p *models.Prompt, cases []models.PromptTestCase) *models.PromptEvaluation {
+	report := &models.PromptEvaluation{
+		PromptID: p.ID,
+		Version:  p.Version,
+		Model:    r.Model,
+		Status:   models.EvaluationCompleted,
+		Total:    len(cases),
+		Results:  make([]models.CaseResult, 0, len(cases)),
+	}
+	for _, tc := range cases {
+		if hasJudge(tc.Assertions) {
+			report.JudgeModel = r.judgeModel()
+		}
+	}
+
+	start := time.Now()
+	var scores float64
+	for _, tc := range cases {
+		result, callErr := r.runCase(ctx, p, tc)
+		if callErr {
+			report.Status = models.EvaluationFailed
+		}
+		if result.Passed {
+			report.Passed++
+		}
+		scores += result.Score
+		report.TotalTokens += result.TotalTokens
+		report.Results = append(report.Results, result)
+	}
+	report.LatencyMs = time.Since(start).Milliseconds()
+	if len(cases) > 0 {
+		report.Score = scores / float64(len(cases))
+	}
+	return report
+}
+
+// runCase reports whether the model call failed besides the case's result.
+func (r *Runner) runCase(ctx context.Context, p *models.Prompt, tc models.PromptTestCase) (models.CaseResult, bool) {
+	result := models.CaseResult{TestCaseID: tc.ID, Name: tc.Name, Assertions: []models.AssertionResult{}}
+
+	rendered, err := render.Render(p, tc.Variables)
+	if err != nil {
+		result.Error = err.Error()
+		return result, false
+	}
+	req := llm.ChatRequest{Model: r.Model, Temperature: r.Temperature, MaxTokens: r.MaxTokens}
+	var input string
+	for _, m := range rendered.Messages {
+		req.Messages = append(req.Messages, llm.Message{Role: m.Role, Content: m.Content})
+		if m.Role == "user" {
+			input = m.Content
+		}
+	}
+
+	start := time.Now()
+	resp, err := r.Provider.ChatStream(ctx, req, ignoreTokens)
+	result.LatencyMs = time.Since(start).Milliseconds()
+	if err != nil {
+		result.Error = err.Error()
+		return result, true
+	}
+	result.Output = resp.Content
+	result.TotalTokens = resp.Usage.TotalTokens
+
+	assertions := tc.Assertions
+	if tc.Expected != "" {
+		assertions = append([]models.Assertion{{Type: models.AssertEquals, Value: tc.Expected}}, assertions...)
+	}
+	passed := 0
+	for _, a := range assertions {
+		check := r.check(ctx, a, input, resp.Content)
+		if check.Passed {
+			passed++
+		}
+		result.Assertions = append(result.Assertions, check)
+	}
+	// a case without assertions only checks that the model answers
+	result.Passed = passed == len(assertions)
+	result.Score = 1
+	if len(assertions) > 0 {
+		result.Score = float64(passed) / float64(len(assertions))
+	}
+	return result, false
+}
+
+func hasJudge(assertions []models.Assertion) bool {
+	for _, a := range assertions {
+		if a.Type == models.AssertLLMJudge {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,145 @@
+package eval
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/walterfan/prompt-service/pkg/llm"
+	"github.com/walterfan/prompt-service/pkg/models"
+)
+
+// fakeLLM answers from a table keyed by the last message, and judges with judgeReply.
+type fakeLLM struct {
+	answers    map[string]string
+	judgeReply string
+	requests   []llm.ChatRequest
+}
+
+func (f *fakeLLM) ChatStream(ctx context.Context, req llm.ChatRequest, onToken func(string) error) (*llm.ChatResponse, error) {
+	f.requests = append(f.requests, req)
+	user := req.Messages[len(req.Messages)-1].Content
+	if strings.HasPrefix(user, "Criterion:") {
+		return &llm.ChatResponse{Model: req.Model, Content: f.judgeReply}, nil
+	}
+	answer, ok := f.answers[user]
+	if !ok {
+		return nil, errors.New("backend unavailable")
+	}
+	return &llm.ChatResponse{Model: req.Model, Content: answer, Usage: llm.Usage{TotalTokens: 10}}, nil
+}
+
+var capital = &models.Prompt{
+	ID:         1,
+	Version:    3,
+	UserPrompt: "What is the capital of {{country}}?",
+	Variables:  []models.PromptVariable{{Name: "country", Type: "string", Required: true}},
+}
+
+func testCase(id uint, country string, assertions ...models.Assertion) models.PromptTestCase {
+	return models.PromptTestCase{ID: id, Name: country, Variables: map[string]interface{}{"country": country}, Assertions: assertions}
+}
+
+func TestAssertions(t *testing.T) {
+	f := &fakeLLM{
+		answers: map[string]string{
+			"What is the capital of France?": "Paris",
+			"What is the capital of Japan?":  "```json\n{\"city\": \"Tokyo\", \"population\": 14}\n```",
+		},
+		judgeReply: "FAIL the answer is too short",
+	}
+	r := &Runner{Provider: f, Model: "fake", JudgeModel: "judge"}
+	schema := json.RawMessage(`{"type": "object", "required": ["city"], "properties": {"city": {"type": "string"}, "population": {"type": "integer"}}}`)
+
+	tests := []struct {
+		name      string
+		country   string
+		assertion models.Assertion
+		pass      bool
+	}{
+		{"equals", "France", models.Assertion{Type: models.AssertEquals, Value: " Paris\n"}, true},
+		{"equals mismatch", "France", models.Assertion{Type: models.AssertEquals, Value: "paris"}, false},
+		{"contains", "France", models.Assertion{Type: models.AssertContains, Value: "Par"}, true},
+		{"contains mismatch", "France", models.Assertion{Type: models.AssertContains, Value: "Lyon"}, false},
+		{"regex", "France", models.Assertion{Type: models.AssertRegex, Value: "^P[a-z]+$"}, true},
+		{"regex mismatch", "France", models.Assertion{Type: models.AssertRegex, Value: `\d`}, false},
+		{"json schema in a code fence", "Japan", models.Assertion{Type: models.AssertJSONSchema, Schema: schema}, true},
+		{"json schema not JSON", "France", models.Assertion{Type: models.AssertJSONSchema, Schema: schema}, false},
+		{"judge", "France", models.Assertion{Type: models.AssertLLMJudge, Value: "Names the city in full"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := r.Run(context.Background(), capital, []models.PromptTestCase{testCase(1, tt.country, tt.assertion)})
+			result := report.Results[0]
+			if result.Passed != tt.pass || len(result.Assertions) != 1 {
+				t.Fatalf("result = %+v, want passed %v", result, tt.pass)
+			}
+			if !tt.pass && result.Assertions[0].Message == "" {
+				t.Error("failed assertion without a message")
+			}
+		})
+	}
+
+	judged := f.requests[len(f.requests)-1]
+	if judged.Model != "judge" || !strings.Contains(judged.Messages[1].Content, "What is the capital of France?") {
+		t.Errorf("judge request = %+v", judged)
+	}
+}
+
+func TestRunScoresCases(t *testing.T) {
+	f := &fakeLLM{
+		answers:    map[string]string{"What is the capital of France?": "Paris", "What is the capital of Italy?": "Rome"},
+		judgeReply: `Sure: {"pass": true, "reason": "correct"}`,
+	}
+	r := &Runner{Provider: f, Model: "fake"}
+	cases := []models.PromptTestCase{
+		{ID: 1, Name: "France", Variables: map[string]interface{}{"country": "France"}, Expected: "Paris",
+			Assertions: []models.Assertion{{Type: models.AssertLLMJudge, Value: "Correct"}}},
+		{ID: 2, Name: "Italy", Variables: map[string]interface{}{"country": "Italy"}, Expected: "Milan",
+			Assertions: []models.Assertion{{Type: models.AssertContains, Value: "Ro"}}},
+		testCase(3, "Spain"), // the backend fails
+		{ID: 4, Name: "missing variable"},
+	}
+
+	report := r.Run(context.Background(), capital, cases)
+	if report.Version != 3 || report.Total != 4 || report.Passed != 1 || report.JudgeModel != "fake" {
+		t.Errorf("report = %+v", report)
+	}
+	if report.Status != models.EvaluationFailed {
+		t.Errorf("status = %s, want failed after a backend error", report.Status)
+	}
+	// France 1, Italy 1/2, Spain and the render error 0
+	if report.Score != 1.5/4 {
+		t.Errorf("score = %v, want %v", report.Score, 1.5/4)
+	}
+	if report.TotalTokens != 20 {
+		t.Errorf("total tokens = %d, want 20", report.TotalTokens)
+	}
+	if report.Results[2].Error == "" || report.Results[3].Error == "" {
+		t.Errorf("results = %+v", report.Results[2:])
+	}
+}
+
+func TestValidateAssertions(t *testing.T) {
+	valid := []models.Assertion{
+		{Type: models.AssertEquals},
+		{Type: models.AssertRegex, Value: "^a"},
+		{Type: models.AssertJSONSchema, Schema: json.RawMessage(`{"type": "array", "items": {"type": "string"}}`)},
+	}
+	if err := ValidateAssertions(valid); err != nil {
+		t.Fatal(err)
+	}
+	for _, a := range []models.Assertion{
+		{Type: "similar"},
+		{Type: models.AssertContains},
+		{Type: models.AssertRegex, Value: "("},
+		{Type: models.AssertJSONSchema},
+		{Type: models.AssertJSONSchema, Schema: json.RawMessage(`{"$ref": "#/components/schemas/Prompt"}`)},
+	} {
+		if err := ValidateAssertions([]models.Assertion{a}); err == nil {
+			t.Errorf("%+v accepted", a)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/walterfan/prompt-service/pkg/audit"
+	"github.com/walterfan/prompt-service/pkg/auth"
+	"github.com/walterfan/prompt-service/pkg/authz"
+	"github.com/walterfan/prompt-service/pkg/database"
+	"github.com/walterfan/prompt-service/pkg/models"
+	"gorm.io/gorm"
+
+	"github.com/gin-gonic/gin"
+)
+
+// loadKeyOwner loads user :id and checks that the caller is that user or an admin.
+func loadKeyOwner(c *gin.Context) (*models.User, bool) {
+	var user models.User
+	if err := database.Ctx(c).First(&user, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return nil, false
+	}
+
+	caller := currentUser(c)
+	if caller != user.Username && !authz.HasRole(caller, authz.AdminRole) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return nil, false
+	}
+	return &user, true
+}
+
+// CreateAPIKey issues a key for user :id. The key is only returned by this call.
+type apiKeyInput struct {
+	Name      string     `json:"name" binding:"required"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expiresAt"`
+}
+
+// createdAPIKey carries the key in clear; it is only ever shown in this response.
+type createdAPIKey struct {
+	APIKey models.APIKey `json:"apiKey"`
+	Key    string        `json:"key"`
+}
+
+func CreateAPIKey(c *gin.Context) {
+	user, ok := loadKeyOwner(c)
+	if !ok {
+		return
+	}
+
+	var input apiKeyInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(input.Scopes) == 0 {
+		input.Scopes = []string{"prompts:read"}
+	}
+	if err := auth.ValidateScopes(input.Scopes); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.ExpiresAt != nil && input.ExpiresAt.Before(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "expiresAt must be in the future"})
+		return
+	}
+
+	key, prefix, err := auth.NewAPIKey()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	apiKey := models.APIKey{
+		UserID:    user.ID,
+		Name:      input.Name,
+		Prefix:    prefix,
+		KeyHash:   auth.HashToken(key),
+		Scopes:    strings.Join(input.Scopes, ","),
+		ExpiresAt: input.ExpiresAt,
+	}
+	err = database.Ctx(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&apiKey).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.ActionCreate, audit.EntityAPIKey, fmt.Sprint(apiKey.ID), nil, apiKey)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, createdAPIKey{APIKey: apiKey, Key: key})
+}
+
+func ListAPIKeys(c *gin.Context) {
+	user, ok := loadKeyOwner(c)
+	if !ok {
+		return
+	}
+
+	var keys []models.APIKey
+	if err := database.Ctx(c).Where("user_id = ?", user.ID).Order("id desc").Find(&keys).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, keys)
+}
+
+func RevokeAPIKey(c *gin.Context) {
+	user, ok := loadKeyOwner(c)
+	if !ok {
+		return
+	}
+
+	var apiKey models.APIKey
+	if err := database.Ctx(c).Where("id = ? AND user_id = ?", c.Param("keyId"), user.ID).First(&apiKey).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+		return
+	}
+
+	if apiKey.RevokedAt == nil {
+		before := apiKey
+		now := time.Now()
+		err := database.Ctx(c).Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&apiKey).Update("revoked_at", now).Error; err != nil {
+				return err
+			}
+			return audit.Record(tx, audit.ActionUpdate, audit.EntityAPIKey, fmt.Sprint(apiKey.ID), before, apiKey)
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "API key revoked"})
+}
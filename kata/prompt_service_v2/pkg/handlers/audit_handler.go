@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/walterfan/prompt-service/pkg/audit"
+	"github.com/walterfan/prompt-service/pkg/database"
+	"github.com/walterfan/prompt-service/pkg/models"
+	"github.com/walterfan/prompt-service/pkg/pagination"
+	"gorm.io/gorm"
+
+	"github.com/gin-gonic/gin"
+)
+
+// auditSorts whitelists the sort parameter of ListAuditEntries.
+var auditSorts = map[string]pagination.Field{
+	"id":        {Column: "id"},
+	"createdAt": {Column: "created_at", Time: true},
+}
+
+// ListAuditEntries: GET /api/v1/audit?actor=&action=&entityType=&entityId=&requestId=&from=&to=,
+// where from and to are RFC 3339 times.
+func ListAuditEntries(c *gin.Context) {
+	page, err := pagination.Parse(c, auditSorts, "id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	query := database.Ctx(c).Model(&models.AuditEntry{})
+	filters := []struct{ param, column string }{
+		{"actor", "actor"},
+		{"action", "action"},
+		{"entityType", "entity_type"},
+		{"entityId", "entity_id"},
+		{"requestId", "request_id"},
+	}
+	for _, f := range filters {
+		if v := c.Query(f.param); v != "" {
+			query = query.Where(f.column+" = ?", v)
+		}
+	}
+	for param, op := range map[string]string{"from": ">=", "to": "<"} {
+		v := c.Query(param)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + param + ": " + v})
+			return
+		}
+		query = query.Where("created_at "+op+" ?", t.UTC())
+	}
+
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var entries []models.AuditEntry
+	if err := page.Apply(query, "audit_entries").Find(&entries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, pagination.NewPage(page, entries, total, func(e models.AuditEntry) (interface{}, uint) {
+		if page.Sort == "createdAt" {
+			return e.CreatedAt, e.ID
+		}
+		return e.ID, e.ID
+	}))
+}
+
+// VerifyAuditLog recomputes the hash chain: GET /api/v1/audit/verify.
+func VerifyAuditLog(c *gin.Context) {
+	result, err := audit.Verify(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
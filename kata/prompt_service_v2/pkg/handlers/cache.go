@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/walterfan/prompt-service/pkg/access"
+	"github.com/walterfan/prompt-service/pkg/cache"
+	"github.com/walterfan/prompt-service/pkg/database"
+	"github.com/walterfan/prompt-service/pkg/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// findCachedPrompt reads a live prompt through the cache. The cached row is the same
+// for every caller; access is still decided per request.
+func findCachedPrompt(c *gin.Context, id string, prompt *models.Prompt) error {
+	if _, err := strconv.ParseUint(id, 10, 64); err != nil {
+		return err
+	}
+	data, err := cache.Fetch(c, "prompt", id, func() ([]byte, error) {
+		var p models.Prompt
+		if err := database.Ctx(c).First(&p, id).Error; err != nil {
+			return nil, err
+		}
+		return json.Marshal(p)
+	})
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, prompt)
+}
+
+// cacheKeyOf identifies what s may see, for caching results restricted by Visible.
+func cacheKeyOf(s *access.Subject) string {
+	if s.Admin {
+		return "admin"
+	}
+	return strconv.Quote(s.Username) + ":" + strconv.Quote(s.Team)
+}
+
+// writeJSONWithETag answers 200 with body, or 304 without it when the client's
+// If-None-Match holds the body's ETag. Responses depend on the caller, so shared
+// caches must not store them.
+func writeJSONWithETag(c *gin.Context, body []byte) {
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", "private, no-cache")
+	if etagMatches(c.GetHeader("If-None-Match"), etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	c.Data(http.StatusOK, "application/json; charset=utf-8", body)
+}
+
+// etagMatches compares If-None-Match weakly, as RFC 9110 asks for GET.
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/walterfan/prompt-service/pkg/access"
+	"github.com/walterfan/prompt-service/pkg/database"
+	"github.com/walterfan/prompt-service/pkg/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// currentUser returns the subject JwtMiddleware stored in the context, if any.
+func currentUser(c *gin.Context) string {
+	if user, ok := c.Get("user"); ok {
+		if name, ok := user.(string); ok {
+			return name
+		}
+	}
+	return ""
+}
+
+// currentSubject returns the caller's access subject, looked up once per request.
+func currentSubject(c *gin.Context) *access.Subject {
+	if s, ok := c.Get("accessSubject"); ok {
+		return s.(*access.Subject)
+	}
+	s := access.SubjectOf(c, currentUser(c))
+	c.Set("accessSubject", s)
+	return s
+}
+
+// loadPrompt loads prompt :id if the caller has at least the given access, writing the
+// error response when it fails. Prompts the caller can't see are reported as not found.
+// Reads go through the cache; changes start from the database row.
+func loadPrompt(c *gin.Context, need access.Level) (*models.Prompt, bool) {
+	id := c.Param("id")
+	var prompt models.Prompt
+	var err error
+	if need == access.View {
+		err = findCachedPrompt(c, id, &prompt)
+	} else {
+		err = database.Ctx(c).First(&prompt, id).Error
+	}
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Prompt not found"})
+		return nil, false
+	}
+
+	level := currentSubject(c).PromptLevel(&prompt)
+	if level == access.None {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Prompt not found"})
+		return nil, false
+	}
+	if level < need {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return nil, false
+	}
+	return &prompt, true
+}
@@ -0,0 +1,346 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/walterfan/prompt-service/pkg/access"
+	"github.com/walterfan/prompt-service/pkg/audit"
+	"github.com/walterfan/prompt-service/pkg/database"
+	"github.com/walterfan/prompt-service/pkg/eval"
+	"github.com/walterfan/prompt-service/pkg/llm"
+	"github.com/walterfan/prompt-service/pkg/models"
+	"gorm.io/gorm"
+
+	"github.com/gin-gonic/gin"
+)
+
+// testCaseInput is the body of CreateTestCase and UpdateTestCase.
+type testCaseInput struct {
+	Name       string                 `json:"name" binding:"required"`
+	Variables  map[string]interface{} `json:"variables"`
+	Expected   string                 `json:"expected"`
+	Assertions []models.Assertion     `json:"assertions"`
+}
+
+// evaluateRequest is the body of EvaluatePrompt.
+type evaluateRequest struct {
+	Version     int      `json:"version"`
+	Model       string   `json:"model"`
+	JudgeModel  string   `json:"judgeModel"`
+	Temperature *float64 `json:"temperature"`
+	MaxTokens   int      `json:"maxTokens"`
+	TestCases   []uint   `json:"testCases"` // ids, every test case of the prompt when empty
+}
+
+// caseComparison is one test case in two evaluations; Before and After are nil
+// where the case wasn't part of the evaluation.
+type caseComparison struct {
+	TestCaseID uint               `json:"testCaseId"`
+	Name       string             `json:"name"`
+	Before     *models.CaseResult `json:"before"`
+	After      *models.CaseResult `json:"after"`
+}
+
+// evaluationComparison is the response of CompareEvaluations.
+type evaluationComparison struct {
+	From  models.PromptEvaluation `json:"from"`
+	To    models.PromptEvaluation `json:"to"`
+	Cases []caseComparison        `json:"cases"`
+}
+
+func (in *testCaseInput) validate(c *gin.Context) bool {
+	in.Name = strings.TrimSpace(in.Name)
+	if in.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Name is required"})
+		return false
+	}
+	if err := eval.ValidateAssertions(in.Assertions); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	return true
+}
+
+// findTestCase loads test case :caseId of the prompt, writing the error response when it fails.
+func findTestCase(c *gin.Context, promptID uint) (*models.PromptTestCase, bool) {
+	var tc models.PromptTestCase
+	if err := database.Ctx(c).Where("id = ? AND prompt_id = ?", c.Param("caseId"), promptID).First(&tc).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Test case not found"})
+		return nil, false
+	}
+	return &tc, true
+}
+
+func ListTestCases(c *gin.Context) {
+	prompt, ok := loadPrompt(c, access.View)
+	if !ok {
+		return
+	}
+
+	var cases []models.PromptTestCase
+	if err := database.Ctx(c).Where("prompt_id = ?", prompt.ID).Order("id").Find(&cases).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, cases)
+}
+
+// CreateTestCase attaches a test case to a prompt:
+// POST /:id/testcases {"name": "...", "variables": {...}, "expected": "...", "assertions": [...]}.
+func CreateTestCase(c *gin.Context) {
+	prompt, ok := loadPrompt(c, access.Edit)
+	if !ok {
+		return
+	}
+
+	var input testCaseInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !input.validate(c) {
+		return
+	}
+
+	tc := models.PromptTestCase{
+		PromptID:   prompt.ID,
+		Name:       input.Name,
+		Variables:  input.Variables,
+		Expected:   input.Expected,
+		Assertions: input.Assertions,
+		CreatedBy:  currentUser(c),
+	}
+	err := database.Ctx(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&tc).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.ActionCreate, audit.EntityTestCase, fmt.Sprint(tc.ID), nil, tc)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, tc)
+}
+
+func UpdateTestCase(c *gin.Context) {
+	prompt, ok := loadPrompt(c, access.Edit)
+	if !ok {
+		return
+	}
+	tc, ok := findTestCase(c, prompt.ID)
+	if !ok {
+		return
+	}
+
+	var input testCaseInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !input.validate(c) {
+		return
+	}
+
+	before := *tc
+	tc.Name, tc.Variables, tc.Expected, tc.Assertions = input.Name, input.Variables, input.Expected, input.Assertions
+	err := database.Ctx(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(tc).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.ActionUpdate, audit.EntityTestCase, fmt.Sprint(tc.ID), before, tc)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, tc)
+}
+
+func DeleteTestCase(c *gin.Context) {
+	prompt, ok := loadPrompt(c, access.Edit)
+	if !ok {
+		return
+	}
+	tc, ok := findTestCase(c, prompt.ID)
+	if !ok {
+		return
+	}
+
+	err := database.Ctx(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(tc).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.ActionDelete, audit.EntityTestCase, fmt.Sprint(tc.ID), tc, nil)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Test case deleted"})
+}
+
+// EvaluatePrompt runs the prompt's test cases against a model and stores the scored
+// report for the revision: POST /:id/evaluate {"version": 2, "model": "gpt-4o-mini"}.
+// It answers once every case has run, so keep the suites small.
+func EvaluatePrompt(c *gin.Context) {
+	var req evaluateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if llm.Client == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": llm.ErrNotConfigured.Error()})
+		return
+	}
+
+	model := req.Model
+	if model == "" {
+		model = llm.DefaultModel
+	}
+	if model == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Model is required"})
+		return
+	}
+
+	prompt, ok := loadPromptForRender(c, req.Version)
+	if !ok {
+		return
+	}
+	prompt, _, ok = expandPrompt(c, prompt)
+	if !ok {
+		return
+	}
+
+	query := database.Ctx(c).Where("prompt_id = ?", prompt.ID)
+	if len(req.TestCases) > 0 {
+		query = query.Where("id IN ?", req.TestCases)
+	}
+	var cases []models.PromptTestCase
+	if err := query.Order("id").Find(&cases).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if len(cases) == 0 || len(cases) < len(req.TestCases) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No such test cases for this prompt"})
+		return
+	}
+
+	runner := eval.Runner{
+		Provider:    llm.Client,
+		Model:       model,
+		JudgeModel:  req.JudgeModel,
+		Temperature: req.Temperature,
+		MaxTokens:   req.MaxTokens,
+	}
+	report := runner.Run(c.Request.Context(), prompt, cases)
+	report.Caller = currentUser(c)
+	if err := database.Ctx(c).Create(report).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, report)
+}
+
+// ListEvaluations returns the latest reports of a prompt, optionally of one ?version=.
+func ListEvaluations(c *gin.Context) {
+	prompt, ok := loadPrompt(c, access.View)
+	if !ok {
+		return
+	}
+
+	query := database.Ctx(c).Where("prompt_id = ?", prompt.ID)
+	if v := c.Query("version"); v != "" {
+		version, err := strconv.Atoi(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid version: " + v})
+			return
+		}
+		query = query.Where("version = ?", version)
+	}
+	var reports []models.PromptEvaluation
+	if err := query.Order("id desc").Limit(100).Find(&reports).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, reports)
+}
+
+func GetEvaluation(c *gin.Context) {
+	prompt, ok := loadPrompt(c, access.View)
+	if !ok {
+		return
+	}
+
+	var report models.PromptEvaluation
+	if err := database.Ctx(c).Where("id = ? AND prompt_id = ?", c.Param("evaluationId"), prompt.ID).First(&report).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Evaluation not found"})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// CompareEvaluations puts the latest reports of two revisions side by side, case by
+// case: GET /:id/evaluations/compare?from=1&to=2, optionally for one &model=.
+func CompareEvaluations(c *gin.Context) {
+	prompt, ok := loadPrompt(c, access.View)
+	if !ok {
+		return
+	}
+
+	latest := func(param string) (*models.PromptEvaluation, bool) {
+		version, err := strconv.Atoi(c.Query(param))
+		if err != nil || version < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + param + " version: " + c.Query(param)})
+			return nil, false
+		}
+		query := database.Ctx(c).Where("prompt_id = ? AND version = ?", prompt.ID, version)
+		if model := c.Query("model"); model != "" {
+			query = query.Where("model = ?", model)
+		}
+		var reports []models.PromptEvaluation
+		if err := query.Order("id desc").Limit(1).Find(&reports).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return nil, false
+		}
+		if len(reports) == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Version %d has not been evaluated", version)})
+			return nil, false
+		}
+		return &reports[0], true
+	}
+	from, ok := latest("from")
+	if !ok {
+		return
+	}
+	to, ok := latest("to")
+	if !ok {
+		return
+	}
+
+	byCase := map[uint]*caseComparison{}
+	for i := range from.Results {
+		r := &from.Results[i]
+		byCase[r.TestCaseID] = &caseComparison{TestCaseID: r.TestCaseID, Name: r.Name, Before: r}
+	}
+	for i := range to.Results {
+		r := &to.Results[i]
+		if cmp, ok := byCase[r.TestCaseID]; ok {
+			cmp.Name, cmp.After = r.Name, r
+		} else {
+			byCase[r.TestCaseID] = &caseComparison{TestCaseID: r.TestCaseID, Name: r.Name, After: r}
+		}
+	}
+	cases := make([]caseComparison, 0, len(byCase))
+	for _, cmp := range byCase {
+		cases = append(cases, *cmp)
+	}
+	sort.Slice(cases, func(i, j int) bool { return cases[i].TestCaseID < cases[j].TestCaseID })
+
+	c.JSON(http.StatusOK, evaluationComparison{From: *from, To: *to, Cases: cases})
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/walterfan/prompt-service/pkg/authz"
+	"github.com/walterfan/prompt-service/pkg/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+// draining is set once shutdown starts, so /readyz takes the instance out of rotation
+// while in-flight requests finish.
+var draining atomic.Bool
+
+// StartDraining makes /readyz fail from now on.
+func StartDraining() {
+	draining.Store(true)
+}
+
+// Healthz is the liveness probe: it answers as long as the process serves HTTP, and
+// deliberately doesn't check dependencies, so an outage doesn't get it restarted.
+func Healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// Readyz is the readiness probe: 503 while draining or when the database or the
+// casbin enforcer isn't usable, with the result of every check.
+func Readyz(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 2*time.Second)
+	defer cancel()
+
+	checks := gin.H{}
+	ready := true
+	check := func(name string, err error) {
+		if err != nil {
+			checks[name] = err.Error()
+			ready = false
+			return
+		}
+		checks[name] = "ok"
+	}
+
+	if draining.Load() {
+		check("shutdown", errors.New("draining"))
+	}
+	check("database", pingDatabase(ctx))
+	check("casbin", checkEnforcer())
+
+	status, code := "ok", http.StatusOK
+	if !ready {
+		status, code = "unavailable", http.StatusServiceUnavailable
+	}
+	c.JSON(code, gin.H{"status": status, "checks": checks})
+}
+
+func pingDatabase(ctx context.Context) error {
+	if database.DB == nil {
+		return errors.New("not connected")
+	}
+	sqlDB, err := database.DB.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+// checkEnforcer checks that the model and policies are loaded: the admin policy is
+// restored on every start, so admins must be allowed.
+func checkEnforcer() error {
+	if authz.Enforcer == nil {
+		return errors.New("not initialized")
+	}
+	ok, err := authz.Enforcer.Enforce(authz.AdminRole, "/api/v1/prompts/", "GET")
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("admin policy not loaded")
+	}
+	return nil
+}
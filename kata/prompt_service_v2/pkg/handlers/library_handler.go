@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/walterfan/prompt-service/pkg/cache"
+	"github.com/walterfan/prompt-service/pkg/library"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ExportPrompts downloads the prompt library: GET /export?format=yaml|json|csv&tag=...
+func ExportPrompts(c *gin.Context) {
+	format, err := library.ParseFormat(c.DefaultQuery("format", library.FormatYAML))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	entries, err := library.Export(c, currentSubject(c), c.QueryArray("tag"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := library.Encode(&buf, format, entries); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="prompts.`+format+`"`)
+	c.Data(http.StatusOK, library.ContentType(format), buf.Bytes())
+}
+
+// ImportPrompts upserts prompts by name from a multipart "file" field or the raw body.
+// The format comes from ?format=, the file name or the content type; ?dryRun=true only reports.
+func ImportPrompts(c *gin.Context) {
+	dryRun, _ := strconv.ParseBool(c.DefaultQuery("dryRun", "false"))
+
+	var body io.Reader = c.Request.Body
+	format := c.Query("format")
+	if fh, err := c.FormFile("file"); err == nil {
+		f, err := fh.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		defer f.Close()
+		body = f
+		if format == "" {
+			format = library.FormatOf(fh.Filename)
+		}
+	}
+	if format == "" {
+		format = formatOfContentType(c.ContentType())
+	}
+
+	format, err := library.ParseFormat(format)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	entries, err := library.Decode(body, format)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	report, err := library.Import(c, entries, currentSubject(c), currentUser(c), dryRun)
+	if errors.Is(err, library.ErrInvalidEntries) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error(), "report": report})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !dryRun {
+		cache.Invalidate(c)
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+func formatOfContentType(contentType string) string {
+	switch {
+	case strings.Contains(contentType, "json"):
+		return library.FormatJSON
+	case strings.Contains(contentType, "csv"):
+		return library.FormatCSV
+	}
+	return library.FormatYAML
+}
@@ -0,0 +1,294 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/walterfan/prompt-service/pkg/library"
+	"github.com/walterfan/prompt-service/pkg/models"
+	"github.com/walterfan/prompt-service/pkg/openapi"
+	"github.com/walterfan/prompt-service/pkg/pagination"
+)
+
+// pageParams are the query parameters read by pagination.Parse.
+func pageParams(sorts ...string) []openapi.Param {
+	return []openapi.Param{
+		{Name: "limit", Type: "integer", Description: "Page size, at most 100"},
+		{Name: "pageSize", Type: "integer", Description: "Deprecated alias of limit"},
+		{Name: "sort", Type: "string", Enum: sorts},
+		{Name: "order", Type: "string", Enum: []string{"asc", "desc"}},
+		{Name: "cursor", Type: "string", Description: "nextCursor of the previous page"},
+		{Name: "pageNum", Type: "integer", Description: "Deprecated, use cursor"},
+	}
+}
+
+// libraryFile is the JSON form of an exported or imported prompt library.
+type libraryFile struct {
+	Prompts []library.Entry `json:"prompts"`
+}
+
+var libraryContent = openapi.Content{
+	"application/yaml": nil,
+	"application/json": libraryFile{},
+	"text/csv":         nil,
+}
+
+var (
+	promptTags = []string{"prompts"}
+	evalTags   = []string{"evaluations"}
+	userTags   = []string{"users"}
+)
+
+// APISpec documents the prompt and user routes. openapi.Build reports routes and
+// operations that don't match, so keep it next to the handlers when adding either.
+var APISpec = openapi.Spec{
+	Title:       "Prompt Service API",
+	Version:     "1.0",
+	Description: "Manage, version, share and run LLM prompts.",
+	Prefixes:    []string{"/api/v1/prompts", "/api/v1/users"},
+	Ops: map[string]openapi.Op{
+		"POST /api/v1/prompts/": {
+			Summary:   "Create a prompt, private to the caller unless visibility says otherwise",
+			Tags:      promptTags,
+			Body:      promptInput{},
+			Responses: map[int]interface{}{http.StatusOK: models.Prompt{}},
+		},
+		"GET /api/v1/prompts/": {
+			Summary: "Search the visible prompts; full-text results also carry rank and snippet",
+			Tags:    promptTags,
+			Query: append([]openapi.Param{
+				{Name: "q", Type: "string", Description: "Keywords"},
+				{Name: "tag", Type: "string", Array: true, Description: "Only prompts with all of these tags"},
+			}, pageParams("updatedAt", "createdAt", "name", "id", "relevance")...),
+			Responses: map[int]interface{}{
+				http.StatusOK:          pagination.Page[models.Prompt]{},
+				http.StatusNotModified: openapi.Content{},
+			},
+		},
+		"GET /api/v1/prompts/:id": {
+			Summary: "Get a prompt, or one of its revisions",
+			Tags:    promptTags,
+			Query:   []openapi.Param{{Name: "version", Type: "integer"}},
+			Responses: map[int]interface{}{
+				http.StatusOK:          models.Prompt{},
+				http.StatusNotModified: openapi.Content{},
+			},
+		},
+		"PUT /api/v1/prompts/:id": {
+			Summary: "Update a prompt, writing a new revision",
+			Tags:    promptTags,
+			Body:    promptInput{},
+			Responses: map[int]interface{}{
+				http.StatusOK:       models.Prompt{},
+				http.StatusConflict: openapi.Error{},
+			},
+		},
+		"DELETE /api/v1/prompts/:id": {
+			Summary:   "Delete a prompt",
+			Tags:      promptTags,
+			Responses: map[int]interface{}{http.StatusOK: openapi.Message{}},
+		},
+		"GET /api/v1/prompts/export": {
+			Summary: "Export the visible prompts",
+			Tags:    promptTags,
+			Query: []openapi.Param{
+				{Name: "format", Type: "string", Enum: []string{library.FormatYAML, library.FormatJSON, library.FormatCSV}},
+				{Name: "tag", Type: "string", Array: true},
+			},
+			Responses: map[int]interface{}{http.StatusOK: libraryContent},
+		},
+		"POST /api/v1/prompts/import": {
+			Summary: "Create or update prompts by name from a library file",
+			Tags:    promptTags,
+			Query: []openapi.Param{
+				{Name: "format", Type: "string", Enum: []string{library.FormatYAML, library.FormatJSON, library.FormatCSV}},
+				{Name: "dryRun", Type: "boolean"},
+			},
+			Body: openapi.Content{
+				"application/yaml":    nil,
+				"application/json":    libraryFile{},
+				"text/csv":            nil,
+				"multipart/form-data": nil,
+			},
+			Responses: map[int]interface{}{http.StatusOK: library.Report{}},
+		},
+		"GET /api/v1/prompts/:id/versions": {
+			Summary:   "List the revisions of a prompt",
+			Tags:      promptTags,
+			Responses: map[int]interface{}{http.StatusOK: []models.PromptVersion{}},
+		},
+		"GET /api/v1/prompts/:id/versions/diff": {
+			Summary: "Diff two revisions",
+			Tags:    promptTags,
+			Query: []openapi.Param{
+				{Name: "from", Type: "integer", Required: true},
+				{Name: "to", Type: "integer", Description: "Defaults to the current revision"},
+			},
+			Responses: map[int]interface{}{http.StatusOK: versionDiff{}},
+		},
+		"GET /api/v1/prompts/:id/versions/:version": {
+			Summary:   "Get a revision",
+			Tags:      promptTags,
+			Responses: map[int]interface{}{http.StatusOK: models.PromptVersion{}},
+		},
+		"POST /api/v1/prompts/:id/versions/:version/rollback": {
+			Summary: "Restore a revision as a new revision",
+			Tags:    promptTags,
+			Body:    rollbackInput{},
+			Responses: map[int]interface{}{
+				http.StatusOK:       models.Prompt{},
+				http.StatusConflict: openapi.Error{},
+			},
+		},
+		"GET /api/v1/prompts/:id/expanded": {
+			Summary:   "Preview the prompt with its base applied and partials inserted",
+			Tags:      promptTags,
+			Query:     []openapi.Param{{Name: "version", Type: "integer", Description: "Defaults to the current revision"}},
+			Responses: map[int]interface{}{http.StatusOK: expandedPrompt{}},
+		},
+		"POST /api/v1/prompts/:id/render": {
+			Summary:   "Fill in the prompt's variables",
+			Tags:      promptTags,
+			Body:      renderRequest{},
+			Responses: map[int]interface{}{http.StatusOK: renderResponse{}},
+		},
+		"POST /api/v1/prompts/:id/run": {
+			Summary: "Run the prompt against the configured LLM, streaming token, then done or error events",
+			Tags:    promptTags,
+			Body:    runRequest{},
+			Responses: map[int]interface{}{
+				http.StatusOK:                 openapi.Content{"text/event-stream": nil},
+				http.StatusServiceUnavailable: openapi.Error{},
+			},
+		},
+		"GET /api/v1/prompts/:id/runs": {
+			Summary:   "List the runs of a prompt",
+			Tags:      promptTags,
+			Responses: map[int]interface{}{http.StatusOK: []models.PromptRun{}},
+		},
+		"GET /api/v1/prompts/:id/testcases": {
+			Summary:   "List the test cases of a prompt",
+			Tags:      evalTags,
+			Responses: map[int]interface{}{http.StatusOK: []models.PromptTestCase{}},
+		},
+		"POST /api/v1/prompts/:id/testcases": {
+			Summary:   "Add a test case: input variables plus an expected output and assertions",
+			Tags:      evalTags,
+			Body:      testCaseInput{},
+			Responses: map[int]interface{}{http.StatusCreated: models.PromptTestCase{}},
+		},
+		"PUT /api/v1/prompts/:id/testcases/:caseId": {
+			Summary:   "Replace a test case",
+			Tags:      evalTags,
+			Body:      testCaseInput{},
+			Responses: map[int]interface{}{http.StatusOK: models.PromptTestCase{}},
+		},
+		"DELETE /api/v1/prompts/:id/testcases/:caseId": {
+			Summary:   "Delete a test case",
+			Tags:      evalTags,
+			Responses: map[int]interface{}{http.StatusOK: openapi.Message{}},
+		},
+		"POST /api/v1/prompts/:id/evaluate": {
+			Summary: "Run the test cases against a model and store the scored report of the revision",
+			Tags:    evalTags,
+			Body:    evaluateRequest{},
+			Responses: map[int]interface{}{
+				http.StatusCreated:            models.PromptEvaluation{},
+				http.StatusServiceUnavailable: openapi.Error{},
+			},
+		},
+		"GET /api/v1/prompts/:id/evaluations": {
+			Summary:   "List the latest evaluation reports",
+			Tags:      evalTags,
+			Query:     []openapi.Param{{Name: "version", Type: "integer"}},
+			Responses: map[int]interface{}{http.StatusOK: []models.PromptEvaluation{}},
+		},
+		"GET /api/v1/prompts/:id/evaluations/compare": {
+			Summary: "Compare the latest reports of two revisions case by case",
+			Tags:    evalTags,
+			Query: []openapi.Param{
+				{Name: "from", Type: "integer", Required: true},
+				{Name: "to", Type: "integer", Required: true},
+				{Name: "model", Type: "string", Description: "Only reports of this model"},
+			},
+			Responses: map[int]interface{}{http.StatusOK: evaluationComparison{}},
+		},
+		"GET /api/v1/prompts/:id/evaluations/:evaluationId": {
+			Summary:   "Get an evaluation report",
+			Tags:      evalTags,
+			Responses: map[int]interface{}{http.StatusOK: models.PromptEvaluation{}},
+		},
+		"GET /api/v1/prompts/:id/shares": {
+			Summary:   "List who the prompt is shared with",
+			Tags:      promptTags,
+			Responses: map[int]interface{}{http.StatusOK: []models.PromptShare{}},
+		},
+		"POST /api/v1/prompts/:id/shares": {
+			Summary:   "Share the prompt with a user or team, or change their role",
+			Tags:      promptTags,
+			Body:      shareInput{},
+			Responses: map[int]interface{}{http.StatusCreated: models.PromptShare{}},
+		},
+		"DELETE /api/v1/prompts/:id/shares/:shareId": {
+			Summary:   "Stop sharing the prompt",
+			Tags:      promptTags,
+			Responses: map[int]interface{}{http.StatusOK: openapi.Message{}},
+		},
+		"POST /api/v1/prompts/:id/transfer": {
+			Summary:   "Hand the prompt to another user",
+			Tags:      promptTags,
+			Body:      transferInput{},
+			Responses: map[int]interface{}{http.StatusOK: models.Prompt{}},
+		},
+
+		"POST /api/v1/users/": {
+			Summary:   "Create a user",
+			Tags:      userTags,
+			Body:      userInput{},
+			Responses: map[int]interface{}{http.StatusOK: models.User{}},
+		},
+		"GET /api/v1/users/": {
+			Summary:   "Search users by username or email",
+			Tags:      userTags,
+			Query:     append([]openapi.Param{{Name: "q", Type: "string"}}, pageParams("updatedAt", "createdAt", "username", "email", "id")...),
+			Responses: map[int]interface{}{http.StatusOK: pagination.Page[models.User]{}},
+		},
+		"GET /api/v1/users/:id": {
+			Summary:   "Get a user",
+			Tags:      userTags,
+			Responses: map[int]interface{}{http.StatusOK: models.User{}},
+		},
+		"PUT /api/v1/users/:id": {
+			Summary:   "Update a user",
+			Tags:      userTags,
+			Body:      userInput{},
+			Responses: map[int]interface{}{http.StatusOK: models.User{}},
+		},
+		"DELETE /api/v1/users/:id": {
+			Summary:   "Delete a user",
+			Tags:      userTags,
+			Responses: map[int]interface{}{http.StatusOK: openapi.Message{}},
+		},
+		"POST /api/v1/users/:id/expiry": {
+			Summary:   "Renew an account",
+			Tags:      userTags,
+			Body:      expiryInput{},
+			Responses: map[int]interface{}{http.StatusOK: models.User{}},
+		},
+		"POST /api/v1/users/:id/apikeys": {
+			Summary:   "Create an API key; the key is only returned here",
+			Tags:      userTags,
+			Body:      apiKeyInput{},
+			Responses: map[int]interface{}{http.StatusCreated: createdAPIKey{}},
+		},
+		"GET /api/v1/users/:id/apikeys": {
+			Summary:   "List the API keys of a user",
+			Tags:      userTags,
+			Responses: map[int]interface{}{http.StatusOK: []models.APIKey{}},
+		},
+		"DELETE /api/v1/users/:id/apikeys/:keyId": {
+			Summary:   "Revoke an API key",
+			Tags:      userTags,
+			Responses: map[int]interface{}{http.StatusOK: openapi.Message{}},
+		},
+	},
+}
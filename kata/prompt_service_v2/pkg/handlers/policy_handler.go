@@ -0,0 +1,249 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/walterfan/prompt-service/internal/log"
+	"github.com/walterfan/prompt-service/pkg/audit"
+	"github.com/walterfan/prompt-service/pkg/authz"
+	"github.com/walterfan/prompt-service/pkg/database"
+	"github.com/walterfan/prompt-service/pkg/models"
+	"gorm.io/gorm"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// Policy allows sub (a role or user) to send act (an HTTP method or "*") to the
+// paths matching obj, where "/*" matches any suffix and ":name" one path segment.
+type Policy struct {
+	Sub string `json:"sub" form:"sub" binding:"required"`
+	Obj string `json:"obj" form:"obj" binding:"required"`
+	Act string `json:"act" form:"act" binding:"required"`
+}
+
+// RoleGrant makes user (a user or role) inherit the policies of role.
+type RoleGrant struct {
+	User string `json:"user" form:"user" binding:"required"`
+	Role string `json:"role" form:"role" binding:"required"`
+}
+
+func (p Policy) rule() []string {
+	return []string{strings.TrimSpace(p.Sub), strings.TrimSpace(p.Obj), strings.TrimSpace(p.Act)}
+}
+
+// key identifies the policy in the audit log.
+func (p Policy) key() string {
+	return strings.Join(p.rule(), ",")
+}
+
+// isAdminPolicy reports whether p is the admin policy that InitAuthz restores on start.
+func (p Policy) isAdminPolicy() bool {
+	rule := p.rule()
+	return rule[0] == authz.AdminRole && rule[1] == "/api/v1/*" && rule[2] == "*"
+}
+
+// recordPolicyChange audits a change the enforcer has already saved through its own
+// connection, and calls undo to take the change back when the entry can't be written.
+func recordPolicyChange(c *gin.Context, undo func() (bool, error), action, entityType, entityID string, before, after interface{}) bool {
+	err := database.Ctx(c).Transaction(func(tx *gorm.DB) error {
+		return audit.Record(tx, action, entityType, entityID, before, after)
+	})
+	if err == nil {
+		return true
+	}
+	if _, undoErr := undo(); undoErr != nil {
+		log.Ctx(c).Error("Failed to undo an unaudited policy change", zap.Error(undoErr))
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	return false
+}
+
+// ListPolicies returns every policy and role grant currently enforced.
+func ListPolicies(c *gin.Context) {
+	rules, err := authz.Enforcer.GetPolicy()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	groupings, err := authz.Enforcer.GetGroupingPolicy()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	policies := make([]Policy, 0, len(rules))
+	for _, r := range rules {
+		policies = append(policies, Policy{Sub: r[0], Obj: r[1], Act: r[2]})
+	}
+	roles := make([]RoleGrant, 0, len(groupings))
+	for _, g := range groupings {
+		roles = append(roles, RoleGrant{User: g[0], Role: g[1]})
+	}
+	c.JSON(http.StatusOK, gin.H{"policies": policies, "roles": roles})
+}
+
+func CreatePolicy(c *gin.Context) {
+	var input Policy
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	added, err := authz.Enforcer.AddPolicy(input.rule())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !added {
+		c.JSON(http.StatusConflict, gin.H{"error": "Policy already exists"})
+		return
+	}
+	undo := func() (bool, error) { return authz.Enforcer.RemovePolicy(input.rule()) }
+	if !recordPolicyChange(c, undo, audit.ActionCreate, audit.EntityPolicy, input.key(), nil, input) {
+		return
+	}
+	c.JSON(http.StatusCreated, input)
+}
+
+// UpdatePolicy replaces a policy: PUT /api/v1/policies {"old": {...}, "new": {...}}.
+func UpdatePolicy(c *gin.Context) {
+	var input struct {
+		Old Policy `json:"old" binding:"required"`
+		New Policy `json:"new" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.Old.isAdminPolicy() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The admin policy can't be changed"})
+		return
+	}
+
+	if exists, _ := authz.Enforcer.HasPolicy(input.Old.rule()); !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Policy not found"})
+		return
+	}
+	if _, err := authz.Enforcer.UpdatePolicy(input.Old.rule(), input.New.rule()); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	undo := func() (bool, error) { return authz.Enforcer.UpdatePolicy(input.New.rule(), input.Old.rule()) }
+	if !recordPolicyChange(c, undo, audit.ActionUpdate, audit.EntityPolicy, input.Old.key(), input.Old, input.New) {
+		return
+	}
+	c.JSON(http.StatusOK, input.New)
+}
+
+// DeletePolicy removes a policy given as query parameters:
+// DELETE /api/v1/policies?sub=user&obj=/api/v1/prompts/*&act=POST.
+func DeletePolicy(c *gin.Context) {
+	var input Policy
+	if err := c.ShouldBindQuery(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.isAdminPolicy() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The admin policy can't be removed"})
+		return
+	}
+
+	removed, err := authz.Enforcer.RemovePolicy(input.rule())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !removed {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Policy not found"})
+		return
+	}
+	undo := func() (bool, error) { return authz.Enforcer.AddPolicy(input.rule()) }
+	if !recordPolicyChange(c, undo, audit.ActionDelete, audit.EntityPolicy, input.key(), input, nil) {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Policy deleted"})
+}
+
+// isUsername reports whether name belongs to a user. A user's own role comes from
+// their Role field, so the role API only manages inheritance between roles.
+func isUsername(c *gin.Context, name string) bool {
+	var count int64
+	database.Ctx(c).Model(&models.User{}).Where("username = ?", name).Count(&count)
+	return count > 0
+}
+
+// AddRoleInheritance makes a role inherit another: POST /api/v1/policies/roles {"user": "editor", "role": "user"}.
+func AddRoleInheritance(c *gin.Context) {
+	var input RoleGrant
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	input.User, input.Role = strings.TrimSpace(input.User), strings.TrimSpace(input.Role)
+	if input.User == input.Role {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A role can't inherit itself"})
+		return
+	}
+	if isUsername(c, input.User) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Set the role of user " + input.User + " through /api/v1/users instead"})
+		return
+	}
+
+	added, err := authz.Enforcer.AddGroupingPolicy(input.User, input.Role)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !added {
+		c.JSON(http.StatusConflict, gin.H{"error": "Role grant already exists"})
+		return
+	}
+	undo := func() (bool, error) { return authz.Enforcer.RemoveGroupingPolicy(input.User, input.Role) }
+	if !recordPolicyChange(c, undo, audit.ActionCreate, audit.EntityRoleGrant, input.User+","+input.Role, nil, input) {
+		return
+	}
+	c.JSON(http.StatusCreated, input)
+}
+
+// RemoveRoleInheritance: DELETE /api/v1/policies/roles?user=editor&role=user.
+func RemoveRoleInheritance(c *gin.Context) {
+	var input RoleGrant
+	if err := c.ShouldBindQuery(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if isUsername(c, input.User) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Set the role of user " + input.User + " through /api/v1/users instead"})
+		return
+	}
+
+	removed, err := authz.Enforcer.RemoveGroupingPolicy(input.User, input.Role)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !removed {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Role grant not found"})
+		return
+	}
+	undo := func() (bool, error) { return authz.Enforcer.AddGroupingPolicy(input.User, input.Role) }
+	if !recordPolicyChange(c, undo, audit.ActionDelete, audit.EntityRoleGrant, input.User+","+input.Role, input, nil) {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Role grant deleted"})
+}
+
+// ReloadPolicies re-reads the policy from the database, e.g. after editing casbin_rule by hand.
+func ReloadPolicies(c *gin.Context) {
+	if err := authz.Enforcer.LoadPolicy(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := authz.SyncUserRoles(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Policy reloaded"})
+}
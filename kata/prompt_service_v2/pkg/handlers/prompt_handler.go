@@ -1,99 +1,327 @@
 package handlers
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
-	"strconv"
 	"strings"
 
+	"github.com/walterfan/prompt-service/pkg/access"
+	"github.com/walterfan/prompt-service/pkg/audit"
+	"github.com/walterfan/prompt-service/pkg/cache"
 	"github.com/walterfan/prompt-service/pkg/database"
 	"github.com/walterfan/prompt-service/pkg/models"
+	"github.com/walterfan/prompt-service/pkg/pagination"
+	"github.com/walterfan/prompt-service/pkg/render"
+	"gorm.io/gorm"
 
 	"github.com/gin-gonic/gin"
 )
 
+// errVersionConflict reports that a prompt got a new revision since it was read.
+var errVersionConflict = errors.New("prompt was changed by another request, reload it and try again")
+
+// updateRevision saves changes to prompt as its next revision. The update only applies
+// while the row is still at the version that was read, so concurrent writers can't
+// both claim the same revision number.
+func updateRevision(tx *gorm.DB, prompt *models.Prompt, changes interface{}) error {
+	result := tx.Model(prompt).Where("version = ?", prompt.Version).Updates(changes)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errVersionConflict
+	}
+	return nil
+}
+
+// writeRevisionError answers 409 for errVersionConflict and 500 for anything else.
+func writeRevisionError(c *gin.Context, err error) {
+	if errors.Is(err, errVersionConflict) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
+
+// promptInput is the request body of create and update; ChangeNote goes to the revision.
+type promptInput struct {
+	models.Prompt
+	ChangeNote string `json:"changeNote"`
+}
+
 func CreatePrompt(c *gin.Context) {
-	var prompt models.Prompt
-	if err := c.ShouldBindJSON(&prompt); err != nil {
+	var input promptInput
+	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	if err := database.DB.Create(&prompt).Error; err != nil {
+	if err := render.ValidateDefinitions(input.Variables); err != nil {
+		writeRenderError(c, err)
+		return
+	}
+	if _, _, ok := expandPrompt(c, &input.Prompt); !ok {
+		return
+	}
+
+	prompt := input.Prompt
+	prompt.Version = 1
+	prompt.Owner = currentUser(c)
+	if prompt.Visibility == "" {
+		prompt.Visibility = models.VisibilityPrivate
+	} else if !models.ValidVisibility(prompt.Visibility) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid visibility: " + prompt.Visibility})
+		return
+	}
+	err := database.Ctx(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&prompt).Error; err != nil {
+			return err
+		}
+		if err := database.SyncPromptTags(tx, &prompt); err != nil {
+			return err
+		}
+		version := models.NewPromptVersion(&prompt, currentUser(c), input.ChangeNote)
+		if err := tx.Create(&version).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.ActionCreate, audit.EntityPrompt, fmt.Sprint(prompt.ID), nil, prompt)
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	cache.Invalidate(c)
 	c.JSON(http.StatusOK, prompt)
 }
 
 func GetPrompt(c *gin.Context) {
-	id := c.Param("id")
-	var prompt models.Prompt
-	if err := database.DB.First(&prompt, id).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Prompt not found"})
+	prompt, ok := loadPrompt(c, access.View)
+	if !ok {
 		return
 	}
-	c.JSON(http.StatusOK, prompt)
+
+	// ?version=N pins the read to an earlier revision
+	if v := c.Query("version"); v != "" {
+		version, ok := findPromptVersion(c, prompt.ID, v)
+		if !ok {
+			return
+		}
+		*prompt = version.AsPrompt(prompt)
+	}
+	body, err := json.Marshal(prompt)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	writeJSONWithETag(c, body)
 }
 
 func UpdatePrompt(c *gin.Context) {
-	id := c.Param("id")
-	var prompt models.Prompt
-	if err := database.DB.First(&prompt, id).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Prompt not found"})
+	prompt, ok := loadPrompt(c, access.Edit)
+	if !ok {
 		return
 	}
 
-	var input models.Prompt
+	var input promptInput
 	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	database.DB.Model(&prompt).Updates(input)
+	if err := render.ValidateDefinitions(input.Variables); err != nil {
+		writeRenderError(c, err)
+		return
+	}
+
+	if input.Visibility != "" && input.Visibility != prompt.Visibility {
+		if !models.ValidVisibility(input.Visibility) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid visibility: " + input.Visibility})
+			return
+		}
+		if currentSubject(c).PromptLevel(prompt) < access.Own {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only the owner can change the visibility"})
+			return
+		}
+	}
+
+	// the prompt as it will be saved must still expand, e.g. not extend itself
+	candidate := *prompt
+	for _, field := range []struct {
+		saved *string
+		input string
+	}{
+		{&candidate.Name, input.Name},
+		{&candidate.SystemPrompt, input.SystemPrompt},
+		{&candidate.UserPrompt, input.UserPrompt},
+		{&candidate.Extends, input.Extends},
+	} {
+		if field.input != "" {
+			*field.saved = field.input
+		}
+	}
+	if _, _, ok := expandPrompt(c, &candidate); !ok {
+		return
+	}
+
+	before := *prompt
+	changes := input.Prompt
+	changes.ID = 0
+	changes.Owner = "" // ownership moves through the transfer endpoint only
+	changes.Version = prompt.Version + 1
+	err := database.Ctx(c).Transaction(func(tx *gorm.DB) error {
+		if err := updateRevision(tx, prompt, changes); err != nil {
+			return err
+		}
+		if err := database.SyncPromptTags(tx, prompt); err != nil {
+			return err
+		}
+		version := models.NewPromptVersion(prompt, currentUser(c), input.ChangeNote)
+		if err := tx.Create(&version).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.ActionUpdate, audit.EntityPrompt, fmt.Sprint(prompt.ID), before, prompt)
+	})
+	if err != nil {
+		writeRevisionError(c, err)
+		return
+	}
+	cache.Invalidate(c)
 	c.JSON(http.StatusOK, prompt)
 }
 
 func DeletePrompt(c *gin.Context) {
-	id := c.Param("id")
-	if err := database.DB.Delete(&models.Prompt{}, id).Error; err != nil {
+	prompt, ok := loadPrompt(c, access.Own)
+	if !ok {
+		return
+	}
+	err := database.Ctx(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(prompt).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.ActionDelete, audit.EntityPrompt, fmt.Sprint(prompt.ID), prompt, nil)
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	cache.Invalidate(c)
 	c.JSON(http.StatusOK, gin.H{"message": "Deleted"})
 }
 
+// searchResult is a prompt matched by full-text search, with its bm25 rank and a highlighted snippet.
+type searchResult struct {
+	models.Prompt
+	Rank    float64 `json:"rank"`
+	Snippet string  `json:"snippet"`
+}
+
+// promptSorts whitelists the sort parameter of SearchPrompts.
+var promptSorts = map[string]pagination.Field{
+	"updatedAt": {Column: "updated_at"},
+	"createdAt": {Column: "created_at"},
+	"name":      {Column: "name"},
+	"id":        {Column: "id"},
+	"relevance": {},
+}
+
+func promptKey(p models.Prompt, sort string) (interface{}, uint) {
+	switch sort {
+	case "createdAt":
+		return p.CreatedAt, p.ID
+	case "name":
+		return p.Name, p.ID
+	case "id":
+		return p.ID, p.ID
+	}
+	return p.UpdatedAt, p.ID
+}
+
 func SearchPrompts(c *gin.Context) {
-	keyword := c.Query("q")
-	pageNum := c.DefaultQuery("pageNum", "1")
-	pageSize := c.DefaultQuery("pageSize", "20")
+	keyword := strings.TrimSpace(c.Query("q"))
+	tags := c.QueryArray("tag")
+	fullText := keyword != "" && database.FTSEnabled
 
-	var prompts []models.Prompt
-	query := database.DB.Model(&models.Prompt{})
+	defaultSort := "updatedAt"
+	if fullText {
+		defaultSort = "relevance"
+	}
+	page, err := pagination.Parse(c, promptSorts, defaultSort)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if page.Sort == "relevance" && !fullText {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Sorting by relevance needs a full-text query"})
+		return
+	}
 
-	if keyword != "" {
-		kw := "%" + strings.ToLower(keyword) + "%"
-		query = query.Where("LOWER(name) LIKE ? OR LOWER(description) LIKE ? OR LOWER(tags) LIKE ?", kw, kw, kw)
+	// results depend on who asks, since they only hold the prompts the caller can see
+	subject := currentSubject(c)
+	key := cacheKeyOf(subject) + ":" + c.Request.URL.Query().Encode()
+	body, err := cache.Fetch(c, "search", key, func() ([]byte, error) {
+		if fullText {
+			return searchFullText(c, subject, keyword, tags, page)
+		}
+		return searchPrompts(c, subject, keyword, tags, page)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
 	}
+	writeJSONWithETag(c, body)
+}
 
+// searchFullText returns the page of prompts matching keyword through the FTS5 index.
+func searchFullText(c *gin.Context, subject *access.Subject, keyword string, tags []string, page *pagination.Params) ([]byte, error) {
+	query := database.Ctx(c).Unscoped().Table("prompts_fts").
+		Joins("JOIN prompts ON prompts.id = prompts_fts.rowid").
+		Where("prompts_fts MATCH ? AND prompts.deleted_at IS NULL", database.FTSQuery(keyword))
+	query = database.WithTags(query, tags)
+	query = subject.Visible(query)
+
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, err
+	}
 
-	// Convert pageNum and pageSize to integers
-	var pageSizeValue int = 20
-	if s, err := strconv.Atoi(pageSize); err == nil && s > 0 {
-		pageSizeValue = s
+	query = query.Select("prompts.*, bm25(prompts_fts) AS rank, " +
+		"snippet(prompts_fts, -1, '<mark>', '</mark>', '…', 16) AS snippet")
+	if page.Sort == "relevance" {
+		query = query.Order("rank").Order("prompts.id")
 	}
 
-	var offset int
-	if p, err := strconv.Atoi(pageNum); err == nil && p > 0 {
-		offset = (p - 1) * pageSizeValue
+	var results []searchResult
+	if err := page.Apply(query, "prompts").Find(&results).Error; err != nil {
+		return nil, err
 	}
+	return json.Marshal(pagination.NewPage(page, results, total, func(r searchResult) (interface{}, uint) {
+		return promptKey(r.Prompt, page.Sort)
+	}))
+}
 
+// searchPrompts returns the page of prompts containing keyword, if any, anywhere.
+func searchPrompts(c *gin.Context, subject *access.Subject, keyword string, tags []string, page *pagination.Params) ([]byte, error) {
+	query := database.Ctx(c).Model(&models.Prompt{})
 
-	query = query.Order("updated_at desc").Limit(pageSizeValue).Offset(offset)
+	if keyword != "" {
+		kw := "%" + strings.ToLower(keyword) + "%"
+		query = query.Where("LOWER(name) LIKE ? OR LOWER(description) LIKE ? OR LOWER(tags) LIKE ? OR LOWER(system_prompt) LIKE ? OR LOWER(user_prompt) LIKE ?", kw, kw, kw, kw, kw)
+	}
+	query = database.WithTags(query, tags)
+	query = subject.Visible(query)
 
-	if err := query.Find(&prompts).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, err
 	}
 
-	c.JSON(http.StatusOK, prompts)
+	var prompts []models.Prompt
+	if err := page.Apply(query, "prompts").Find(&prompts).Error; err != nil {
+		return nil, err
+	}
+	return json.Marshal(pagination.NewPage(page, prompts, total, func(p models.Prompt) (interface{}, uint) {
+		return promptKey(p, page.Sort)
+	}))
 }
@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/walterfan/prompt-service/pkg/access"
+	"github.com/walterfan/prompt-service/pkg/audit"
+	"github.com/walterfan/prompt-service/pkg/cache"
+	"github.com/walterfan/prompt-service/pkg/database"
+	"github.com/walterfan/prompt-service/pkg/diff"
+	"github.com/walterfan/prompt-service/pkg/models"
+	"gorm.io/gorm"
+
+	"github.com/gin-gonic/gin"
+)
+
+// FieldDiff is the line diff of one prompt field between two revisions.
+type FieldDiff struct {
+	Field   string      `json:"field"`
+	Lines   []diff.Line `json:"lines"`
+	Unified string      `json:"unified"`
+}
+
+// versionDiff is the response of DiffPromptVersions; Changes lists only the changed fields.
+type versionDiff struct {
+	PromptID uint        `json:"promptId"`
+	From     int         `json:"from"`
+	To       int         `json:"to"`
+	Changes  []FieldDiff `json:"changes"`
+}
+
+// rollbackInput is the optional body of RollbackPrompt.
+type rollbackInput struct {
+	ChangeNote string `json:"changeNote"`
+}
+
+// findPromptVersion loads one revision of a prompt, writing the error response when it fails.
+func findPromptVersion(c *gin.Context, promptID uint, versionParam string) (*models.PromptVersion, bool) {
+	number, err := strconv.Atoi(versionParam)
+	if err != nil || number < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid version: " + versionParam})
+		return nil, false
+	}
+
+	var version models.PromptVersion
+	if err := database.Ctx(c).Where("prompt_id = ? AND version = ?", promptID, number).First(&version).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Prompt version not found"})
+		return nil, false
+	}
+	return &version, true
+}
+
+func ListPromptVersions(c *gin.Context) {
+	prompt, ok := loadPrompt(c, access.View)
+	if !ok {
+		return
+	}
+
+	var versions []models.PromptVersion
+	if err := database.Ctx(c).Where("prompt_id = ?", prompt.ID).Order("version desc").Find(&versions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, versions)
+}
+
+func GetPromptVersion(c *gin.Context) {
+	prompt, ok := loadPrompt(c, access.View)
+	if !ok {
+		return
+	}
+
+	version, ok := findPromptVersion(c, prompt.ID, c.Param("version"))
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, version)
+}
+
+// DiffPromptVersions compares two revisions: GET /:id/versions/diff?from=1&to=2.
+// "to" defaults to the current revision.
+func DiffPromptVersions(c *gin.Context) {
+	prompt, ok := loadPrompt(c, access.View)
+	if !ok {
+		return
+	}
+
+	from, ok := findPromptVersion(c, prompt.ID, c.Query("from"))
+	if !ok {
+		return
+	}
+	to, ok := findPromptVersion(c, prompt.ID, c.DefaultQuery("to", strconv.Itoa(prompt.Version)))
+	if !ok {
+		return
+	}
+
+	fields := []struct {
+		name     string
+		from, to string
+	}{
+		{"name", from.Name, to.Name},
+		{"desc", from.Description, to.Description},
+		{"systemPrompt", from.SystemPrompt, to.SystemPrompt},
+		{"userPrompt", from.UserPrompt, to.UserPrompt},
+		{"extends", from.Extends, to.Extends},
+		{"tags", from.Tags, to.Tags},
+	}
+
+	changes := []FieldDiff{}
+	for _, f := range fields {
+		lines := diff.Lines(f.from, f.to)
+		if diff.Changed(lines) {
+			changes = append(changes, FieldDiff{Field: f.name, Lines: lines, Unified: diff.Unified(lines)})
+		}
+	}
+
+	c.JSON(http.StatusOK, versionDiff{
+		PromptID: prompt.ID,
+		From:     from.Version,
+		To:       to.Version,
+		Changes:  changes,
+	})
+}
+
+// RollbackPrompt restores the content of an earlier revision as a new revision,
+// so the history stays append-only.
+func RollbackPrompt(c *gin.Context) {
+	prompt, ok := loadPrompt(c, access.Edit)
+	if !ok {
+		return
+	}
+
+	target, ok := findPromptVersion(c, prompt.ID, c.Param("version"))
+	if !ok {
+		return
+	}
+
+	var input rollbackInput
+	// the body is optional
+	_ = c.ShouldBindJSON(&input)
+	if input.ChangeNote == "" {
+		input.ChangeNote = fmt.Sprintf("rollback to version %d", target.Version)
+	}
+
+	before := *prompt
+	err := database.Ctx(c).Transaction(func(tx *gorm.DB) error {
+		restored := target.AsPrompt(prompt)
+		restored.Version = prompt.Version + 1
+		err := updateRevision(tx.Select("name", "description", "system_prompt", "user_prompt", "extends", "tags", "variables", "version"),
+			prompt, &restored)
+		if err != nil {
+			return err
+		}
+		if err := database.SyncPromptTags(tx, prompt); err != nil {
+			return err
+		}
+		version := models.NewPromptVersion(prompt, currentUser(c), input.ChangeNote)
+		if err := tx.Create(&version).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.ActionUpdate, audit.EntityPrompt, fmt.Sprint(prompt.ID), before, prompt)
+	})
+	if err != nil {
+		writeRevisionError(c, err)
+		return
+	}
+	cache.Invalidate(c)
+	c.JSON(http.StatusOK, prompt)
+}
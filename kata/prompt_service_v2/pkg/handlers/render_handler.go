@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/walterfan/prompt-service/pkg/access"
+	"github.com/walterfan/prompt-service/pkg/database"
+	"github.com/walterfan/prompt-service/pkg/models"
+	"github.com/walterfan/prompt-service/pkg/render"
+
+	"github.com/gin-gonic/gin"
+)
+
+type renderRequest struct {
+	Variables map[string]interface{} `json:"variables"`
+	Version   int                    `json:"version"`
+}
+
+// renderResponse is the rendered prompt with the variables after defaults were applied.
+type renderResponse struct {
+	PromptID  uint               `json:"promptId"`
+	Version   int                `json:"version"`
+	Messages  []render.Message   `json:"messages"`
+	Variables map[string]string  `json:"variables"`
+	Uses      []render.Reference `json:"uses"`
+}
+
+// expandedPrompt is the response of ExpandPrompt.
+type expandedPrompt struct {
+	PromptID     uint                    `json:"promptId"`
+	Version      int                     `json:"version"`
+	Extends      string                  `json:"extends"`
+	SystemPrompt string                  `json:"systemPrompt"`
+	UserPrompt   string                  `json:"userPrompt"`
+	Variables    []models.PromptVariable `json:"variables"`
+	Uses         []render.Reference      `json:"uses"`
+}
+
+// promptResolver finds the partials and bases of a prompt among the prompts the
+// caller can view, the oldest one when several share a name.
+type promptResolver struct {
+	c *gin.Context
+}
+
+func (r promptResolver) Resolve(name string, version int) (*models.Prompt, error) {
+	var matches []models.Prompt
+	query := currentSubject(r.c).Visible(database.Ctx(r.c).Where("name = ?", name))
+	if err := query.Order("id").Limit(1).Find(&matches).Error; err != nil {
+		return nil, err
+	}
+	if len(matches) == 0 {
+		return nil, errors.New("prompt not found")
+	}
+	prompt := &matches[0]
+	if version == 0 || version == prompt.Version {
+		return prompt, nil
+	}
+
+	var pinned models.PromptVersion
+	if err := database.Ctx(r.c).Where("prompt_id = ? AND version = ?", prompt.ID, version).First(&pinned).Error; err != nil {
+		return nil, fmt.Errorf("version %d not found", version)
+	}
+	p := pinned.AsPrompt(prompt)
+	return &p, nil
+}
+
+// expandPrompt applies the prompt's base and partials, writing the error response when it fails.
+func expandPrompt(c *gin.Context, prompt *models.Prompt) (*models.Prompt, []render.Reference, bool) {
+	expanded, uses, err := render.Expand(prompt, promptResolver{c})
+	if err != nil {
+		writeRenderError(c, err)
+		return nil, nil, false
+	}
+	return expanded, uses, true
+}
+
+// loadPromptForRender loads the prompt, or the pinned revision of it, writing the error response when it fails.
+func loadPromptForRender(c *gin.Context, version int) (*models.Prompt, bool) {
+	prompt, ok := loadPrompt(c, access.View)
+	if !ok {
+		return nil, false
+	}
+
+	if version > 0 && version != prompt.Version {
+		pinned, ok := findPromptVersion(c, prompt.ID, strconv.Itoa(version))
+		if !ok {
+			return nil, false
+		}
+		p := pinned.AsPrompt(prompt)
+		return &p, true
+	}
+	return prompt, true
+}
+
+// writeRenderError maps render errors to a structured 422 response.
+func writeRenderError(c *gin.Context, err error) {
+	var verr *render.ValidationError
+	if errors.As(err, &verr) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Invalid variables", "details": verr.Errors})
+		return
+	}
+	var cerr *render.CompositionError
+	if errors.As(err, &cerr) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Invalid composition: " + cerr.Message, "path": cerr.Path})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
+
+func RenderPrompt(c *gin.Context) {
+	var req renderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	prompt, ok := loadPromptForRender(c, req.Version)
+	if !ok {
+		return
+	}
+	expanded, uses, ok := expandPrompt(c, prompt)
+	if !ok {
+		return
+	}
+
+	result, err := render.Render(expanded, req.Variables)
+	if err != nil {
+		writeRenderError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, renderResponse{
+		PromptID:  prompt.ID,
+		Version:   prompt.Version,
+		Messages:  result.Messages,
+		Variables: result.Variables,
+		Uses:      uses,
+	})
+}
+
+// ExpandPrompt previews the prompt, or its ?version=, with its base applied and
+// partials inserted, along with the revisions of them it used.
+func ExpandPrompt(c *gin.Context) {
+	version := 0
+	if v := c.Query("version"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid version: " + v})
+			return
+		}
+		version = n
+	}
+
+	prompt, ok := loadPromptForRender(c, version)
+	if !ok {
+		return
+	}
+	expanded, uses, ok := expandPrompt(c, prompt)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, expandedPrompt{
+		PromptID:     prompt.ID,
+		Version:      prompt.Version,
+		Extends:      prompt.Extends,
+		SystemPrompt: expanded.SystemPrompt,
+		UserPrompt:   expanded.UserPrompt,
+		Variables:    render.Declarations(expanded),
+		Uses:         uses,
+	})
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/walterfan/prompt-service/internal/log"
+	"github.com/walterfan/prompt-service/pkg/access"
+	"github.com/walterfan/prompt-service/pkg/database"
+	"github.com/walterfan/prompt-service/pkg/llm"
+	"github.com/walterfan/prompt-service/pkg/models"
+	"github.com/walterfan/prompt-service/pkg/render"
+	"go.uber.org/zap"
+
+	"github.com/gin-gonic/gin"
+)
+
+type runRequest struct {
+	Variables   map[string]interface{} `json:"variables"`
+	Version     int                    `json:"version"`
+	Model       string                 `json:"model"`
+	Temperature *float64               `json:"temperature"`
+	MaxTokens   int                    `json:"maxTokens"`
+}
+
+// RunPrompt renders the prompt, sends it to the configured LLM and streams the
+// answer back as server-sent events: "token" for every chunk, then "done" or "error".
+func RunPrompt(c *gin.Context) {
+	var req runRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if llm.Client == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": llm.ErrNotConfigured.Error()})
+		return
+	}
+
+	model := req.Model
+	if model == "" {
+		model = llm.DefaultModel
+	}
+	if model == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Model is required"})
+		return
+	}
+
+	prompt, ok := loadPromptForRender(c, req.Version)
+	if !ok {
+		return
+	}
+	expanded, _, ok := expandPrompt(c, prompt)
+	if !ok {
+		return
+	}
+
+	rendered, err := render.Render(expanded, req.Variables)
+	if err != nil {
+		writeRenderError(c, err)
+		return
+	}
+
+	chatReq := llm.ChatRequest{Model: model, Temperature: req.Temperature, MaxTokens: req.MaxTokens}
+	for _, m := range rendered.Messages {
+		chatReq.Messages = append(chatReq.Messages, llm.Message{Role: m.Role, Content: m.Content})
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	run := models.PromptRun{
+		PromptID: prompt.ID,
+		Version:  prompt.Version,
+		Model:    model,
+		Caller:   currentUser(c),
+	}
+
+	start := time.Now()
+	resp, err := llm.Client.ChatStream(c.Request.Context(), chatReq, func(token string) error {
+		c.SSEvent("token", gin.H{"content": token})
+		c.Writer.Flush()
+		return c.Request.Context().Err()
+	})
+	run.LatencyMs = time.Since(start).Milliseconds()
+
+	if err != nil {
+		run.Status = "error"
+		run.Error = err.Error()
+	} else {
+		run.Status = "success"
+		run.Model = resp.Model
+		run.PromptTokens = resp.Usage.PromptTokens
+		run.CompletionTokens = resp.Usage.CompletionTokens
+		run.TotalTokens = resp.Usage.TotalTokens
+	}
+
+	if dbErr := database.Ctx(c).Create(&run).Error; dbErr != nil {
+		log.Ctx(c).Error("Failed to record prompt run", zap.Uint("prompt_id", prompt.ID), zap.Error(dbErr))
+	}
+
+	if err != nil {
+		c.SSEvent("error", gin.H{"error": err.Error(), "run": run})
+	} else {
+		c.SSEvent("done", gin.H{"content": resp.Content, "run": run})
+	}
+	c.Writer.Flush()
+}
+
+func ListPromptRuns(c *gin.Context) {
+	prompt, ok := loadPrompt(c, access.View)
+	if !ok {
+		return
+	}
+
+	var runs []models.PromptRun
+	if err := database.Ctx(c).Where("prompt_id = ?", prompt.ID).Order("id desc").Limit(100).Find(&runs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, runs)
+}
@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/walterfan/prompt-service/pkg/access"
+	"github.com/walterfan/prompt-service/pkg/audit"
+	"github.com/walterfan/prompt-service/pkg/cache"
+	"github.com/walterfan/prompt-service/pkg/database"
+	"github.com/walterfan/prompt-service/pkg/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/gin-gonic/gin"
+)
+
+// shareInput names exactly one of user or team.
+type shareInput struct {
+	User string `json:"user"`
+	Team string `json:"team"`
+	Role string `json:"role" binding:"required"`
+}
+
+func ListPromptShares(c *gin.Context) {
+	prompt, ok := loadPrompt(c, access.Own)
+	if !ok {
+		return
+	}
+
+	var shares []models.PromptShare
+	if err := database.Ctx(c).Where("prompt_id = ?", prompt.ID).Order("id").Find(&shares).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, shares)
+}
+
+// SharePrompt grants a user or team access: POST /:id/shares {"user": "bob", "role": "editor"}.
+// Sharing again with the same grantee changes the role.
+func SharePrompt(c *gin.Context) {
+	prompt, ok := loadPrompt(c, access.Own)
+	if !ok {
+		return
+	}
+
+	var input shareInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.Role != models.ShareViewer && input.Role != models.ShareEditor {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role: " + input.Role})
+		return
+	}
+
+	share := models.PromptShare{PromptID: prompt.ID, Role: input.Role, CreatedBy: currentUser(c)}
+	user, team := strings.TrimSpace(input.User), strings.TrimSpace(input.Team)
+	switch {
+	case user != "" && team == "":
+		var count int64
+		database.Ctx(c).Model(&models.User{}).Where("username = ?", user).Count(&count)
+		if count == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown user: " + user})
+			return
+		}
+		share.GranteeType, share.Grantee = models.GranteeUser, user
+	case team != "" && user == "":
+		share.GranteeType, share.Grantee = models.GranteeTeam, team
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Give either user or team"})
+		return
+	}
+
+	err := database.Ctx(c).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "prompt_id"}, {Name: "grantee_type"}, {Name: "grantee"}},
+			DoUpdates: clause.AssignmentColumns([]string{"role", "created_by"}),
+		}).Create(&share).Error
+		if err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.ActionCreate, audit.EntityPromptShare, fmt.Sprint(share.ID), nil, share)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	cache.Invalidate(c)
+	c.JSON(http.StatusCreated, share)
+}
+
+func UnsharePrompt(c *gin.Context) {
+	prompt, ok := loadPrompt(c, access.Own)
+	if !ok {
+		return
+	}
+
+	var share models.PromptShare
+	if err := database.Ctx(c).Where("id = ? AND prompt_id = ?", c.Param("shareId"), prompt.ID).First(&share).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Share not found"})
+		return
+	}
+	err := database.Ctx(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&share).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.ActionDelete, audit.EntityPromptShare, fmt.Sprint(share.ID), share, nil)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	cache.Invalidate(c)
+	c.JSON(http.StatusOK, gin.H{"message": "Share deleted"})
+}
+
+type transferInput struct {
+	Owner string `json:"owner" binding:"required"`
+}
+
+// TransferPrompt hands a prompt to another user: POST /:id/transfer {"owner": "bob"}.
+func TransferPrompt(c *gin.Context) {
+	prompt, ok := loadPrompt(c, access.Own)
+	if !ok {
+		return
+	}
+
+	var input transferInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var count int64
+	database.Ctx(c).Model(&models.User{}).Where("username = ?", input.Owner).Count(&count)
+	if count == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown user: " + input.Owner})
+		return
+	}
+
+	before := *prompt
+	err := database.Ctx(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(prompt).UpdateColumn("owner", input.Owner).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.ActionUpdate, audit.EntityPrompt, fmt.Sprint(prompt.ID), before, prompt)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	cache.Invalidate(c)
+	c.JSON(http.StatusOK, prompt)
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/walterfan/prompt-service/pkg/access"
+	"github.com/walterfan/prompt-service/pkg/audit"
+	"github.com/walterfan/prompt-service/pkg/cache"
+	"github.com/walterfan/prompt-service/pkg/database"
+	"github.com/walterfan/prompt-service/pkg/models"
+	"gorm.io/gorm"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListTags lists the tags of the prompts the caller can view: GET /api/v1/tags/
+func ListTags(c *gin.Context) {
+	visible := currentSubject(c).Visible(database.Ctx(c).Model(&models.Prompt{}).Select("prompts.id"))
+	counts, err := database.TagCounts(database.Ctx(c), visible)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, counts)
+}
+
+// RenameTag renames a tag on the prompts the caller can edit: PUT /api/v1/tags/:name
+// {"name": "new-name"}. Like any other edit, every changed prompt gets a new revision
+// and an audit entry; the prompts the caller can't edit keep the old tag.
+func RenameTag(c *gin.Context) {
+	oldName := c.Param("name")
+	var input struct {
+		Name string `json:"name" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	newName := strings.TrimSpace(input.Name)
+	if newName == "" || strings.Contains(newName, ",") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tag name"})
+		return
+	}
+
+	subject := currentSubject(c)
+	var prompts []models.Prompt
+	query := database.Ctx(c).Where("prompts.id IN (?)", database.TaggedPromptIDs(database.Ctx(c), oldName))
+	if err := subject.Visible(query).Order("id").Find(&prompts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if len(prompts) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tag not found"})
+		return
+	}
+
+	var editable, before []models.Prompt
+	for i := range prompts {
+		if subject.PromptLevel(&prompts[i]) >= access.Edit {
+			editable = append(editable, prompts[i])
+			before = append(before, prompts[i])
+		}
+	}
+	if len(editable) == 0 {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	note := fmt.Sprintf("rename tag %s to %s", oldName, newName)
+	err := database.Ctx(c).Transaction(func(tx *gorm.DB) error {
+		for i := range editable {
+			prompt := &editable[i]
+			names := models.ParseTags(prompt.Tags)
+			for j, name := range names {
+				if name == oldName {
+					names[j] = newName
+				}
+			}
+			changes := models.Prompt{Tags: strings.Join(names, ","), Version: prompt.Version + 1}
+			if err := updateRevision(tx, prompt, changes); err != nil {
+				return err
+			}
+			if err := database.SyncPromptTags(tx, prompt); err != nil {
+				return err
+			}
+			version := models.NewPromptVersion(prompt, currentUser(c), note)
+			if err := tx.Create(&version).Error; err != nil {
+				return err
+			}
+			if err := audit.Record(tx, audit.ActionUpdate, audit.EntityPrompt, fmt.Sprint(prompt.ID), before[i], prompt); err != nil {
+				return err
+			}
+		}
+		if err := database.DeleteUnusedTag(tx, oldName); err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.ActionUpdate, audit.EntityTag, oldName,
+			gin.H{"name": oldName}, gin.H{"name": newName, "prompts": len(editable)})
+	})
+	if err != nil {
+		writeRevisionError(c, err)
+		return
+	}
+	cache.Invalidate(c)
+	c.JSON(http.StatusOK, gin.H{"name": newName, "prompts": len(editable), "skipped": len(prompts) - len(editable)})
+}
@@ -1,24 +1,82 @@
 package handlers
 
 import (
+	"fmt"
 	"net/http"
-	"strconv"
 	"strings"
+	"time"
 
+	"github.com/walterfan/prompt-service/pkg/audit"
+	"github.com/walterfan/prompt-service/pkg/auth"
+	"github.com/walterfan/prompt-service/pkg/authz"
+	"github.com/walterfan/prompt-service/pkg/cache"
 	"github.com/walterfan/prompt-service/pkg/database"
 	"github.com/walterfan/prompt-service/pkg/models"
+	"github.com/walterfan/prompt-service/pkg/pagination"
+	"gorm.io/gorm"
 
 	"github.com/gin-gonic/gin"
 )
 
+// userInput is the body of the admin user endpoints. The password is hashed under the
+// password policy; models.User never carries it in JSON.
+type userInput struct {
+	Username  string     `json:"username"`
+	Password  string     `json:"password"`
+	Email     string     `json:"email"`
+	Role      string     `json:"role"`
+	Team      string     `json:"team"`
+	ExpiredAt *time.Time `json:"expired_at"`
+}
+
 func CreateUser(c *gin.Context) {
-	var user models.User
-	if err := c.ShouldBindJSON(&user); err != nil {
+	var input userInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	input.Username = strings.TrimSpace(input.Username)
+	if input.Username == "" || input.Email == "" || input.Password == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "username, email and password are required"})
+		return
+	}
+	if err := auth.ValidateUsername(input.Username); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if input.Role != "" && !authz.IsRole(input.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown role: " + input.Role})
+		return
+	}
+
+	hash, err := auth.HashPassword(input.Password, input.Username)
+	if err != nil {
+		auth.WritePasswordError(c, err)
+		return
+	}
+	user := models.User{
+		Username:  input.Username,
+		Password:  hash,
+		Email:     input.Email,
+		Role:      input.Role,
+		Team:      input.Team,
+		ExpiredAt: time.Now().Add(auth.AccountTTL),
+	}
+	if input.ExpiredAt != nil {
+		user.ExpiredAt = *input.ExpiredAt
+	}
 
-	if err := database.DB.Create(&user).Error; err != nil {
+	err = database.Ctx(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.ActionCreate, audit.EntityUser, fmt.Sprint(user.ID), nil, user)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := authz.SetUserRole(user.Username, user.Role); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -28,7 +86,7 @@ func CreateUser(c *gin.Context) {
 func GetUser(c *gin.Context) {
 	id := c.Param("id")
 	var user models.User
-	if err := database.DB.First(&user, id).Error; err != nil {
+	if err := database.Ctx(c).First(&user, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 		return
 	}
@@ -38,60 +96,213 @@ func GetUser(c *gin.Context) {
 func UpdateUser(c *gin.Context) {
 	id := c.Param("id")
 	var user models.User
-	if err := database.DB.First(&user, id).Error; err != nil {
+	if err := database.Ctx(c).First(&user, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	var input userInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// an empty username keeps the current one, a blank one is rejected
+	if input.Username != "" {
+		input.Username = strings.TrimSpace(input.Username)
+		if err := auth.ValidateUsername(input.Username); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if input.Role != "" && !authz.IsRole(input.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown role: " + input.Role})
+		return
+	}
+
+	changes := models.User{
+		Username: input.Username,
+		Email:    input.Email,
+		Role:     input.Role,
+		Team:     input.Team,
+	}
+	if input.ExpiredAt != nil {
+		changes.ExpiredAt = *input.ExpiredAt
+	}
+	if input.Password != "" {
+		username := input.Username
+		if username == "" {
+			username = user.Username
+		}
+		hash, err := auth.HashPassword(input.Password, username)
+		if err != nil {
+			auth.WritePasswordError(c, err)
+			return
+		}
+		changes.Password = hash
+	}
+
+	before := user
+	oldUsername := user.Username
+	err := database.Ctx(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Updates(changes).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.ActionUpdate, audit.EntityUser, fmt.Sprint(user.ID), before, user)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if user.Username != oldUsername {
+		if err := authz.RemoveUser(oldUsername); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if err := authz.SetUserRole(user.Username, user.Role); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	// the team decides which team-visible prompts the user and their team see
+	cache.Invalidate(c)
+	c.JSON(http.StatusOK, user)
+}
+
+// expiryInput sets either ExpiredAt or ExtendBy, a Go duration such as "720h".
+type expiryInput struct {
+	ExpiredAt *time.Time `json:"expiredAt"`
+	ExtendBy  string     `json:"extendBy"`
+}
+
+// ExtendUserExpiry renews an account: POST /api/v1/users/:id/expiry with either
+// {"expiredAt": "2027-01-01T00:00:00Z"} or {"extendBy": "720h"}, which counts from
+// the current expiry or from now, whichever is later.
+func ExtendUserExpiry(c *gin.Context) {
+	id := c.Param("id")
+	var user models.User
+	if err := database.Ctx(c).First(&user, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 		return
 	}
 
-	var input models.User
+	var input expiryInput
 	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	database.DB.Model(&user).Updates(input)
+	var expiredAt time.Time
+	switch {
+	case input.ExpiredAt != nil && input.ExtendBy == "":
+		expiredAt = *input.ExpiredAt
+	case input.ExtendBy != "" && input.ExpiredAt == nil:
+		d, err := time.ParseDuration(input.ExtendBy)
+		if err != nil || d <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid extendBy: " + input.ExtendBy})
+			return
+		}
+		from := user.ExpiredAt
+		if from.Before(time.Now()) {
+			from = time.Now()
+		}
+		expiredAt = from.Add(d)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Give either expiredAt or extendBy"})
+		return
+	}
+
+	before := user
+	err := database.Ctx(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Update("expired_at", expiredAt).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.ActionUpdate, audit.EntityUser, fmt.Sprint(user.ID), before, user)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 	c.JSON(http.StatusOK, user)
 }
 
 func DeleteUser(c *gin.Context) {
 	id := c.Param("id")
-	if err := database.DB.Delete(&models.User{}, id).Error; err != nil {
+	var user models.User
+	if err := database.Ctx(c).First(&user, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	err := database.Ctx(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&user).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, audit.ActionDelete, audit.EntityUser, fmt.Sprint(user.ID), user, nil)
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	if err := authz.RemoveUser(user.Username); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	cache.Invalidate(c)
 	c.JSON(http.StatusOK, gin.H{"message": "User deleted"})
 }
 
+// userSorts whitelists the sort parameter of SearchUsers.
+var userSorts = map[string]pagination.Field{
+	"updatedAt": {Column: "updated_at", Time: true},
+	"createdAt": {Column: "created_at", Time: true},
+	"username":  {Column: "username"},
+	"email":     {Column: "email"},
+	"id":        {Column: "id"},
+}
+
+func userKey(u models.User, sort string) (interface{}, uint) {
+	switch sort {
+	case "createdAt":
+		return u.CreatedAt, u.ID
+	case "username":
+		return u.Username, u.ID
+	case "email":
+		return u.Email, u.ID
+	case "id":
+		return u.ID, u.ID
+	}
+	return u.UpdatedAt, u.ID
+}
+
 func SearchUsers(c *gin.Context) {
 	keyword := c.Query("q")
-	pageNum := c.DefaultQuery("pageNum", "1")
-	pageSize := c.DefaultQuery("pageSize", "20")
 
-	var users []models.User
-	query := database.DB.Model(&models.User{})
+	page, err := pagination.Parse(c, userSorts, "updatedAt")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	query := database.Ctx(c).Model(&models.User{})
 
 	if keyword != "" {
 		kw := "%" + strings.ToLower(keyword) + "%"
 		query = query.Where("LOWER(username) LIKE ? OR LOWER(email) LIKE ?", kw, kw)
 	}
 
-	// Pagination
-	var pageSizeValue int = 20
-	if s, err := strconv.Atoi(pageSize); err == nil && s > 0 {
-		pageSizeValue = s
-	}
-
-	var offset int
-	if p, err := strconv.Atoi(pageNum); err == nil && p > 0 {
-		offset = (p - 1) * pageSizeValue
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
 	}
 
-	query = query.Order("updated_at desc").Limit(pageSizeValue).Offset(offset)
-
-	if err := query.Find(&users).Error; err != nil {
+	var users []models.User
+	if err := page.Apply(query, "users").Find(&users).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, users)
-}
\ No newline at end of file
+	c.JSON(http.StatusOK, pagination.NewPage(page, users, total, func(u models.User) (interface{}, uint) {
+		return userKey(u, page.Sort)
+	}))
+}
@@ -0,0 +1,191 @@
+// pkg/library/format.go
+package library
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/walterfan/prompt-service/pkg/models"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	FormatYAML = "yaml"
+	FormatJSON = "json"
+	FormatCSV  = "csv"
+)
+
+// Entry is the portable form of a prompt: no ids, versions or timestamps, so a
+// library can move between environments and be matched by name.
+type Entry struct {
+	Name         string                  `json:"name" yaml:"name"`
+	Description  string                  `json:"desc,omitempty" yaml:"description,omitempty"`
+	SystemPrompt string                  `json:"systemPrompt,omitempty" yaml:"system_prompt,omitempty"`
+	UserPrompt   string                  `json:"userPrompt,omitempty" yaml:"user_prompt,omitempty"`
+	Extends      string                  `json:"extends,omitempty" yaml:"extends,omitempty"`
+	Tags         string                  `json:"tags,omitempty" yaml:"tags,omitempty"`
+	Variables    []models.PromptVariable `json:"variables,omitempty" yaml:"variables,omitempty"`
+}
+
+// file is the document written by the YAML and JSON encoders, shaped like the
+// prompts key of config.yaml.
+type file struct {
+	Prompts []Entry `json:"prompts" yaml:"prompts"`
+}
+
+var csvHeader = []string{"name", "description", "system_prompt", "user_prompt", "tags", "variables", "extends"}
+
+func FromPrompt(p *models.Prompt) Entry {
+	return Entry{
+		Name:         p.Name,
+		Description:  p.Description,
+		SystemPrompt: p.SystemPrompt,
+		UserPrompt:   p.UserPrompt,
+		Extends:      p.Extends,
+		Tags:         p.Tags,
+		Variables:    p.Variables,
+	}
+}
+
+// ParseFormat validates a format name; "yml" is accepted for YAML.
+func ParseFormat(format string) (string, error) {
+	switch f := strings.ToLower(strings.TrimPrefix(format, ".")); f {
+	case FormatYAML, "yml":
+		return FormatYAML, nil
+	case FormatJSON, FormatCSV:
+		return f, nil
+	}
+	return "", fmt.Errorf("unsupported format %q, expected yaml, json or csv", format)
+}
+
+// FormatOf guesses the format from a file name, defaulting to YAML.
+func FormatOf(filename string) string {
+	if f, err := ParseFormat(filepath.Ext(filename)); err == nil {
+		return f
+	}
+	return FormatYAML
+}
+
+func ContentType(format string) string {
+	switch format {
+	case FormatJSON:
+		return "application/json"
+	case FormatCSV:
+		return "text/csv"
+	}
+	return "application/yaml"
+}
+
+func Encode(w io.Writer, format string, entries []Entry) error {
+	switch format {
+	case FormatYAML:
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(file{Prompts: entries}); err != nil {
+			return err
+		}
+		return enc.Close()
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(file{Prompts: entries})
+	case FormatCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(csvHeader); err != nil {
+			return err
+		}
+		for _, e := range entries {
+			vars := ""
+			if len(e.Variables) > 0 {
+				data, err := json.Marshal(e.Variables)
+				if err != nil {
+					return err
+				}
+				vars = string(data)
+			}
+			if err := cw.Write([]string{e.Name, e.Description, e.SystemPrompt, e.UserPrompt, e.Tags, vars, e.Extends}); err != nil {
+				return err
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+	}
+	return fmt.Errorf("unsupported format %q", format)
+}
+
+// Decode reads a library. YAML and JSON accept either {"prompts": [...]} or a bare list.
+func Decode(r io.Reader, format string) ([]Entry, error) {
+	switch format {
+	case FormatYAML, FormatJSON:
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		unmarshal := yaml.Unmarshal
+		if format == FormatJSON {
+			unmarshal = json.Unmarshal
+		}
+
+		var doc file
+		if err := unmarshal(data, &doc); err == nil && doc.Prompts != nil {
+			return doc.Prompts, nil
+		}
+		var entries []Entry
+		if err := unmarshal(data, &entries); err != nil {
+			return nil, fmt.Errorf("invalid %s library: %w", format, err)
+		}
+		return entries, nil
+	case FormatCSV:
+		return decodeCSV(r)
+	}
+	return nil, fmt.Errorf("unsupported format %q", format)
+}
+
+func decodeCSV(r io.Reader) ([]Entry, error) {
+	cr := csv.NewReader(r)
+	rows, err := cr.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("invalid csv library: %w", err)
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+
+	columns := map[string]int{}
+	for i, name := range rows[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["name"]; !ok {
+		return nil, fmt.Errorf("invalid csv library: missing name column")
+	}
+
+	get := func(row []string, column string) string {
+		if i, ok := columns[column]; ok && i < len(row) {
+			return row[i]
+		}
+		return ""
+	}
+
+	entries := make([]Entry, 0, len(rows)-1)
+	for n, row := range rows[1:] {
+		e := Entry{
+			Name:         get(row, "name"),
+			Description:  get(row, "description"),
+			SystemPrompt: get(row, "system_prompt"),
+			UserPrompt:   get(row, "user_prompt"),
+			Extends:      get(row, "extends"),
+			Tags:         get(row, "tags"),
+		}
+		if vars := get(row, "variables"); vars != "" {
+			if err := json.Unmarshal([]byte(vars), &e.Variables); err != nil {
+				return nil, fmt.Errorf("invalid variables on csv line %d: %w", n+2, err)
+			}
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
@@ -0,0 +1,200 @@
+// pkg/library/library.go
+package library
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/walterfan/prompt-service/pkg/access"
+	"github.com/walterfan/prompt-service/pkg/audit"
+	"github.com/walterfan/prompt-service/pkg/database"
+	"github.com/walterfan/prompt-service/pkg/models"
+	"github.com/walterfan/prompt-service/pkg/render"
+	"gorm.io/gorm"
+)
+
+// Report lists by name what an import created, updated or left untouched.
+type Report struct {
+	DryRun  bool         `json:"dryRun"`
+	Created []string     `json:"created"`
+	Updated []string     `json:"updated"`
+	Skipped []string     `json:"skipped"`
+	Errors  []EntryError `json:"errors,omitempty"`
+}
+
+// EntryError is an entry that was rejected by validation.
+type EntryError struct {
+	Name  string `json:"name"`
+	Error string `json:"error"`
+}
+
+// ErrInvalidEntries is returned by Import when any entry is invalid; nothing is written.
+var ErrInvalidEntries = errors.New("library contains invalid entries")
+
+// Export returns every live prompt visible to as, or every prompt when as is nil,
+// optionally only those carrying all the given tags.
+func Export(ctx context.Context, as *access.Subject, tags []string) ([]Entry, error) {
+	var prompts []models.Prompt
+	query := database.WithTags(database.Ctx(ctx).Model(&models.Prompt{}), tags)
+	if as != nil {
+		query = as.Visible(query)
+	}
+	if err := query.Order("name").Find(&prompts).Error; err != nil {
+		return nil, err
+	}
+
+	entries := make([]Entry, 0, len(prompts))
+	for i := range prompts {
+		entries = append(entries, FromPrompt(&prompts[i]))
+	}
+	return entries, nil
+}
+
+// Import upserts entries by prompt name in a single transaction. Updated prompts get a
+// new revision authored by author. When as is given, only prompts visible to it are
+// matched, updating needs edit access and new prompts are private to it; otherwise new
+// prompts are public and unowned. Every created or updated prompt gets an audit entry
+// naming the actor of ctx, see audit.WithActor. With dryRun nothing is written.
+func Import(ctx context.Context, entries []Entry, as *access.Subject, author string, dryRun bool) (*Report, error) {
+	report := &Report{DryRun: dryRun, Created: []string{}, Updated: []string{}, Skipped: []string{}}
+
+	seen := map[string]bool{}
+	for i := range entries {
+		e := &entries[i]
+		e.Name = strings.TrimSpace(e.Name)
+		e.Tags = strings.Join(models.ParseTags(e.Tags), ",")
+		switch {
+		case e.Name == "":
+			report.Errors = append(report.Errors, EntryError{Name: e.Name, Error: "name is required"})
+		case seen[e.Name]:
+			report.Errors = append(report.Errors, EntryError{Name: e.Name, Error: "duplicate name"})
+		default:
+			if err := render.ValidateDefinitions(e.Variables); err != nil {
+				report.Errors = append(report.Errors, EntryError{Name: e.Name, Error: err.Error()})
+			}
+		}
+		seen[e.Name] = true
+	}
+	if len(report.Errors) > 0 {
+		return report, ErrInvalidEntries
+	}
+
+	err := database.Ctx(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, e := range entries {
+			var matches []models.Prompt
+			query := tx.Where("name = ?", e.Name)
+			if as != nil {
+				query = as.Visible(query)
+			}
+			if err := query.Order("id").Limit(1).Find(&matches).Error; err != nil {
+				return err
+			}
+			if len(matches) == 0 {
+				report.Created = append(report.Created, e.Name)
+				if dryRun {
+					continue
+				}
+				if err := create(tx, e, as, author); err != nil {
+					return err
+				}
+				continue
+			}
+
+			existing := matches[0]
+			if as != nil && as.PromptLevel(&existing) < access.Edit {
+				report.Errors = append(report.Errors, EntryError{Name: e.Name, Error: "not allowed to edit this prompt"})
+				continue
+			}
+			if reflect.DeepEqual(normalize(FromPrompt(&existing)), normalize(e)) {
+				report.Skipped = append(report.Skipped, e.Name)
+				continue
+			}
+			report.Updated = append(report.Updated, e.Name)
+			if dryRun {
+				continue
+			}
+			if err := update(tx, &existing, e, author); err != nil {
+				return err
+			}
+		}
+		if len(report.Errors) > 0 {
+			return ErrInvalidEntries
+		}
+		return nil
+	})
+	if errors.Is(err, ErrInvalidEntries) {
+		return report, err
+	}
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+func create(tx *gorm.DB, e Entry, as *access.Subject, author string) error {
+	prompt := models.Prompt{
+		Visibility:   models.VisibilityPublic,
+		Name:         e.Name,
+		Description:  e.Description,
+		SystemPrompt: e.SystemPrompt,
+		UserPrompt:   e.UserPrompt,
+		Extends:      e.Extends,
+		Tags:         e.Tags,
+		Variables:    e.Variables,
+		Version:      1,
+	}
+	if as != nil {
+		prompt.Owner, prompt.Visibility = as.Username, models.VisibilityPrivate
+	}
+	if err := tx.Create(&prompt).Error; err != nil {
+		return err
+	}
+	if err := database.SyncPromptTags(tx, &prompt); err != nil {
+		return err
+	}
+	version := models.NewPromptVersion(&prompt, author, "imported")
+	if err := tx.Create(&version).Error; err != nil {
+		return err
+	}
+	return audit.Record(tx, audit.ActionCreate, audit.EntityPrompt, fmt.Sprint(prompt.ID), nil, prompt)
+}
+
+func update(tx *gorm.DB, prompt *models.Prompt, e Entry, author string) error {
+	changes := models.Prompt{
+		Name:         e.Name,
+		Description:  e.Description,
+		SystemPrompt: e.SystemPrompt,
+		UserPrompt:   e.UserPrompt,
+		Extends:      e.Extends,
+		Tags:         e.Tags,
+		Variables:    e.Variables,
+		Version:      prompt.Version + 1,
+	}
+	before := *prompt
+	// an import replaces the whole prompt, so empty fields are written too
+	err := tx.Model(prompt).
+		Select("description", "system_prompt", "user_prompt", "extends", "tags", "variables", "version").
+		Updates(&changes).Error
+	if err != nil {
+		return err
+	}
+	if err := database.SyncPromptTags(tx, prompt); err != nil {
+		return err
+	}
+	version := models.NewPromptVersion(prompt, author, "imported")
+	if err := tx.Create(&version).Error; err != nil {
+		return err
+	}
+	return audit.Record(tx, audit.ActionUpdate, audit.EntityPrompt, fmt.Sprint(prompt.ID), before, prompt)
+}
+
+// normalize makes nil and empty variable lists compare equal.
+func normalize(e Entry) Entry {
+	if len(e.Variables) == 0 {
+		e.Variables = nil
+	}
+	return e
+}
@@ -0,0 +1,82 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func fakeOpenAI(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/chat/completions" {
+			http.NotFound(w, r)
+			return
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer test-key" {
+			t.Errorf("Authorization = %q", got)
+		}
+
+		var req streamRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatalf("decode request: %v", err)
+		}
+		if !req.Stream || req.Model != "fake-model" || len(req.Messages) != 2 {
+			t.Errorf("unexpected request: %+v", req)
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, token := range []string{"Hello", ", ", "world"} {
+			fmt.Fprintf(w, "data: {\"model\":\"fake-model\",\"choices\":[{\"delta\":{\"content\":%q}}]}\n\n", token)
+		}
+		fmt.Fprint(w, "data: {\"choices\":[],\"usage\":{\"prompt_tokens\":12,\"completion_tokens\":3,\"total_tokens\":15}}\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+}
+
+func TestOpenAIClientChatStream(t *testing.T) {
+	server := fakeOpenAI(t)
+	defer server.Close()
+
+	client := NewOpenAIClient(server.URL+"/", "test-key")
+	var tokens []string
+	resp, err := client.ChatStream(context.Background(), ChatRequest{
+		Model: "fake-model",
+		Messages: []Message{
+			{Role: "system", Content: "You are a Go language expert."},
+			{Role: "user", Content: "Say hello"},
+		},
+	}, func(token string) error {
+		tokens = append(tokens, token)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ChatStream: %v", err)
+	}
+
+	if strings.Join(tokens, "|") != "Hello|, |world" {
+		t.Errorf("tokens = %q", tokens)
+	}
+	if resp.Content != "Hello, world" {
+		t.Errorf("content = %q", resp.Content)
+	}
+	if resp.Usage.TotalTokens != 15 || resp.Usage.PromptTokens != 12 {
+		t.Errorf("usage = %+v", resp.Usage)
+	}
+}
+
+func TestOpenAIClientBackendError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error":"rate limited"}`, http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	client := NewOpenAIClient(server.URL, "")
+	_, err := client.ChatStream(context.Background(), ChatRequest{Model: "m"}, func(string) error { return nil })
+	if err == nil || !strings.Contains(err.Error(), "429") {
+		t.Fatalf("expected 429 error, got %v", err)
+	}
+}
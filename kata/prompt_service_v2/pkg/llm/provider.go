@@ -0,0 +1,59 @@
+// pkg/llm/provider.go
+package llm
+
+import (
+	"context"
+	"errors"
+)
+
+// Message is one chat message sent to the model.
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// ChatRequest is a chat completion request.
+type ChatRequest struct {
+	Model       string    `json:"model"`
+	Messages    []Message `json:"messages"`
+	Temperature *float64  `json:"temperature,omitempty"`
+	MaxTokens   int       `json:"max_tokens,omitempty"`
+}
+
+// Usage is the token accounting reported by the backend.
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// ChatResponse is the completed answer once streaming has finished.
+type ChatResponse struct {
+	Model   string `json:"model"`
+	Content string `json:"content"`
+	Usage   Usage  `json:"usage"`
+}
+
+// Provider runs chat completions. onToken is called for every streamed chunk;
+// returning an error from it aborts the stream.
+type Provider interface {
+	ChatStream(ctx context.Context, req ChatRequest, onToken func(token string) error) (*ChatResponse, error)
+}
+
+// Client is the provider used by the handlers. It is nil until InitProvider is called
+// and can be replaced in tests.
+var Client Provider
+
+// DefaultModel is used when a run request doesn't name a model.
+var DefaultModel string
+
+var ErrNotConfigured = errors.New("LLM provider is not configured")
+
+// InitProvider configures an OpenAI-compatible backend. An empty baseURL leaves it disabled.
+func InitProvider(baseURL, apiKey, model string) {
+	DefaultModel = model
+	if baseURL == "" {
+		return
+	}
+	Client = NewOpenAIClient(baseURL, apiKey)
+}
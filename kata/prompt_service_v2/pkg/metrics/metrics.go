@@ -29,8 +29,31 @@ var (
 		},
 		[]string{"method", "path", "status"},
 	)
+
+	RateLimitedRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "http_requests_rate_limited_total",
+			Help: "Number of requests rejected with 429, by limit scope",
+		},
+		[]string{"scope"}, // ip, user, route or lockout
+	)
+
+	LoginLockouts = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "login_lockouts_total",
+			Help: "Number of accounts locked after repeated failed logins",
+		},
+	)
+
+	CacheRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cache_requests_total",
+			Help: "Number of cache lookups, by cache and result",
+		},
+		[]string{"cache", "result"}, // prompt or search; hit or miss
+	)
 )
 
 func Register() {
-	prometheus.MustRegister(RequestCount, RequestDuration, RequestErrors)
+	prometheus.MustRegister(RequestCount, RequestDuration, RequestErrors, RateLimitedRequests, LoginLockouts, CacheRequests)
 }
\ No newline at end of file
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// APIKey is a long-lived credential for machine clients. Only the SHA-256 of the key
+// is stored; Prefix is kept in clear so owners can tell their keys apart.
+type APIKey struct {
+	ID         uint       `json:"id" gorm:"primaryKey"`
+	UserID     uint       `json:"userId" gorm:"index;not null"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	KeyHash    string     `json:"-" gorm:"uniqueIndex;not null"`
+	Scopes     string     `json:"scopes"` // comma separated, e.g. "prompts:read,prompts:write"
+	ExpiresAt  *time.Time `json:"expiresAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt"`
+	RevokedAt  *time.Time `json:"revokedAt"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
@@ -0,0 +1,22 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// AuditEntry records one mutation. Entries are append-only and hash-chained: Hash
+// covers the entry's fields and PrevHash, the Hash of the entry before it.
+type AuditEntry struct {
+	ID         uint            `json:"id" gorm:"primaryKey"`
+	CreatedAt  time.Time       `json:"createdAt" gorm:"index"`
+	Actor      string          `json:"actor" gorm:"index"`
+	Action     string          `json:"action" gorm:"index"`     // create, update, delete, ...
+	EntityType string          `json:"entityType" gorm:"index"` // prompt, user, policy, ...
+	EntityID   string          `json:"entityId" gorm:"index"`
+	Before     json.RawMessage `json:"before"`
+	After      json.RawMessage `json:"after"`
+	RequestID  string          `json:"requestId" gorm:"index"`
+	PrevHash   string          `json:"prevHash" gorm:"uniqueIndex"` // one successor per entry, even across replicas
+	Hash       string          `json:"hash" gorm:"uniqueIndex"`
+}
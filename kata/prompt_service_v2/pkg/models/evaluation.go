@@ -0,0 +1,78 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	AssertEquals     = "equals"      // the output, trimmed, is exactly value
+	AssertContains   = "contains"    // the output contains value
+	AssertRegex      = "regex"       // the output matches the regular expression value
+	AssertJSONSchema = "json_schema" // the output is JSON valid against schema
+	AssertLLMJudge   = "llm_judge"   // a judge model decides whether the output meets value
+
+	EvaluationCompleted = "completed" // every case ran, whether or not it passed
+	EvaluationFailed    = "failed"    // the model could not be reached for some cases
+)
+
+// Assertion is one check of a test case's output.
+type Assertion struct {
+	Type   string          `json:"type"`
+	Value  string          `json:"value,omitempty"`
+	Schema json.RawMessage `json:"schema,omitempty"`
+}
+
+// PromptTestCase is a set of input variables for a prompt and what its output must satisfy.
+// A non-empty Expected output is checked like an equals assertion.
+type PromptTestCase struct {
+	ID         uint                   `json:"id" gorm:"primaryKey"`
+	PromptID   uint                   `json:"promptId" gorm:"index;not null"`
+	Name       string                 `json:"name"`
+	Variables  map[string]interface{} `json:"variables" gorm:"serializer:json"`
+	Expected   string                 `json:"expected"`
+	Assertions []Assertion            `json:"assertions" gorm:"serializer:json"`
+	CreatedBy  string                 `json:"createdBy"`
+	CreatedAt  time.Time              `json:"createdAt"`
+	UpdatedAt  time.Time              `json:"updatedAt"`
+}
+
+// AssertionResult is the outcome of one assertion.
+type AssertionResult struct {
+	Type    string `json:"type"`
+	Passed  bool   `json:"passed"`
+	Message string `json:"message,omitempty"`
+}
+
+// CaseResult is the outcome of one test case. Score is the share of its assertions
+// that passed; the case passes when all of them do.
+type CaseResult struct {
+	TestCaseID  uint              `json:"testCaseId"`
+	Name        string            `json:"name"`
+	Output      string            `json:"output"`
+	Passed      bool              `json:"passed"`
+	Score       float64           `json:"score"`
+	Assertions  []AssertionResult `json:"assertions"`
+	Error       string            `json:"error,omitempty"`
+	LatencyMs   int64             `json:"latencyMs"`
+	TotalTokens int               `json:"totalTokens"`
+}
+
+// PromptEvaluation is the scored report of running a prompt revision's test cases
+// against a model. Score is the mean of the case scores.
+type PromptEvaluation struct {
+	ID          uint         `json:"id" gorm:"primaryKey"`
+	PromptID    uint         `json:"promptId" gorm:"index:idx_prompt_evaluation;not null"`
+	Version     int          `json:"version" gorm:"index:idx_prompt_evaluation"`
+	Model       string       `json:"model"`
+	JudgeModel  string       `json:"judgeModel,omitempty"`
+	Caller      string       `json:"caller"`
+	Status      string       `json:"status"`
+	Total       int          `json:"total"`
+	Passed      int          `json:"passed"`
+	Score       float64      `json:"score"`
+	Results     []CaseResult `json:"results" gorm:"serializer:json"`
+	LatencyMs   int64        `json:"latencyMs"`
+	TotalTokens int          `json:"totalTokens"`
+	CreatedAt   time.Time    `json:"createdAt"`
+}
@@ -3,13 +3,29 @@ package models
 import "gorm.io/gorm"
 
 type Prompt struct {
-    ID           uint           `json:"id" gorm:"primaryKey"`
-    Name         string         `json:"name" gorm:"index"`
-    Description  string         `json:"desc"`
-    SystemPrompt string         `json:"systemPrompt"`
-    UserPrompt   string         `json:"userPrompt"`
-    Tags         string         `json:"tags"` // 用逗号分隔的 tag 字符串
-    CreatedAt    int64          `json:"createdAt" gorm:"autoCreateTime"`
-    UpdatedAt    int64          `json:"updatedAt" gorm:"autoUpdateTime"`
-    DeletedAt    gorm.DeletedAt `gorm:"index" json:"-"`
+	ID           uint             `json:"id" gorm:"primaryKey"`
+	Name         string           `json:"name" gorm:"index"`
+	Description  string           `json:"desc"`
+	SystemPrompt string           `json:"systemPrompt"`
+	UserPrompt   string           `json:"userPrompt"`
+	Extends      string           `json:"extends"` // name of the base prompt, "name@3" pins a revision
+	Tags         string           `json:"tags"`    // 用逗号分隔的 tag 字符串
+	TagList      []Tag            `json:"-" gorm:"many2many:prompt_tags"`
+	Variables    []PromptVariable `json:"variables" gorm:"serializer:json"`
+	Version      int              `json:"version" gorm:"default:1"`
+	Owner        string           `json:"owner" gorm:"index"` // username, empty for prompts created before ownership
+	Visibility   string           `json:"visibility" gorm:"default:public"`
+	CreatedAt    int64            `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt    int64            `json:"updatedAt" gorm:"autoUpdateTime"`
+	DeletedAt    gorm.DeletedAt   `gorm:"index" json:"-"`
+}
+
+// PromptVariable declares a {{name}} placeholder used in SystemPrompt or UserPrompt.
+// Prompts may also hold {{> name}} partials and {{#block name}} blocks, see render.Expand.
+type PromptVariable struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"` // string, number, integer or boolean
+	Required    bool   `json:"required"`
+	Default     string `json:"default,omitempty"`
+	Description string `json:"description,omitempty"`
 }
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// PromptRun records one execution of a prompt against an LLM.
+type PromptRun struct {
+	ID               uint      `json:"id" gorm:"primaryKey"`
+	PromptID         uint      `json:"promptId" gorm:"index;not null"`
+	Version          int       `json:"version"`
+	Model            string    `json:"model"`
+	Caller           string    `json:"caller" gorm:"index"`
+	Status           string    `json:"status"` // success or error
+	Error            string    `json:"error,omitempty"`
+	LatencyMs        int64     `json:"latencyMs"`
+	PromptTokens     int       `json:"promptTokens"`
+	CompletionTokens int       `json:"completionTokens"`
+	TotalTokens      int       `json:"totalTokens"`
+	CreatedAt        time.Time `json:"createdAt"`
+}
@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+const (
+	VisibilityPrivate = "private" // owner, shares and admins only
+	VisibilityTeam    = "team"    // also everyone in the owner's team
+	VisibilityPublic  = "public"  // every authenticated user
+
+	ShareViewer = "viewer"
+	ShareEditor = "editor"
+
+	GranteeUser = "user"
+	GranteeTeam = "team"
+)
+
+// PromptShare grants a user or a whole team viewer or editor access to a prompt,
+// whatever its visibility.
+type PromptShare struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	PromptID    uint      `json:"promptId" gorm:"uniqueIndex:idx_prompt_share;not null"`
+	GranteeType string    `json:"granteeType" gorm:"uniqueIndex:idx_prompt_share;not null"` // user or team
+	Grantee     string    `json:"grantee" gorm:"uniqueIndex:idx_prompt_share;not null"`     // username or team name
+	Role        string    `json:"role" gorm:"not null"`                                     // viewer or editor
+	CreatedBy   string    `json:"createdBy"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+// ValidVisibility reports whether v is one of the visibility scopes.
+func ValidVisibility(v string) bool {
+	return v == VisibilityPrivate || v == VisibilityTeam || v == VisibilityPublic
+}
@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+// PromptVersion is an immutable snapshot of a prompt, written on every change.
+type PromptVersion struct {
+	ID           uint             `json:"id" gorm:"primaryKey"`
+	PromptID     uint             `json:"promptId" gorm:"uniqueIndex:idx_prompt_version;not null"`
+	Version      int              `json:"version" gorm:"uniqueIndex:idx_prompt_version;not null"`
+	Name         string           `json:"name"`
+	Description  string           `json:"desc"`
+	SystemPrompt string           `json:"systemPrompt"`
+	UserPrompt   string           `json:"userPrompt"`
+	Extends      string           `json:"extends"`
+	Tags         string           `json:"tags"`
+	Variables    []PromptVariable `json:"variables" gorm:"serializer:json"`
+	Author       string           `json:"author"`
+	ChangeNote   string           `json:"changeNote"`
+	CreatedAt    time.Time        `json:"createdAt"`
+}
+
+// NewPromptVersion snapshots the current content of p as its p.Version revision.
+func NewPromptVersion(p *Prompt, author, note string) PromptVersion {
+	return PromptVersion{
+		PromptID:     p.ID,
+		Version:      p.Version,
+		Name:         p.Name,
+		Description:  p.Description,
+		SystemPrompt: p.SystemPrompt,
+		UserPrompt:   p.UserPrompt,
+		Extends:      p.Extends,
+		Tags:         p.Tags,
+		Variables:    p.Variables,
+		Author:       author,
+		ChangeNote:   note,
+	}
+}
+
+// AsPrompt returns the prompt as it looked at this revision.
+func (v *PromptVersion) AsPrompt(current *Prompt) Prompt {
+	p := *current
+	p.Name = v.Name
+	p.Description = v.Description
+	p.SystemPrompt = v.SystemPrompt
+	p.UserPrompt = v.UserPrompt
+	p.Extends = v.Extends
+	p.Tags = v.Tags
+	p.Variables = v.Variables
+	p.Version = v.Version
+	return p
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// RefreshToken is a rotating refresh token. Only the SHA-256 of the token is stored;
+// tokens rotated from the same login share a FamilyID so reuse can revoke them all.
+type RefreshToken struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"userId" gorm:"index;not null"`
+	TokenHash string     `json:"-" gorm:"uniqueIndex;not null"`
+	FamilyID  string     `json:"familyId" gorm:"index;not null"`
+	ExpiresAt time.Time  `json:"expiresAt"`
+	RevokedAt *time.Time `json:"revokedAt"`
+	CreatedAt time.Time  `json:"createdAt"`
+}
@@ -0,0 +1,30 @@
+package models
+
+import "strings"
+
+// Tag is a prompt label, linked to prompts through the prompt_tags join table.
+type Tag struct {
+	ID   uint   `json:"id" gorm:"primaryKey"`
+	Name string `json:"name" gorm:"uniqueIndex;not null"`
+}
+
+// TagCount is a tag together with the number of prompts using it.
+type TagCount struct {
+	Name  string `json:"name"`
+	Count int64  `json:"count"`
+}
+
+// ParseTags splits a comma-separated tag string, trimming blanks and duplicates.
+func ParseTags(s string) []string {
+	seen := map[string]bool{}
+	var tags []string
+	for _, t := range strings.Split(s, ",") {
+		t = strings.TrimSpace(t)
+		if t == "" || seen[t] {
+			continue
+		}
+		seen[t] = true
+		tags = append(tags, t)
+	}
+	return tags
+}
@@ -7,10 +7,11 @@ import (
 type User struct {
 	ID        uint      `json:"id" gorm:"primaryKey"`
 	Username  string    `json:"username" gorm:"unique;not null"`
-	Password  string    `json:"password" gorm:"not null"`
+	Password  string    `json:"-" gorm:"not null"` // bcrypt hash, never serialized
 	Email     string    `json:"email" gorm:"unique;not null"`
 	Role      string    `json:"role" gorm:"default:'user'"` // e.g., 'admin', 'user'
-	ExpiredAt time.Time `json:"expired_at"`                // expiration time for account
+	Team      string    `json:"team" gorm:"index"`          // prompts with team visibility are shared within it
+	ExpiredAt time.Time `json:"expired_at"`                 // expiration time for account
 	CreatedAt time.Time `json:"-"`
 	UpdatedAt time.Time `json:"-"`
-}
\ No newline at end of file
+}
@@ -0,0 +1,201 @@
+package openapi
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Spec describes the routes under Prefixes. Ops is keyed by method and gin path,
+// e.g. "GET /api/v1/prompts/:id".
+type Spec struct {
+	Title       string
+	Version     string
+	Description string
+	Prefixes    []string
+	Ops         map[string]Op
+}
+
+// Op describes one route. Body and the Responses values are Go values whose JSON
+// encoding the schemas are derived from; use Content for other media types.
+type Op struct {
+	Summary   string
+	Tags      []string
+	Query     []Param
+	Body      interface{}
+	Responses map[int]interface{}
+	Public    bool // reachable without credentials
+}
+
+type Param struct {
+	Name        string
+	Type        string // string, integer or boolean
+	Description string
+	Required    bool
+	Array       bool // may be repeated
+	Enum        []string
+}
+
+// Content is a payload in several media types; a nil value leaves the schema open.
+type Content map[string]interface{}
+
+// pathParamTypes are the types of path parameters, string when not listed.
+var pathParamTypes = map[string]string{
+	"id":      "integer",
+	"version": "integer",
+	"shareId": "integer",
+	"keyId":   "integer",
+
+	"caseId":       "integer",
+	"evaluationId": "integer",
+}
+
+// Build documents routes with spec. Routes under the prefixes without an Op, and Ops
+// without a route, are returned as an error alongside the document of the rest, so
+// a test can keep the two in sync.
+func Build(spec Spec, routes gin.RoutesInfo) (*Document, error) {
+	g := &generator{components: map[reflect.Type]*component{}}
+	doc := &Document{
+		OpenAPI: "3.0.3",
+		Info:    Info{Title: spec.Title, Version: spec.Version, Description: spec.Description},
+		Paths:   map[string]PathItem{},
+		Components: Components{
+			SecuritySchemes: map[string]*SecurityScheme{
+				"bearerAuth": {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+				"apiKey":     {Type: "apiKey", In: "header", Name: "X-API-Key"},
+			},
+		},
+		Security: []map[string][]string{{"bearerAuth": {}}, {"apiKey": {}}},
+	}
+	errorSchema := g.schemaOf(reflect.TypeOf(Error{}))
+
+	var problems []string
+	documented := map[string]bool{}
+	for _, route := range routes {
+		if !hasPrefix(route.Path, spec.Prefixes) {
+			continue
+		}
+		key := route.Method + " " + route.Path
+		op, ok := spec.Ops[key]
+		if !ok {
+			problems = append(problems, "undocumented route "+key)
+			continue
+		}
+		documented[key] = true
+
+		path, params := openAPIPath(route.Path)
+		item := doc.Paths[path]
+		if item == nil {
+			item = PathItem{}
+			doc.Paths[path] = item
+		}
+		item[strings.ToLower(route.Method)] = g.operation(op, handlerName(route.Handler), params, errorSchema)
+	}
+	for key := range spec.Ops {
+		if !documented[key] {
+			problems = append(problems, "documented route "+key+" is not registered")
+		}
+	}
+
+	doc.Components.Schemas = g.schemas()
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return doc, fmt.Errorf("openapi: %s", strings.Join(problems, "; "))
+	}
+	return doc, nil
+}
+
+func (g *generator) operation(op Op, id string, params []Parameter, errorSchema *Schema) *Operation {
+	o := &Operation{
+		OperationID: id,
+		Summary:     op.Summary,
+		Tags:        op.Tags,
+		Parameters:  params,
+		Responses:   map[string]*Response{},
+	}
+	for _, q := range op.Query {
+		s := &Schema{Type: q.Type, Enum: q.Enum}
+		if q.Array {
+			s = &Schema{Type: "array", Items: s}
+		}
+		o.Parameters = append(o.Parameters, Parameter{Name: q.Name, In: "query", Description: q.Description, Required: q.Required, Schema: s})
+	}
+
+	if op.Body != nil {
+		g.request = true
+		o.RequestBody = &RequestBody{Required: true, Content: g.content(op.Body)}
+		g.request = false
+	}
+
+	for status, body := range op.Responses {
+		o.Responses[strconv.Itoa(status)] = &Response{Description: http.StatusText(status), Content: g.content(body)}
+	}
+	errorResponse := func(description string) *Response {
+		return &Response{Description: description, Content: map[string]MediaType{"application/json": {Schema: errorSchema}}}
+	}
+	if op.Public {
+		o.Security = []map[string][]string{}
+	} else {
+		o.Responses["401"] = errorResponse("Missing or invalid credentials")
+		o.Responses["403"] = errorResponse("Not allowed by the policy or the resource's access")
+	}
+	o.Responses["429"] = errorResponse("Rate limited, see the Retry-After header")
+	o.Responses["default"] = errorResponse("Error")
+	return o
+}
+
+func (g *generator) content(body interface{}) map[string]MediaType {
+	if c, ok := body.(Content); ok {
+		media := map[string]MediaType{}
+		for mediaType, v := range c {
+			var s *Schema
+			if v != nil {
+				s = g.schemaOf(reflect.TypeOf(v))
+			}
+			media[mediaType] = MediaType{Schema: s}
+		}
+		return media
+	}
+	return map[string]MediaType{"application/json": {Schema: g.schemaOf(reflect.TypeOf(body))}}
+}
+
+func hasPrefix(path string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(path, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// openAPIPath turns /prompts/:id into /prompts/{id} and lists its parameters.
+func openAPIPath(ginPath string) (string, []Parameter) {
+	var params []Parameter
+	segments := strings.Split(ginPath, "/")
+	for i, seg := range segments {
+		if len(seg) > 1 && (seg[0] == ':' || seg[0] == '*') {
+			name := seg[1:]
+			typ := pathParamTypes[name]
+			if typ == "" {
+				typ = "string"
+			}
+			params = append(params, Parameter{Name: name, In: "path", Required: true, Schema: &Schema{Type: typ}})
+			segments[i] = "{" + name + "}"
+		}
+	}
+	return strings.Join(segments, "/"), params
+}
+
+// handlerName turns ".../pkg/handlers.CreatePrompt" into CreatePrompt.
+func handlerName(handler string) string {
+	if i := strings.LastIndex(handler, "."); i >= 0 {
+		return handler[i+1:]
+	}
+	return handler
+}
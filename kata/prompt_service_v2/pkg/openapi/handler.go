@@ -0,0 +1,44 @@
+package openapi
+
+import (
+	"html/template"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// JSONHandler serves the document, e.g. at /openapi.json.
+func JSONHandler(doc *Document) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, doc)
+	}
+}
+
+// swaggerUI loads Swagger UI from a CDN and points it at the document.
+const swaggerUI = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>Prompt Service API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+  <script>
+    window.ui = SwaggerUIBundle({url: {{.}}, dom_id: "#swagger-ui"});
+  </script>
+</body>
+</html>
+`
+
+var uiTemplate = template.Must(template.New("swagger-ui").Parse(swaggerUI))
+
+// UIHandler serves a Swagger UI page for the document at specURL.
+func UIHandler(specURL string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Content-Type", "text/html; charset=utf-8")
+		c.Status(http.StatusOK)
+		_ = uiTemplate.Execute(c.Writer, specURL)
+	}
+}
@@ -0,0 +1,167 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+)
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+	marshalerType  = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+// generator derives schemas from the JSON encoding of Go types. Response schemas
+// register named structs as components and require every field without omitempty;
+// request schemas are inlined and require the fields with binding:"required".
+type generator struct {
+	components map[reflect.Type]*component
+	request    bool
+}
+
+// component is a named struct schema and the references to it, which are
+// filled in by names once every type is known.
+type component struct {
+	schema *Schema
+	refs   []*Schema
+}
+
+func (g *generator) schemaOf(t reflect.Type) *Schema {
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t == rawMessageType:
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		s := g.schemaOf(t.Elem())
+		if s.Ref != "" {
+			return &Schema{AllOf: []*Schema{s}, Nullable: true}
+		}
+		nullable := *s
+		nullable.Nullable = true
+		return &nullable
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		// nil slices encode as null
+		return &Schema{Type: "array", Items: g.schemaOf(t.Elem()), Nullable: t.Kind() == reflect.Slice}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schemaOf(t.Elem()), Nullable: true}
+	case reflect.Struct:
+		if reflect.PointerTo(t).Implements(marshalerType) {
+			return &Schema{}
+		}
+		if g.request || t.Name() == "" {
+			return g.structSchema(t)
+		}
+		comp, ok := g.components[t]
+		if !ok {
+			// register first so recursive types refer to themselves
+			comp = &component{schema: &Schema{}}
+			g.components[t] = comp
+			*comp.schema = *g.structSchema(t)
+		}
+		ref := &Schema{}
+		comp.refs = append(comp.refs, ref)
+		return ref
+	}
+	return &Schema{}
+}
+
+func (g *generator) structSchema(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	g.addFields(s, t)
+	return s
+}
+
+func (g *generator) addFields(s *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+
+		ft := f.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			// encoding/json promotes the fields of embedded structs
+			g.addFields(s, ft)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		s.Properties[name] = g.schemaOf(f.Type)
+		required := !strings.Contains(opts, "omitempty")
+		if g.request {
+			required = strings.Contains(f.Tag.Get("binding"), "required")
+		}
+		if required {
+			s.Required = append(s.Required, name)
+		}
+	}
+}
+
+// schemas names the components, qualifying a name with its package when types of
+// several packages share it, e.g. RenderMessage and OpenapiMessage.
+func (g *generator) schemas() map[string]*Schema {
+	count := map[string]int{}
+	for t := range g.components {
+		count[componentName(t)]++
+	}
+	schemas := map[string]*Schema{}
+	for t, comp := range g.components {
+		name := componentName(t)
+		if count[name] > 1 {
+			pkg := t.PkgPath()
+			pkg = pkg[strings.LastIndex(pkg, "/")+1:]
+			name = strings.ToUpper(pkg[:1]) + pkg[1:] + name
+		}
+		schemas[name] = comp.schema
+		for _, ref := range comp.refs {
+			ref.Ref = "#/components/schemas/" + name
+		}
+	}
+	return schemas
+}
+
+// componentName capitalizes the type name and names generic instances after their
+// type arguments, e.g. Page[.../models.Prompt] becomes PageOfPrompt.
+func componentName(t reflect.Type) string {
+	name := strings.ToUpper(t.Name()[:1]) + t.Name()[1:]
+	base, args, ok := strings.Cut(name, "[")
+	if !ok {
+		return name
+	}
+	var parts []string
+	for _, arg := range strings.Split(strings.TrimSuffix(args, "]"), ",") {
+		if i := strings.LastIndexAny(arg, "./"); i >= 0 {
+			arg = arg[i+1:]
+		}
+		parts = append(parts, arg)
+	}
+	return base + "Of" + strings.Join(parts, "And")
+}
@@ -0,0 +1,211 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Match finds the documented operation serving a request path such as /api/v1/prompts/7,
+// returning it with its path template. Literal segments win over parameters, so
+// /prompts/export doesn't match /prompts/{id}.
+func (d *Document) Match(method, path string) (string, *Operation) {
+	path, _, _ = strings.Cut(path, "?")
+	segments := strings.Split(path, "/")
+
+	var match string
+	var matchOp *Operation
+	best := -1
+	for template, item := range d.Paths {
+		op := item[strings.ToLower(method)]
+		parts := strings.Split(template, "/")
+		if op == nil || len(parts) != len(segments) {
+			continue
+		}
+		if score := matchScore(parts, segments); score > best {
+			match, matchOp, best = template, op, score
+		}
+	}
+	return match, matchOp
+}
+
+// matchScore counts the literal segments of a template matching segments, or is -1.
+func matchScore(parts, segments []string) int {
+	score := 0
+	for i, part := range parts {
+		switch {
+		case part == segments[i]:
+			score++
+		case strings.HasPrefix(part, "{") && segments[i] != "":
+		default:
+			return -1
+		}
+	}
+	return score
+}
+
+// ValidateResponse checks a response to method path against the document: the status
+// must be documented (or covered by "default"), and a JSON body must match its schema.
+func (d *Document) ValidateResponse(method, path string, status int, contentType string, body []byte) error {
+	template, op := d.Match(method, path)
+	if op == nil {
+		return fmt.Errorf("%s %s is not documented", method, path)
+	}
+	resp := op.Responses[strconv.Itoa(status)]
+	if resp == nil {
+		resp = op.Responses["default"]
+	}
+	if resp == nil {
+		return fmt.Errorf("%s %s: status %d is not documented", method, template, status)
+	}
+	if len(resp.Content) == 0 {
+		return nil
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return fmt.Errorf("%s %s: invalid content type %q", method, template, contentType)
+	}
+	media, ok := resp.Content[mediaType]
+	if !ok {
+		return fmt.Errorf("%s %s: content type %s is not documented for status %d", method, template, mediaType, status)
+	}
+	if media.Schema == nil || mediaType != "application/json" {
+		return nil
+	}
+
+	value, err := decode(body)
+	if err != nil {
+		return fmt.Errorf("%s %s: invalid JSON: %v", method, template, err)
+	}
+	if err := d.validate(media.Schema, value, "$"); err != nil {
+		return fmt.Errorf("%s %s %d: %v", method, template, status, err)
+	}
+	return nil
+}
+
+// ValidateJSON checks a JSON document against a standalone schema, one without $ref.
+func ValidateJSON(s *Schema, data []byte) error {
+	value, err := decode(data)
+	if err != nil {
+		return fmt.Errorf("invalid JSON: %v", err)
+	}
+	return (&Document{}).validate(s, value, "$")
+}
+
+func decode(data []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var value interface{}
+	if err := dec.Decode(&value); err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, fmt.Errorf("unexpected data after the JSON value")
+	}
+	return value, nil
+}
+
+func (d *Document) validate(s *Schema, v interface{}, at string) error {
+	if s.Ref != "" {
+		name := strings.TrimPrefix(s.Ref, "#/components/schemas/")
+		ref, ok := d.Components.Schemas[name]
+		if !ok {
+			return fmt.Errorf("%s: unknown schema %s", at, s.Ref)
+		}
+		return d.validate(ref, v, at)
+	}
+	if v == nil {
+		if s.Nullable || (s.Type == "" && len(s.AllOf) == 0) {
+			return nil
+		}
+		return fmt.Errorf("%s: null is not allowed", at)
+	}
+	for _, sub := range s.AllOf {
+		if err := d.validate(sub, v, at); err != nil {
+			return err
+		}
+	}
+
+	switch s.Type {
+	case "object":
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s: expected an object, got %T", at, v)
+		}
+		for _, name := range s.Required {
+			if _, ok := obj[name]; !ok {
+				return fmt.Errorf("%s: missing required property %q", at, name)
+			}
+		}
+		for name, value := range obj {
+			prop, ok := s.Properties[name]
+			if !ok {
+				prop = s.AdditionalProperties
+			}
+			if prop == nil {
+				continue
+			}
+			if err := d.validate(prop, value, at+"."+name); err != nil {
+				return err
+			}
+		}
+	case "array":
+		items, ok := v.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s: expected an array, got %T", at, v)
+		}
+		if s.Items != nil {
+			for i, item := range items {
+				if err := d.validate(s.Items, item, fmt.Sprintf("%s[%d]", at, i)); err != nil {
+					return err
+				}
+			}
+		}
+	case "string":
+		str, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("%s: expected a string, got %T", at, v)
+		}
+		if s.Format == "date-time" {
+			if _, err := time.Parse(time.RFC3339Nano, str); err != nil {
+				return fmt.Errorf("%s: %q is not a date-time", at, str)
+			}
+		}
+		if len(s.Enum) > 0 && !contains(s.Enum, str) {
+			return fmt.Errorf("%s: %q is not one of %v", at, str, s.Enum)
+		}
+	case "integer":
+		n, ok := v.(json.Number)
+		if !ok {
+			return fmt.Errorf("%s: expected an integer, got %T", at, v)
+		}
+		if _, err := n.Int64(); err != nil {
+			if _, err := strconv.ParseUint(n.String(), 10, 64); err != nil {
+				return fmt.Errorf("%s: %s is not an integer", at, n)
+			}
+		}
+	case "number":
+		if _, ok := v.(json.Number); !ok {
+			return fmt.Errorf("%s: expected a number, got %T", at, v)
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return fmt.Errorf("%s: expected a boolean, got %T", at, v)
+		}
+	}
+	return nil
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,213 @@
+// pkg/pagination/pagination.go
+package pagination
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+)
+
+// Page is the response envelope of every list endpoint.
+type Page[T any] struct {
+	Items      []T    `json:"items"`
+	Total      int64  `json:"total"`
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+// Field is a column callers may sort by. An empty Column means the caller orders
+// the query itself (e.g. by relevance) and pages through it by position.
+type Field struct {
+	Column string
+	Time   bool // the column holds time.Time values
+}
+
+// Params are the parsed limit, sort, order and cursor query parameters.
+type Params struct {
+	Limit int
+	Sort  string
+	Field Field
+	Desc  bool
+
+	offset int
+	after  *cursor
+}
+
+// cursor points just past the last item of the previous page: either the sort value
+// and id of that item (keyset), or a position for caller-ordered queries. Sort and
+// Desc record the ordering it was built for.
+type cursor struct {
+	Sort   string      `json:"s"`
+	Desc   bool        `json:"d,omitempty"`
+	Value  interface{} `json:"v"`
+	ID     uint        `json:"id,omitempty"`
+	Offset int         `json:"o,omitempty"`
+}
+
+// Error is returned by Parse for invalid query parameters.
+type Error struct {
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Parse reads limit (or the legacy pageSize), sort, order, cursor and the legacy pageNum.
+// sort must be one of the keys of fields.
+func Parse(c *gin.Context, fields map[string]Field, defaultSort string) (*Params, error) {
+	p := &Params{Limit: DefaultLimit, Sort: defaultSort, Desc: true}
+
+	limit := c.Query("limit")
+	if limit == "" {
+		limit = c.Query("pageSize")
+	}
+	if limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 {
+			return nil, &Error{"Invalid limit: " + limit}
+		}
+		p.Limit = min(n, MaxLimit)
+	}
+
+	if s := c.Query("sort"); s != "" {
+		p.Sort = s
+	}
+	field, ok := fields[p.Sort]
+	if !ok {
+		return nil, &Error{fmt.Sprintf("Invalid sort %q, expected one of %s", p.Sort, strings.Join(keys(fields), ", "))}
+	}
+	p.Field = field
+
+	switch order := strings.ToLower(c.DefaultQuery("order", "desc")); order {
+	case "asc":
+		p.Desc = false
+	case "desc":
+		p.Desc = true
+	default:
+		return nil, &Error{"Invalid order: " + order}
+	}
+
+	if token := c.Query("cursor"); token != "" {
+		after, err := decodeCursor(token, field)
+		if err != nil {
+			return nil, &Error{"Invalid cursor"}
+		}
+		if after.Sort != p.Sort || after.Desc != p.Desc {
+			return nil, &Error{"Cursor doesn't match sort and order, start again without it"}
+		}
+		p.after = after
+		p.offset = after.Offset
+	} else if pageNum, err := strconv.Atoi(c.Query("pageNum")); err == nil && pageNum > 1 {
+		p.offset = (pageNum - 1) * p.Limit
+	}
+
+	return p, nil
+}
+
+// Apply adds ordering, the cursor condition and the limit to query. table qualifies
+// the sort and id columns when the query joins other tables.
+func (p *Params) Apply(query *gorm.DB, table string) *gorm.DB {
+	if p.Field.Column != "" {
+		column, id := table+"."+p.Field.Column, table+".id"
+		op, dir := ">", "ASC"
+		if p.Desc {
+			op, dir = "<", "DESC"
+		}
+
+		if p.after != nil {
+			query = query.Where(
+				fmt.Sprintf("(%s %s ? OR (%s = ? AND %s %s ?))", column, op, column, id, op),
+				p.after.Value, p.after.Value, p.after.ID)
+		} else if p.offset > 0 {
+			query = query.Offset(p.offset)
+		}
+		query = query.Order(column + " " + dir).Order(id + " " + dir)
+	} else if p.offset > 0 {
+		query = query.Offset(p.offset)
+	}
+
+	// one extra row tells whether there is a next page
+	return query.Limit(p.Limit + 1)
+}
+
+// NewPage trims the extra row fetched by Apply and builds the next cursor from the
+// last item. key returns the item's sort value and id.
+func NewPage[T any](p *Params, items []T, total int64, key func(item T) (interface{}, uint)) Page[T] {
+	page := Page[T]{Items: items, Total: total}
+	if page.Items == nil {
+		page.Items = []T{}
+	}
+	if len(items) <= p.Limit {
+		return page
+	}
+
+	page.Items = items[:p.Limit]
+	next := cursor{Sort: p.Sort, Desc: p.Desc}
+	if p.Field.Column == "" {
+		next.Offset = p.offset + p.Limit
+	} else {
+		next.Value, next.ID = key(page.Items[p.Limit-1])
+	}
+	page.NextCursor = encodeCursor(next)
+	return page
+}
+
+func encodeCursor(c cursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(token string, field Field) (*cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, err
+	}
+	var c cursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, err
+	}
+
+	if field.Column == "" {
+		if c.Offset <= 0 {
+			return nil, fmt.Errorf("cursor has no position")
+		}
+		return &c, nil
+	}
+
+	if c.Offset != 0 {
+		return nil, fmt.Errorf("cursor doesn't match sort")
+	}
+	if field.Time && c.Value != nil {
+		s, ok := c.Value.(string)
+		if !ok {
+			return nil, fmt.Errorf("cursor value is not a time")
+		}
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return nil, err
+		}
+		c.Value = t
+	}
+	return &c, nil
+}
+
+func keys(fields map[string]Field) []string {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
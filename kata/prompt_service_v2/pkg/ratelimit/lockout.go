@@ -0,0 +1,59 @@
+// pkg/ratelimit/lockout.go
+package ratelimit
+
+import (
+	"context"
+	"time"
+)
+
+// LockoutPolicy locks a client out of an account after MaxFailures failed logins from
+// its IP within Window, and the account for everyone after AccountMaxFailures from all
+// IPs, so a single client can't lock others out. Each lockout lasts Backoff, doubling
+// with every lockout in a row up to MaxBackoff.
+type LockoutPolicy struct {
+	MaxFailures        int
+	AccountMaxFailures int
+	Window             time.Duration
+	Backoff            time.Duration
+	MaxBackoff         time.Duration
+}
+
+var Policy = LockoutPolicy{
+	MaxFailures:        5,
+	AccountMaxFailures: 20,
+	Window:             15 * time.Minute,
+	Backoff:            time.Minute,
+	MaxBackoff:         time.Hour,
+}
+
+// backoff is the length of the given lockout in a row, counting from zero.
+func (p LockoutPolicy) backoff(strikes int) time.Duration {
+	d := p.Backoff
+	for i := 0; i < strikes && d < p.MaxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, p.MaxBackoff)
+}
+
+// strikeTTL is how long consecutive lockouts are remembered for the backoff.
+func (p LockoutPolicy) strikeTTL() time.Duration {
+	return 2*p.MaxBackoff + p.Window
+}
+
+// Lockout tracks failed logins per account and client IP, and per account.
+type Lockout interface {
+	// Locked returns how long the account stays locked for ip, zero when it isn't.
+	Locked(ctx context.Context, account, ip string) (time.Duration, error)
+	// Fail records a failed login from ip and returns the lockout it triggered, if any.
+	Fail(ctx context.Context, account, ip string) (time.Duration, error)
+	// Reset forgets the failures after a successful login from ip.
+	Reset(ctx context.Context, account, ip string) error
+}
+
+// clientKey identifies the failures of account from ip.
+func clientKey(account, ip string) string {
+	return account + "@" + ip
+}
+
+// Lockouts holds the failed login state; Init picks the implementation.
+var Lockouts Lockout = NewMemoryLockout()
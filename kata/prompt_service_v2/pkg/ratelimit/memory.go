@@ -0,0 +1,163 @@
+// pkg/ratelimit/memory.go
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	idle   time.Duration // time to refill completely, after which the bucket can be dropped
+}
+
+type memoryLimiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+func NewMemoryLimiter() Limiter {
+	return &memoryLimiter{buckets: map[string]*bucket{}, lastSweep: time.Now()}
+}
+
+func (m *memoryLimiter) Allow(_ context.Context, key string, rate Rate) (bool, time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	m.sweep(now)
+
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(rate.Limit), last: now, idle: rate.Period}
+		m.buckets[key] = b
+	}
+	b.tokens = min(float64(rate.Limit), b.tokens+now.Sub(b.last).Seconds()*rate.perSecond())
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0, nil
+	}
+	wait := time.Duration((1 - b.tokens) / rate.perSecond() * float64(time.Second))
+	return false, wait, nil
+}
+
+// sweep drops full buckets once a minute so the map doesn't grow with every client seen.
+func (m *memoryLimiter) sweep(now time.Time) {
+	if now.Sub(m.lastSweep) < time.Minute {
+		return
+	}
+	m.lastSweep = now
+	for key, b := range m.buckets {
+		if now.Sub(b.last) > b.idle {
+			delete(m.buckets, key)
+		}
+	}
+}
+
+// lockoutState counts the failed logins of an account, or of an account from one IP.
+type lockoutState struct {
+	failures    int
+	windowStart time.Time
+	strikes     int
+	lastLockout time.Time
+	lockedUntil time.Time
+}
+
+// expired reports whether s no longer affects the window, backoff or lock.
+func (s *lockoutState) expired(now time.Time) bool {
+	return now.Sub(s.windowStart) > Policy.Window && now.Sub(s.lastLockout) > Policy.strikeTTL()
+}
+
+type memoryLockout struct {
+	mu        sync.Mutex
+	accounts  map[string]*lockoutState
+	clients   map[string]*lockoutState
+	lastSweep time.Time
+}
+
+func NewMemoryLockout() Lockout {
+	return &memoryLockout{
+		accounts:  map[string]*lockoutState{},
+		clients:   map[string]*lockoutState{},
+		lastSweep: time.Now(),
+	}
+}
+
+func (m *memoryLockout) Locked(_ context.Context, account, ip string) (time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var wait time.Duration
+	if s, ok := m.accounts[account]; ok {
+		wait = max(wait, time.Until(s.lockedUntil))
+	}
+	if s, ok := m.clients[clientKey(account, ip)]; ok {
+		wait = max(wait, time.Until(s.lockedUntil))
+	}
+	return wait, nil
+}
+
+func (m *memoryLockout) Fail(_ context.Context, account, ip string) (time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	m.sweep(now)
+	client := fail(m.clients, clientKey(account, ip), Policy.MaxFailures, now)
+	return max(client, fail(m.accounts, account, Policy.AccountMaxFailures, now)), nil
+}
+
+// fail counts a failure in the state of key and returns the lockout it triggered, if any.
+func fail(states map[string]*lockoutState, key string, maxFailures int, now time.Time) time.Duration {
+	s, ok := states[key]
+	if !ok {
+		s = &lockoutState{}
+		states[key] = s
+	}
+	if now.Sub(s.windowStart) > Policy.Window {
+		s.failures, s.windowStart = 0, now
+	}
+	if now.Sub(s.lastLockout) > Policy.strikeTTL() {
+		s.strikes = 0
+	}
+
+	s.failures++
+	if s.failures < maxFailures {
+		return 0
+	}
+	d := Policy.backoff(s.strikes)
+	s.failures, s.windowStart = 0, now
+	s.strikes++
+	s.lastLockout = now
+	s.lockedUntil = now.Add(d)
+	return d
+}
+
+// sweep drops expired states once a minute so failures from ever new IPs and
+// usernames don't grow the maps without bound.
+func (m *memoryLockout) sweep(now time.Time) {
+	if now.Sub(m.lastSweep) < time.Minute {
+		return
+	}
+	m.lastSweep = now
+	for _, states := range []map[string]*lockoutState{m.accounts, m.clients} {
+		for key, s := range states {
+			if s.expired(now) {
+				delete(states, key)
+			}
+		}
+	}
+}
+
+func (m *memoryLockout) Reset(_ context.Context, account, ip string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.accounts, account)
+	delete(m.clients, clientKey(account, ip))
+	return nil
+}
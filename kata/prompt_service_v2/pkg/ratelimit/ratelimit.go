@@ -0,0 +1,214 @@
+// pkg/ratelimit/ratelimit.go
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/walterfan/prompt-service/internal/log"
+	"github.com/walterfan/prompt-service/pkg/metrics"
+	"go.uber.org/zap"
+)
+
+// Rate allows Limit requests per Period, refilled continuously; Limit is also the burst.
+// The zero Rate disables limiting.
+type Rate struct {
+	Limit  int
+	Period time.Duration
+}
+
+func (r Rate) Enabled() bool {
+	return r.Limit > 0 && r.Period > 0
+}
+
+// perSecond is the refill speed of the bucket.
+func (r Rate) perSecond() float64 {
+	return float64(r.Limit) / r.Period.Seconds()
+}
+
+// ParseRate reads "100/m" (also "/s", "/h" or a duration such as "/10s"); "" and "0" disable.
+func ParseRate(s string) (Rate, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || s == "0" {
+		return Rate{}, nil
+	}
+	count, period, ok := strings.Cut(s, "/")
+	if !ok {
+		return Rate{}, fmt.Errorf("invalid rate %q, expected e.g. 100/m", s)
+	}
+	limit, err := strconv.Atoi(strings.TrimSpace(count))
+	if err != nil || limit < 0 {
+		return Rate{}, fmt.Errorf("invalid rate %q, expected e.g. 100/m", s)
+	}
+
+	var d time.Duration
+	switch period = strings.TrimSpace(period); period {
+	case "s":
+		d = time.Second
+	case "m":
+		d = time.Minute
+	case "h":
+		d = time.Hour
+	default:
+		d, err = time.ParseDuration(period)
+		if err != nil || d <= 0 {
+			return Rate{}, fmt.Errorf("invalid rate period %q", period)
+		}
+	}
+	return Rate{Limit: limit, Period: d}, nil
+}
+
+// ParseRoutes reads comma-separated "METHOD /path=rate" entries, with paths as
+// registered in gin, e.g. "POST /login=20/m,POST /api/v1/prompts/:id/run=30/m".
+func ParseRoutes(s string) (map[string]Rate, error) {
+	routes := map[string]Rate{}
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		route, rate, ok := strings.Cut(entry, "=")
+		method, path, hasPath := strings.Cut(strings.TrimSpace(route), " ")
+		if !ok || !hasPath {
+			return nil, fmt.Errorf("invalid route limit %q, expected e.g. POST /login=20/m", entry)
+		}
+		r, err := ParseRate(rate)
+		if err != nil {
+			return nil, err
+		}
+		routes[strings.ToUpper(method)+" "+strings.TrimSpace(path)] = r
+	}
+	return routes, nil
+}
+
+// Limits are the rates applied by the middlewares.
+type Limits struct {
+	IP     Rate
+	User   Rate
+	Routes map[string]Rate
+}
+
+// current holds the limits in effect; SetLimits swaps them while serving.
+var current atomic.Pointer[Limits]
+
+// SetLimits changes the limits of every middleware, e.g. when the configuration is
+// reloaded. Buckets keep their tokens, so only the new refill rate and burst apply.
+func SetLimits(limits *Limits) {
+	current.Store(limits)
+}
+
+func currentLimits() *Limits {
+	if limits := current.Load(); limits != nil {
+		return limits
+	}
+	return &Limits{}
+}
+
+func ParseLimits(ip, user, routes string) (*Limits, error) {
+	var limits Limits
+	var err error
+	if limits.IP, err = ParseRate(ip); err != nil {
+		return nil, err
+	}
+	if limits.User, err = ParseRate(user); err != nil {
+		return nil, err
+	}
+	if limits.Routes, err = ParseRoutes(routes); err != nil {
+		return nil, err
+	}
+	return &limits, nil
+}
+
+// Limiter is a set of token buckets identified by key.
+type Limiter interface {
+	// Allow takes a token from the bucket of key, or tells how long until one is available.
+	Allow(ctx context.Context, key string, rate Rate) (bool, time.Duration, error)
+}
+
+// Buckets holds the rate limit state; Init picks the implementation.
+var Buckets Limiter = NewMemoryLimiter()
+
+// Init keeps buckets and login failures in Redis when a client is given, so limits hold
+// across instances; otherwise they stay in process memory.
+func Init(client *redis.Client, policy LockoutPolicy) {
+	Policy = policy
+	if client != nil {
+		Buckets = &redisLimiter{client: client}
+		Lockouts = &redisLockout{client: client}
+		return
+	}
+	Buckets = NewMemoryLimiter()
+	Lockouts = NewMemoryLockout()
+}
+
+// ByIP limits every request per client IP.
+func ByIP() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit(c, "ip", "ip:"+c.ClientIP(), currentLimits().IP)
+	}
+}
+
+// ByUser limits requests per authenticated user; use it after the auth middleware.
+func ByUser() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := c.GetString("user")
+		if user == "" {
+			c.Next()
+			return
+		}
+		limit(c, "user", "user:"+user, currentLimits().User)
+	}
+}
+
+// ByRoute applies the limit of the matched route per client IP, so expensive or
+// sensitive endpoints get their own budget.
+func ByRoute() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := c.Request.Method + " " + c.FullPath()
+		rate, ok := currentLimits().Routes[route]
+		if !ok {
+			c.Next()
+			return
+		}
+		limit(c, "route", "route:"+route+":"+c.ClientIP(), rate)
+	}
+}
+
+func limit(c *gin.Context, scope, key string, rate Rate) {
+	if !rate.Enabled() {
+		c.Next()
+		return
+	}
+
+	allowed, wait, err := Buckets.Allow(c.Request.Context(), key, rate)
+	if err != nil {
+		// fail open: an unavailable store must not take the API down
+		log.Ctx(c).Error("Rate limiter unavailable", zap.String("scope", scope), zap.Error(err))
+		c.Next()
+		return
+	}
+	if !allowed {
+		metrics.RateLimitedRequests.WithLabelValues(scope).Inc()
+		TooManyRequests(c, wait, "Too many requests")
+		return
+	}
+	c.Next()
+}
+
+// TooManyRequests aborts with 429 and a Retry-After header of at least one second.
+func TooManyRequests(c *gin.Context, wait time.Duration, message string) {
+	seconds := int(math.Ceil(wait.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": message, "retryAfter": seconds})
+}
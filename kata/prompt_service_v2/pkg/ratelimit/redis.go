@@ -0,0 +1,117 @@
+// pkg/ratelimit/redis.go
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const keyPrefix = "prompt-service:ratelimit:"
+
+// tokenBucket refills and takes a token atomically using the Redis clock.
+// It returns {allowed, milliseconds to wait}.
+var tokenBucket = redis.NewScript(`
+local limit = tonumber(ARGV[1])
+local per_ms = tonumber(ARGV[2])
+local t = redis.call('TIME')
+local now = t[1] * 1000 + math.floor(t[2] / 1000)
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1]) or limit
+local ts = tonumber(state[2]) or now
+tokens = math.min(limit, tokens + (now - ts) * per_ms)
+
+local allowed, wait = 0, 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	wait = math.ceil((1 - tokens) / per_ms)
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil(limit / per_ms) + 1000)
+return {allowed, wait}
+`)
+
+type redisLimiter struct {
+	client *redis.Client
+}
+
+func (r *redisLimiter) Allow(ctx context.Context, key string, rate Rate) (bool, time.Duration, error) {
+	res, err := tokenBucket.Run(ctx, r.client, []string{keyPrefix + key}, rate.Limit, rate.perSecond()/1000).Int64Slice()
+	if err != nil {
+		return false, 0, err
+	}
+	return res[0] == 1, time.Duration(res[1]) * time.Millisecond, nil
+}
+
+type redisLockout struct {
+	client *redis.Client
+}
+
+func lockoutKey(kind, key string) string {
+	return keyPrefix + "login:" + kind + ":" + key
+}
+
+// lockoutKeys are the keys of account and of account from ip, whose state is kept apart.
+func lockoutKeys(account, ip string) []string {
+	return []string{"account:" + account, "client:" + clientKey(account, ip)}
+}
+
+func (r *redisLockout) Locked(ctx context.Context, account, ip string) (time.Duration, error) {
+	var wait time.Duration
+	for _, key := range lockoutKeys(account, ip) {
+		ttl, err := r.client.PTTL(ctx, lockoutKey("lock", key)).Result()
+		if err != nil {
+			return 0, err
+		}
+		wait = max(wait, ttl)
+	}
+	return wait, nil
+}
+
+func (r *redisLockout) Fail(ctx context.Context, account, ip string) (time.Duration, error) {
+	keys := lockoutKeys(account, ip)
+	locked, err := r.fail(ctx, keys[0], Policy.AccountMaxFailures)
+	if err != nil {
+		return 0, err
+	}
+	client, err := r.fail(ctx, keys[1], Policy.MaxFailures)
+	return max(locked, client), err
+}
+
+// fail counts a failure of key and returns the lockout it triggered, if any.
+func (r *redisLockout) fail(ctx context.Context, key string, maxFailures int) (time.Duration, error) {
+	failuresKey := lockoutKey("failures", key)
+	pipe := r.client.TxPipeline()
+	failures := pipe.Incr(ctx, failuresKey)
+	pipe.ExpireNX(ctx, failuresKey, Policy.Window)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	if failures.Val() < int64(maxFailures) {
+		return 0, nil
+	}
+
+	strikesKey := lockoutKey("strikes", key)
+	pipe = r.client.TxPipeline()
+	strikes := pipe.Incr(ctx, strikesKey)
+	pipe.Expire(ctx, strikesKey, Policy.strikeTTL())
+	pipe.Del(ctx, failuresKey)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+
+	d := Policy.backoff(int(strikes.Val()) - 1)
+	return d, r.client.Set(ctx, lockoutKey("lock", key), 1, d).Err()
+}
+
+func (r *redisLockout) Reset(ctx context.Context, account, ip string) error {
+	var keys []string
+	for _, key := range lockoutKeys(account, ip) {
+		keys = append(keys, lockoutKey("failures", key), lockoutKey("strikes", key))
+	}
+	return r.client.Del(ctx, keys...).Err()
+}
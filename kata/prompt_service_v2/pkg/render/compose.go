@@ -0,0 +1,253 @@
+// pkg/render/compose.go
+package render
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/walterfan/prompt-service/pkg/models"
+)
+
+const (
+	RefPartial = "partial" // included with {{> name}}
+	RefBase    = "base"    // named by Extends
+
+	// maxDepth bounds how deep partials and bases may nest.
+	maxDepth = 10
+)
+
+var (
+	// partial matches {{> name}} and {{> name@3}}; names may contain spaces
+	partial = regexp.MustCompile(`\{\{\s*>\s*([^{}@]+?)\s*(?:@(\d+))?\s*\}\}`)
+	// block matches {{#block name}}content{{/block}}; blocks don't nest
+	block      = regexp.MustCompile(`(?s)\{\{#block\s+([A-Za-z_][A-Za-z0-9_-]*)\s*\}\}(.*?)\{\{/block\}\}`)
+	blockOpen  = regexp.MustCompile(`\{\{#block\b`)
+	blockClose = regexp.MustCompile(`\{\{/block\}\}`)
+)
+
+// Resolver finds a prompt by name for composition, at revision version or the
+// current one when version is 0.
+type Resolver interface {
+	Resolve(name string, version int) (*models.Prompt, error)
+}
+
+// Reference is a prompt revision used while expanding another.
+type Reference struct {
+	Kind     string `json:"kind"`
+	Name     string `json:"name"`
+	PromptID uint   `json:"promptId"`
+	Version  int    `json:"version"`
+}
+
+// CompositionError is returned when a prompt's partials or base can't be expanded.
+// Path is the chain of prompt names, set for cycles.
+type CompositionError struct {
+	Message string   `json:"message"`
+	Path    []string `json:"path,omitempty"`
+}
+
+func (e *CompositionError) Error() string {
+	return e.Message
+}
+
+func compositionError(format string, args ...interface{}) *CompositionError {
+	return &CompositionError{Message: fmt.Sprintf(format, args...)}
+}
+
+// ParseRef splits a reference like "name@3" into the name and the pinned revision,
+// 0 when none is pinned.
+func ParseRef(ref string) (string, int) {
+	ref = strings.TrimSpace(ref)
+	if at := strings.LastIndex(ref, "@"); at > 0 {
+		if v, err := strconv.Atoi(ref[at+1:]); err == nil && v > 0 {
+			return strings.TrimSpace(ref[:at]), v
+		}
+	}
+	return ref, 0
+}
+
+// Expand returns a copy of p with its base prompt applied and its partials inserted,
+// ready for Render, along with the revisions it used in order of first use.
+//
+// A prompt that extends a base takes the base's system and user prompts with the
+// {{#block name}} contents replaced by the blocks of the same name it defines. A field
+// with any text outside blocks replaces the base's field instead. {{> name}} inserts
+// the same field of the named prompt, or its other field when that one is empty.
+// Variables declared by bases and partials apply unless p declares them too.
+func Expand(p *models.Prompt, r Resolver) (*models.Prompt, []Reference, error) {
+	e := &expander{resolver: r, stack: []string{p.Name}, seen: map[Reference]bool{}}
+	system, user, vars, err := e.inherit(p)
+	if err != nil {
+		return nil, nil, err
+	}
+	system, vars, err = e.include(system, true, vars)
+	if err != nil {
+		return nil, nil, err
+	}
+	user, vars, err = e.include(user, false, vars)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	out := *p
+	out.SystemPrompt = stripBlocks(system)
+	out.UserPrompt = stripBlocks(user)
+	out.Variables = vars
+	if e.refs == nil {
+		e.refs = []Reference{}
+	}
+	return &out, e.refs, nil
+}
+
+type expander struct {
+	resolver Resolver
+	stack    []string // names being expanded, to detect cycles
+	refs     []Reference
+	seen     map[Reference]bool
+}
+
+// load resolves a referenced prompt and pushes it on the stack; the caller pops it.
+func (e *expander) load(kind, name string, version int) (*models.Prompt, error) {
+	for i, n := range e.stack {
+		if n == name {
+			path := append(append([]string{}, e.stack[i:]...), name)
+			return nil, &CompositionError{Message: "cycle: " + strings.Join(path, " -> "), Path: path}
+		}
+	}
+	if len(e.stack) > maxDepth {
+		return nil, compositionError("%s %q is nested more than %d levels deep", kind, name, maxDepth)
+	}
+
+	p, err := e.resolver.Resolve(name, version)
+	if err != nil {
+		return nil, compositionError("%s %q: %v", kind, name, err)
+	}
+	e.stack = append(e.stack, name)
+
+	used := Reference{Kind: kind, Name: p.Name, PromptID: p.ID, Version: p.Version}
+	if !e.seen[used] {
+		e.seen[used] = true
+		e.refs = append(e.refs, used)
+	}
+	return p, nil
+}
+
+func (e *expander) pop() {
+	e.stack = e.stack[:len(e.stack)-1]
+}
+
+// inherit applies p's base, recursively, keeping the block markers so that the
+// most derived prompt's blocks win.
+func (e *expander) inherit(p *models.Prompt) (string, string, []models.PromptVariable, error) {
+	if err := checkBlocks(p); err != nil {
+		return "", "", nil, err
+	}
+	if strings.TrimSpace(p.Extends) == "" {
+		return p.SystemPrompt, p.UserPrompt, p.Variables, nil
+	}
+
+	name, version := ParseRef(p.Extends)
+	base, err := e.load(RefBase, name, version)
+	if err != nil {
+		return "", "", nil, err
+	}
+	defer e.pop()
+	system, user, vars, err := e.inherit(base)
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	overrides := map[string]string{}
+	for _, text := range []string{p.SystemPrompt, p.UserPrompt} {
+		for _, m := range block.FindAllStringSubmatch(text, -1) {
+			overrides[m[1]] = m[2]
+		}
+	}
+	return override(p.SystemPrompt, system, overrides), override(p.UserPrompt, user, overrides),
+		mergeVariables(p.Variables, vars), nil
+}
+
+// override returns own when it has text outside blocks, else base with its blocks overridden.
+func override(own, base string, blocks map[string]string) string {
+	if strings.TrimSpace(block.ReplaceAllString(own, "")) != "" {
+		return own
+	}
+	return block.ReplaceAllStringFunc(base, func(m string) string {
+		name := block.FindStringSubmatch(m)[1]
+		if content, ok := blocks[name]; ok {
+			return "{{#block " + name + "}}" + content + "{{/block}}"
+		}
+		return m
+	})
+}
+
+// include replaces the partials in text with their expanded content.
+func (e *expander) include(text string, system bool, vars []models.PromptVariable) (string, []models.PromptVariable, error) {
+	var b strings.Builder
+	last := 0
+	for _, loc := range partial.FindAllStringSubmatchIndex(text, -1) {
+		b.WriteString(text[last:loc[0]])
+		last = loc[1]
+
+		version := 0
+		if loc[4] >= 0 {
+			version, _ = strconv.Atoi(text[loc[4]:loc[5]])
+		}
+		p, err := e.load(RefPartial, text[loc[2]:loc[3]], version)
+		if err != nil {
+			return "", nil, err
+		}
+		ps, pu, pvars, err := e.inherit(p)
+		if err != nil {
+			return "", nil, err
+		}
+		content := pu
+		if system && ps != "" || pu == "" {
+			content = ps
+		}
+		content, pvars, err = e.include(content, system, pvars)
+		if err != nil {
+			return "", nil, err
+		}
+		e.pop()
+
+		b.WriteString(content)
+		vars = mergeVariables(vars, pvars)
+	}
+	b.WriteString(text[last:])
+	return b.String(), vars, nil
+}
+
+// checkBlocks rejects unclosed and nested blocks.
+func checkBlocks(p *models.Prompt) error {
+	for _, text := range []string{p.SystemPrompt, p.UserPrompt} {
+		opens := len(blockOpen.FindAllStringIndex(text, -1))
+		closes := len(blockClose.FindAllStringIndex(text, -1))
+		if opens != closes || len(block.FindAllStringIndex(text, -1)) != opens {
+			return compositionError("prompt %q has unclosed or nested blocks", p.Name)
+		}
+	}
+	return nil
+}
+
+func stripBlocks(text string) string {
+	return block.ReplaceAllString(text, "$2")
+}
+
+// mergeVariables appends the declarations of extra that own doesn't declare.
+func mergeVariables(own, extra []models.PromptVariable) []models.PromptVariable {
+	vars := append([]models.PromptVariable{}, own...)
+	declared := map[string]bool{}
+	for _, v := range vars {
+		declared[v.Name] = true
+	}
+	for _, v := range extra {
+		if !declared[v.Name] {
+			declared[v.Name] = true
+			vars = append(vars, v)
+		}
+	}
+	return vars
+}
@@ -0,0 +1,154 @@
+package render
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/walterfan/prompt-service/pkg/models"
+)
+
+// library resolves prompts by name; revisions maps "name@N" to older revisions.
+type library struct {
+	prompts   map[string]*models.Prompt
+	revisions map[string]*models.Prompt
+}
+
+func (l library) Resolve(name string, version int) (*models.Prompt, error) {
+	p, ok := l.prompts[name]
+	if !ok {
+		return nil, errors.New("prompt not found")
+	}
+	if version == 0 || version == p.Version {
+		return p, nil
+	}
+	if old, ok := l.revisions[name+"@"+strconv.Itoa(version)]; ok {
+		return old, nil
+	}
+	return nil, errors.New("version not found")
+}
+
+func newLibrary(prompts ...*models.Prompt) library {
+	l := library{prompts: map[string]*models.Prompt{}, revisions: map[string]*models.Prompt{}}
+	for i, p := range prompts {
+		p.ID = uint(i + 1)
+		if p.Version == 0 {
+			p.Version = 1
+		}
+		l.prompts[p.Name] = p
+	}
+	return l
+}
+
+func TestExpand(t *testing.T) {
+	lib := newLibrary(
+		&models.Prompt{Name: "persona", SystemPrompt: "You are a {{tone}} reviewer.", Version: 2,
+			Variables: []models.PromptVariable{{Name: "tone", Type: TypeString, Default: "strict"}}},
+		&models.Prompt{Name: "json output", UserPrompt: "Answer in JSON."},
+		&models.Prompt{
+			Name:         "review",
+			SystemPrompt: "{{> persona}}",
+			UserPrompt:   "{{#block task}}Review this.{{/block}}\n{{#block format}}Answer in prose.{{/block}}",
+		},
+	)
+	lib.revisions["persona@1"] = &models.Prompt{ID: 1, Name: "persona", SystemPrompt: "You review code.", Version: 1}
+
+	tests := []struct {
+		name         string
+		prompt       models.Prompt
+		system, user string
+		uses         []Reference
+	}{
+		{
+			name:   "partials",
+			prompt: models.Prompt{Name: "p", SystemPrompt: "{{> persona@1}}", UserPrompt: "{{ > json output }} {{code}}"},
+			system: "You review code.",
+			user:   "Answer in JSON. {{code}}",
+			uses:   []Reference{{RefPartial, "persona", 1, 1}, {RefPartial, "json output", 2, 1}},
+		},
+		{
+			name:   "blocks override the base",
+			prompt: models.Prompt{Name: "p", Extends: "review", UserPrompt: "{{#block format}}{{> json output}}{{/block}}"},
+			system: "You are a {{tone}} reviewer.",
+			user:   "Review this.\nAnswer in JSON.",
+			uses:   []Reference{{RefBase, "review", 3, 1}, {RefPartial, "persona", 1, 2}, {RefPartial, "json output", 2, 1}},
+		},
+		{
+			name:   "text outside blocks replaces the field",
+			prompt: models.Prompt{Name: "p", Extends: "review", SystemPrompt: "Be brief.", UserPrompt: "{{#block task}}Review {{code}}.{{/block}}"},
+			system: "Be brief.",
+			user:   "Review {{code}}.\nAnswer in prose.",
+			uses:   []Reference{{RefBase, "review", 3, 1}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, uses, err := Expand(&tt.prompt, lib)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out.SystemPrompt != tt.system || out.UserPrompt != tt.user {
+				t.Errorf("expanded to %q / %q, want %q / %q", out.SystemPrompt, out.UserPrompt, tt.system, tt.user)
+			}
+			if !reflect.DeepEqual(uses, tt.uses) {
+				t.Errorf("uses = %+v, want %+v", uses, tt.uses)
+			}
+		})
+	}
+
+	// variables of partials apply with their declarations
+	out, _, err := Expand(&models.Prompt{Name: "p", Extends: "review"}, lib)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := Render(out, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Messages[0].Content != "You are a strict reviewer." {
+		t.Errorf("rendered %+v", result.Messages)
+	}
+}
+
+func TestExpandErrors(t *testing.T) {
+	lib := newLibrary(
+		&models.Prompt{Name: "a", UserPrompt: "{{> b}}"},
+		&models.Prompt{Name: "b", UserPrompt: "{{> c}}"},
+		&models.Prompt{Name: "c", Extends: "a"},
+	)
+
+	_, _, err := Expand(lib.prompts["a"], lib)
+	var cerr *CompositionError
+	if !errors.As(err, &cerr) || !reflect.DeepEqual(cerr.Path, []string{"a", "b", "c", "a"}) {
+		t.Fatalf("err = %v, want the cycle a -> b -> c -> a", err)
+	}
+
+	for _, p := range []models.Prompt{
+		{Name: "self", Extends: "self"},
+		{Name: "missing", UserPrompt: "{{> nothing}}"},
+		{Name: "unpinned", UserPrompt: "{{> c@7}}"},
+		{Name: "unclosed", UserPrompt: "{{#block a}}x"},
+		{Name: "nested", UserPrompt: "{{#block a}}{{#block b}}x{{/block}}{{/block}}"},
+	} {
+		if _, _, err := Expand(&p, lib); !errors.As(err, &cerr) {
+			t.Errorf("%s: err = %v, want a composition error", p.Name, err)
+		}
+	}
+}
+
+func TestParseRef(t *testing.T) {
+	for ref, want := range map[string]struct {
+		name    string
+		version int
+	}{
+		"base":          {"base", 0},
+		" base@3 ":      {"base", 3},
+		"team@work":     {"team@work", 0},
+		"code review@0": {"code review@0", 0},
+	} {
+		if name, version := ParseRef(ref); name != want.name || version != want.version {
+			t.Errorf("ParseRef(%q) = %q, %d", ref, name, version)
+		}
+	}
+}
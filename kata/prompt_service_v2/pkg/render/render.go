@@ -0,0 +1,249 @@
+// pkg/render/render.go
+package render
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/walterfan/prompt-service/pkg/models"
+)
+
+const (
+	TypeString  = "string"
+	TypeNumber  = "number"
+	TypeInteger = "integer"
+	TypeBoolean = "boolean"
+)
+
+// placeholder matches {{name}} and {{ name }}
+var placeholder = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+
+var validName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Message is one chat message of a rendered prompt.
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// Result is a rendered prompt ready to be sent to an LLM.
+type Result struct {
+	Messages  []Message         `json:"messages"`
+	Variables map[string]string `json:"variables"`
+}
+
+// FieldError describes why a single variable was rejected.
+type FieldError struct {
+	Variable string `json:"variable"`
+	Reason   string `json:"reason"`
+}
+
+// ValidationError is returned when the supplied variables don't match the declarations.
+type ValidationError struct {
+	Errors []FieldError `json:"errors"`
+}
+
+func (e *ValidationError) Error() string {
+	parts := make([]string, 0, len(e.Errors))
+	for _, fe := range e.Errors {
+		parts = append(parts, fe.Variable+": "+fe.Reason)
+	}
+	return "invalid variables: " + strings.Join(parts, "; ")
+}
+
+func (e *ValidationError) add(variable, reason string) {
+	e.Errors = append(e.Errors, FieldError{Variable: variable, Reason: reason})
+}
+
+// Placeholders returns the distinct variable names referenced by the templates, in order.
+func Placeholders(templates ...string) []string {
+	seen := map[string]bool{}
+	var names []string
+	for _, t := range templates {
+		for _, m := range placeholder.FindAllStringSubmatch(t, -1) {
+			if !seen[m[1]] {
+				seen[m[1]] = true
+				names = append(names, m[1])
+			}
+		}
+	}
+	return names
+}
+
+// Declarations returns the prompt's declared variables plus an implicit required
+// string variable for every placeholder that isn't declared.
+func Declarations(p *models.Prompt) []models.PromptVariable {
+	vars := append([]models.PromptVariable{}, p.Variables...)
+	declared := map[string]bool{}
+	for _, v := range vars {
+		declared[v.Name] = true
+	}
+	for _, name := range Placeholders(p.SystemPrompt, p.UserPrompt) {
+		if !declared[name] {
+			vars = append(vars, models.PromptVariable{Name: name, Type: TypeString, Required: true})
+		}
+	}
+	return vars
+}
+
+// ValidateDefinitions checks variable declarations when a prompt is saved.
+func ValidateDefinitions(vars []models.PromptVariable) error {
+	verr := &ValidationError{}
+	seen := map[string]bool{}
+	for _, v := range vars {
+		switch {
+		case !validName.MatchString(v.Name):
+			verr.add(v.Name, "invalid name")
+		case seen[v.Name]:
+			verr.add(v.Name, "declared more than once")
+		case !knownType(v.Type):
+			verr.add(v.Name, fmt.Sprintf("unknown type %q", v.Type))
+		case v.Default != "":
+			if _, err := convert(v.Type, v.Default); err != nil {
+				verr.add(v.Name, "invalid default: "+err.Error())
+			}
+		}
+		seen[v.Name] = true
+	}
+	if len(verr.Errors) > 0 {
+		return verr
+	}
+	return nil
+}
+
+// Render validates values against the prompt's variables and substitutes them
+// into the system and user prompts.
+func Render(p *models.Prompt, values map[string]interface{}) (*Result, error) {
+	decls := Declarations(p)
+	verr := &ValidationError{}
+
+	declared := map[string]bool{}
+	for _, d := range decls {
+		declared[d.Name] = true
+	}
+	var unknown []string
+	for name := range values {
+		if !declared[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+	for _, name := range unknown {
+		verr.add(name, "unknown variable")
+	}
+
+	resolved := map[string]string{}
+	for _, d := range decls {
+		value, ok := values[d.Name]
+		if !ok || value == nil {
+			switch {
+			case d.Default != "":
+				value = d.Default
+			case d.Required:
+				verr.add(d.Name, "missing required variable")
+				continue
+			default:
+				resolved[d.Name] = ""
+				continue
+			}
+		}
+
+		s, err := convert(typeOrString(d.Type), value)
+		if err != nil {
+			verr.add(d.Name, err.Error())
+			continue
+		}
+		resolved[d.Name] = s
+	}
+
+	if len(verr.Errors) > 0 {
+		return nil, verr
+	}
+
+	result := &Result{Variables: resolved}
+	if p.SystemPrompt != "" {
+		result.Messages = append(result.Messages, Message{Role: "system", Content: substitute(p.SystemPrompt, resolved)})
+	}
+	result.Messages = append(result.Messages, Message{Role: "user", Content: substitute(p.UserPrompt, resolved)})
+	return result, nil
+}
+
+func substitute(template string, values map[string]string) string {
+	return placeholder.ReplaceAllStringFunc(template, func(m string) string {
+		name := placeholder.FindStringSubmatch(m)[1]
+		return values[name]
+	})
+}
+
+func knownType(t string) bool {
+	switch t {
+	case "", TypeString, TypeNumber, TypeInteger, TypeBoolean:
+		return true
+	}
+	return false
+}
+
+func typeOrString(t string) string {
+	if t == "" {
+		return TypeString
+	}
+	return t
+}
+
+// convert checks that value matches the variable type and formats it for substitution.
+// Strings are accepted for every type so defaults and form values can be parsed.
+func convert(varType string, value interface{}) (string, error) {
+	switch typeOrString(varType) {
+	case TypeString:
+		switch v := value.(type) {
+		case string:
+			return v, nil
+		default:
+			return "", fmt.Errorf("expected string, got %T", value)
+		}
+	case TypeNumber:
+		switch v := value.(type) {
+		case float64:
+			return strconv.FormatFloat(v, 'f', -1, 64), nil
+		case string:
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return "", fmt.Errorf("expected number, got %q", v)
+			}
+			return strconv.FormatFloat(f, 'f', -1, 64), nil
+		}
+		return "", fmt.Errorf("expected number, got %T", value)
+	case TypeInteger:
+		switch v := value.(type) {
+		case float64:
+			if v != math.Trunc(v) {
+				return "", fmt.Errorf("expected integer, got %v", v)
+			}
+			return strconv.FormatInt(int64(v), 10), nil
+		case string:
+			i, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return "", fmt.Errorf("expected integer, got %q", v)
+			}
+			return strconv.FormatInt(i, 10), nil
+		}
+		return "", fmt.Errorf("expected integer, got %T", value)
+	case TypeBoolean:
+		switch v := value.(type) {
+		case bool:
+			return strconv.FormatBool(v), nil
+		case string:
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return "", fmt.Errorf("expected boolean, got %q", v)
+			}
+			return strconv.FormatBool(b), nil
+		}
+		return "", fmt.Errorf("expected boolean, got %T", value)
+	}
+	return "", fmt.Errorf("unknown type %q", varType)
+}
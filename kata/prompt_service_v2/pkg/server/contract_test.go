@@ -0,0 +1,202 @@
+package server_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/walterfan/prompt-service/pkg/handlers"
+	"github.com/walterfan/prompt-service/pkg/llm"
+	"github.com/walterfan/prompt-service/pkg/openapi"
+)
+
+// greeter is a fake LLM answering "Hello, <what the prompt says hello to>!", and
+// passing every output it is asked to judge.
+type greeter struct{}
+
+func (greeter) ChatStream(ctx context.Context, req llm.ChatRequest, onToken func(string) error) (*llm.ChatResponse, error) {
+	user := req.Messages[len(req.Messages)-1].Content
+	content := "Hello, " + strings.TrimPrefix(user, "Say hello to ") + "!"
+	if strings.HasPrefix(user, "Criterion:") {
+		content = `{"pass": true, "reason": "polite"}`
+	}
+	if err := onToken(content); err != nil {
+		return nil, err
+	}
+	return &llm.ChatResponse{Model: req.Model, Content: content, Usage: llm.Usage{TotalTokens: 7}}, nil
+}
+
+func TestOpenAPIMatchesRoutes(t *testing.T) {
+	r := setup(t, backend{"sqlite", filepath.Join(t.TempDir(), "prompt_test.db")})
+	if _, err := openapi.Build(handlers.APISpec, r.Routes()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestContract calls every documented operation and validates each response against
+// the document served at /openapi.json.
+func TestContract(t *testing.T) {
+	r := setup(t, backend{"sqlite", filepath.Join(t.TempDir(), "prompt_test.db")})
+
+	var doc openapi.Document
+	(&client{h: r}).do(t, "GET", "/openapi.json", nil, &doc, http.StatusOK)
+	(&client{h: r}).do(t, "GET", "/docs", nil, nil, http.StatusOK)
+
+	anonymous := &client{h: r, doc: &doc, covered: map[string]bool{}}
+	admin := anonymous.login(t, "admin", adminPassword)
+
+	var p struct {
+		ID uint `json:"id"`
+	}
+	admin.do(t, "POST", "/api/v1/prompts/", map[string]interface{}{
+		"name":       "Greeting",
+		"userPrompt": "Say hello to {{name}}",
+		"tags":       "greeting",
+		"variables":  []map[string]interface{}{{"name": "name", "type": "string", "required": true}},
+	}, &p, http.StatusOK)
+	prompt := fmt.Sprintf("/api/v1/prompts/%d", p.ID)
+
+	admin.do(t, "POST", "/api/v1/prompts/", map[string]string{"name": "Bad", "visibility": "everyone"}, nil, http.StatusBadRequest)
+	etag := admin.do(t, "GET", prompt, nil, nil, http.StatusOK).Get("ETag")
+	admin.with("If-None-Match", etag).do(t, "GET", prompt, nil, nil, http.StatusNotModified)
+	admin.do(t, "GET", "/api/v1/prompts/99999", nil, nil, http.StatusNotFound)
+	admin.do(t, "PUT", prompt, map[string]string{"desc": "Greets someone", "changeNote": "describe"}, nil, http.StatusOK)
+	admin.do(t, "GET", prompt+"?version=1", nil, nil, http.StatusOK)
+	anonymous.do(t, "GET", "/api/v1/prompts/", nil, nil, http.StatusUnauthorized)
+	etag = admin.do(t, "GET", "/api/v1/prompts/?q=hello&tag=greeting", nil, nil, http.StatusOK).Get("ETag")
+	admin.with("If-None-Match", etag).do(t, "GET", "/api/v1/prompts/?q=hello&tag=greeting", nil, nil, http.StatusNotModified)
+	admin.do(t, "GET", "/api/v1/prompts/?sort=size", nil, nil, http.StatusBadRequest)
+
+	admin.do(t, "GET", "/api/v1/prompts/export?format=json", nil, nil, http.StatusOK)
+	admin.do(t, "GET", "/api/v1/prompts/export?format=yaml", nil, nil, http.StatusOK)
+	admin.do(t, "POST", "/api/v1/prompts/import?dryRun=true", map[string]interface{}{
+		"prompts": []map[string]string{{"name": "Imported", "userPrompt": "Hi"}},
+	}, nil, http.StatusOK)
+
+	admin.do(t, "GET", prompt+"/versions", nil, nil, http.StatusOK)
+	admin.do(t, "GET", prompt+"/versions/1", nil, nil, http.StatusOK)
+	admin.do(t, "GET", prompt+"/versions/diff?from=1", nil, nil, http.StatusOK)
+	admin.do(t, "POST", prompt+"/versions/1/rollback", map[string]string{}, nil, http.StatusOK)
+	admin.do(t, "POST", prompt+"/render", map[string]interface{}{"variables": map[string]string{"name": "Ada"}}, nil, http.StatusOK)
+	admin.do(t, "POST", prompt+"/render", map[string]interface{}{}, nil, http.StatusUnprocessableEntity)
+	admin.do(t, "POST", prompt+"/run", map[string]interface{}{"variables": map[string]string{"name": "Ada"}}, nil, http.StatusServiceUnavailable)
+
+	var persona, polite struct {
+		ID uint `json:"id"`
+	}
+	admin.do(t, "POST", "/api/v1/prompts/", map[string]string{"name": "Persona", "systemPrompt": "You are kind."}, &persona, http.StatusOK)
+	admin.do(t, "POST", "/api/v1/prompts/", map[string]string{
+		"name": "Polite greeting", "extends": "Greeting", "systemPrompt": "{{> Persona}}",
+	}, &polite, http.StatusOK)
+	var expanded struct {
+		SystemPrompt string `json:"systemPrompt"`
+		UserPrompt   string `json:"userPrompt"`
+		Uses         []struct {
+			Kind string `json:"kind"`
+			Name string `json:"name"`
+		} `json:"uses"`
+	}
+	admin.do(t, "GET", fmt.Sprintf("/api/v1/prompts/%d/expanded", polite.ID), nil, &expanded, http.StatusOK)
+	if expanded.SystemPrompt != "You are kind." || expanded.UserPrompt != "Say hello to {{name}}" || len(expanded.Uses) != 2 {
+		t.Errorf("expanded = %+v", expanded)
+	}
+	admin.do(t, "PUT", fmt.Sprintf("/api/v1/prompts/%d", persona.ID), map[string]string{"systemPrompt": "{{> Polite greeting}}"}, nil, http.StatusUnprocessableEntity)
+	admin.do(t, "GET", prompt+"/runs", nil, nil, http.StatusOK)
+
+	var tc struct {
+		ID uint `json:"id"`
+	}
+	admin.do(t, "POST", prompt+"/testcases", map[string]interface{}{
+		"name":      "Ada",
+		"variables": map[string]string{"name": "Ada"},
+		"expected":  "Hello, Ada!",
+		"assertions": []map[string]interface{}{
+			{"type": "regex", "value": "^Hello"},
+			{"type": "llm_judge", "value": "The greeting is polite"},
+		},
+	}, &tc, http.StatusCreated)
+	admin.do(t, "POST", prompt+"/testcases", map[string]interface{}{
+		"name": "Bad", "assertions": []map[string]string{{"type": "regex", "value": "("}},
+	}, nil, http.StatusBadRequest)
+	admin.do(t, "GET", prompt+"/testcases", nil, nil, http.StatusOK)
+	admin.do(t, "POST", prompt+"/evaluate", map[string]string{"model": "fake"}, nil, http.StatusServiceUnavailable)
+
+	llm.Client = greeter{}
+	t.Cleanup(func() { llm.Client = nil })
+	var report struct {
+		ID     uint    `json:"id"`
+		Score  float64 `json:"score"`
+		Passed int     `json:"passed"`
+	}
+	admin.do(t, "POST", prompt+"/evaluate", map[string]interface{}{"model": "fake", "version": 1}, &report, http.StatusCreated)
+	if report.Score != 1 || report.Passed != 1 {
+		t.Errorf("evaluation of version 1 = %+v", report)
+	}
+	admin.do(t, "PUT", fmt.Sprintf("%s/testcases/%d", prompt, tc.ID), map[string]interface{}{
+		"name": "Ada", "variables": map[string]string{"name": "Ada"}, "expected": "Hi, Ada!",
+	}, nil, http.StatusOK)
+	admin.do(t, "POST", prompt+"/evaluate", map[string]interface{}{"model": "fake"}, &report, http.StatusCreated)
+	if report.Score != 0 || report.Passed != 0 {
+		t.Errorf("evaluation of the current version = %+v", report)
+	}
+	admin.do(t, "GET", prompt+"/evaluations?version=1", nil, nil, http.StatusOK)
+	admin.do(t, "GET", fmt.Sprintf("%s/evaluations/%d", prompt, report.ID), nil, nil, http.StatusOK)
+	admin.do(t, "GET", prompt+"/evaluations/compare?from=1&to=3", nil, nil, http.StatusOK)
+	admin.do(t, "GET", prompt+"/evaluations/compare?from=1&to=2", nil, nil, http.StatusNotFound)
+	admin.do(t, "DELETE", fmt.Sprintf("%s/testcases/%d", prompt, tc.ID), nil, nil, http.StatusOK)
+
+	var bob struct {
+		ID uint `json:"id"`
+	}
+	admin.do(t, "POST", "/api/v1/users/", map[string]string{
+		"username": "bob", "email": "bob@example.com", "password": bobPassword,
+	}, &bob, http.StatusOK)
+	user := fmt.Sprintf("/api/v1/users/%d", bob.ID)
+	admin.do(t, "GET", "/api/v1/users/?q=bob", nil, nil, http.StatusOK)
+	admin.do(t, "GET", user, nil, nil, http.StatusOK)
+	admin.do(t, "PUT", user, map[string]string{"team": "ml"}, nil, http.StatusOK)
+	admin.do(t, "POST", user+"/expiry", map[string]string{"extendBy": "720h"}, nil, http.StatusOK)
+
+	var key struct {
+		APIKey struct {
+			ID uint `json:"id"`
+		} `json:"apiKey"`
+	}
+	admin.do(t, "POST", user+"/apikeys", map[string]interface{}{"name": "ci", "scopes": []string{"prompts:read"}}, &key, http.StatusCreated)
+	admin.do(t, "GET", user+"/apikeys", nil, nil, http.StatusOK)
+	admin.do(t, "DELETE", fmt.Sprintf("%s/apikeys/%d", user, key.APIKey.ID), nil, nil, http.StatusOK)
+
+	asBob := anonymous.login(t, "bob", bobPassword)
+	asBob.do(t, "GET", prompt, nil, nil, http.StatusNotFound)
+	asBob.do(t, "GET", "/api/v1/users/", nil, nil, http.StatusForbidden)
+
+	var share struct {
+		ID uint `json:"id"`
+	}
+	admin.do(t, "POST", prompt+"/shares", map[string]string{"user": "bob", "role": "viewer"}, &share, http.StatusCreated)
+	admin.do(t, "GET", prompt+"/shares", nil, nil, http.StatusOK)
+	asBob.do(t, "GET", prompt, nil, nil, http.StatusOK)
+	asBob.do(t, "PUT", prompt, map[string]string{"desc": "mine now"}, nil, http.StatusForbidden)
+	admin.do(t, "DELETE", fmt.Sprintf("%s/shares/%d", prompt, share.ID), nil, nil, http.StatusOK)
+	admin.do(t, "POST", prompt+"/transfer", map[string]string{"owner": "bob"}, nil, http.StatusOK)
+
+	admin.do(t, "DELETE", prompt, nil, nil, http.StatusOK)
+	admin.do(t, "DELETE", user, nil, nil, http.StatusOK)
+
+	var missing []string
+	for path, item := range doc.Paths {
+		for method := range item {
+			if key := strings.ToUpper(method) + " " + path; !anonymous.covered[key] {
+				missing = append(missing, key)
+			}
+		}
+	}
+	sort.Strings(missing)
+	if len(missing) > 0 {
+		t.Errorf("operations not exercised: %s", strings.Join(missing, ", "))
+	}
+}
@@ -0,0 +1,39 @@
+//go:build sqlite_fts5
+
+package server_test
+
+import (
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/walterfan/prompt-service/pkg/database"
+)
+
+// Run with go test -tags sqlite_fts5 (make test); without the tag search uses LIKE.
+func TestFullTextSearch(t *testing.T) {
+	h := setup(t, backend{"sqlite", filepath.Join(t.TempDir(), "prompt_test.db")})
+	if !database.FTSEnabled {
+		t.Fatal("FTS5 is unavailable in a sqlite_fts5 build")
+	}
+	admin := (&client{h: h}).login(t, "admin", adminPassword)
+
+	admin.do(t, "POST", "/api/v1/prompts/", map[string]string{
+		"name": "Release Notes", "userPrompt": "Write release notes for {{version}}",
+	}, nil, http.StatusOK)
+	admin.do(t, "POST", "/api/v1/prompts/", map[string]string{
+		"name": "Changelog", "userPrompt": "List the changes",
+	}, nil, http.StatusOK)
+
+	var page struct {
+		Items []struct {
+			Name    string `json:"name"`
+			Snippet string `json:"snippet"`
+		} `json:"items"`
+	}
+	admin.do(t, "GET", "/api/v1/prompts/?q=relea", nil, &page, http.StatusOK)
+	if len(page.Items) != 1 || page.Items[0].Name != "Release Notes" || !strings.Contains(page.Items[0].Snippet, "<mark>") {
+		t.Errorf("search relea = %+v", page.Items)
+	}
+}
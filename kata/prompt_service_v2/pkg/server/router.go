@@ -0,0 +1,145 @@
+// pkg/server/router.go
+package server
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/walterfan/prompt-service/internal/log"
+	"github.com/walterfan/prompt-service/pkg/audit"
+	"github.com/walterfan/prompt-service/pkg/auth"
+	"github.com/walterfan/prompt-service/pkg/authz"
+	"github.com/walterfan/prompt-service/pkg/handlers"
+	"github.com/walterfan/prompt-service/pkg/metrics"
+	"github.com/walterfan/prompt-service/pkg/openapi"
+	"github.com/walterfan/prompt-service/pkg/ratelimit"
+	"github.com/walterfan/prompt-service/pkg/tracing"
+	"go.uber.org/zap"
+)
+
+// NewRouter registers the API routes with the initial rate limits, see
+// ratelimit.SetLimits. The database, authz and auth packages must be initialized first.
+func NewRouter(limits *ratelimit.Limits) *gin.Engine {
+	ratelimit.SetLimits(limits)
+
+	r := gin.New()
+	// a *gin.Context then carries the request context, with its span, to database.Ctx
+	r.ContextWithFallback = true
+	r.Use(gin.Recovery())
+	// probes are registered before the other middlewares so they aren't metered,
+	// traced, logged or rate limited
+	r.GET("/healthz", handlers.Healthz)
+	r.GET("/readyz", handlers.Readyz)
+
+	r.Use(metrics.MetricsMiddleware())
+	r.Use(tracing.Middleware())
+	r.Use(audit.RequestIDMiddleware())
+	r.Use(log.Middleware())
+	r.Use(ratelimit.ByIP())
+	r.Use(ratelimit.ByRoute())
+
+	r.GET("/metrics", gin.WrapH(promhttp.Handler()))
+	r.POST("/login", auth.LoginHandler)
+	r.POST("/refresh", auth.RefreshHandler)
+	r.POST("/logout", auth.JwtMiddleware(), auth.LogoutHandler)
+	r.POST("/register", auth.RegisterHandler)
+	r.POST("/password", auth.JwtMiddleware(), auth.ChangePasswordHandler)
+	r.POST("/password/reset/request", auth.RequestPasswordResetHandler)
+	r.POST("/password/reset", auth.ResetPasswordHandler)
+
+	api := r.Group("/api/v1/prompts")
+	api.Use(auth.AuthMiddleware())
+	api.Use(ratelimit.ByUser())
+	api.Use(authz.CasbinMiddleware())
+	{
+		api.POST("/", handlers.CreatePrompt)
+		api.GET("/:id", handlers.GetPrompt)
+		api.PUT("/:id", handlers.UpdatePrompt)
+		api.DELETE("/:id", handlers.DeletePrompt)
+		api.GET("/", handlers.SearchPrompts)
+		api.GET("/export", handlers.ExportPrompts)
+		api.POST("/import", handlers.ImportPrompts)
+
+		api.GET("/:id/versions", handlers.ListPromptVersions)
+		api.GET("/:id/versions/diff", handlers.DiffPromptVersions)
+		api.GET("/:id/versions/:version", handlers.GetPromptVersion)
+		api.POST("/:id/versions/:version/rollback", handlers.RollbackPrompt)
+		api.GET("/:id/expanded", handlers.ExpandPrompt)
+		api.POST("/:id/render", handlers.RenderPrompt)
+		api.POST("/:id/run", handlers.RunPrompt)
+		api.GET("/:id/runs", handlers.ListPromptRuns)
+
+		api.GET("/:id/testcases", handlers.ListTestCases)
+		api.POST("/:id/testcases", handlers.CreateTestCase)
+		api.PUT("/:id/testcases/:caseId", handlers.UpdateTestCase)
+		api.DELETE("/:id/testcases/:caseId", handlers.DeleteTestCase)
+		api.POST("/:id/evaluate", handlers.EvaluatePrompt)
+		api.GET("/:id/evaluations", handlers.ListEvaluations)
+		api.GET("/:id/evaluations/compare", handlers.CompareEvaluations)
+		api.GET("/:id/evaluations/:evaluationId", handlers.GetEvaluation)
+
+		api.GET("/:id/shares", handlers.ListPromptShares)
+		api.POST("/:id/shares", handlers.SharePrompt)
+		api.DELETE("/:id/shares/:shareId", handlers.UnsharePrompt)
+		api.POST("/:id/transfer", handlers.TransferPrompt)
+	}
+
+	tagApi := r.Group("/api/v1/tags")
+	tagApi.Use(auth.AuthMiddleware())
+	tagApi.Use(ratelimit.ByUser())
+	tagApi.Use(authz.CasbinMiddleware())
+	{
+		tagApi.GET("/", handlers.ListTags)
+		tagApi.PUT("/:name", handlers.RenameTag)
+	}
+
+	userApi := r.Group("/api/v1/users")
+	userApi.Use(auth.AuthMiddleware())
+	userApi.Use(ratelimit.ByUser())
+	userApi.Use(authz.CasbinMiddleware())
+	{
+		userApi.POST("/", handlers.CreateUser)
+		userApi.GET("/:id", handlers.GetUser)
+		userApi.PUT("/:id", handlers.UpdateUser)
+		userApi.DELETE("/:id", handlers.DeleteUser)
+		userApi.POST("/:id/expiry", handlers.ExtendUserExpiry)
+		userApi.GET("/", handlers.SearchUsers)
+
+		userApi.POST("/:id/apikeys", handlers.CreateAPIKey)
+		userApi.GET("/:id/apikeys", handlers.ListAPIKeys)
+		userApi.DELETE("/:id/apikeys/:keyId", handlers.RevokeAPIKey)
+	}
+
+	policyApi := r.Group("/api/v1/policies")
+	policyApi.Use(auth.AuthMiddleware())
+	policyApi.Use(ratelimit.ByUser())
+	policyApi.Use(authz.CasbinMiddleware())
+	policyApi.Use(authz.RequireRole(authz.AdminRole))
+	{
+		policyApi.GET("/", handlers.ListPolicies)
+		policyApi.POST("/", handlers.CreatePolicy)
+		policyApi.PUT("/", handlers.UpdatePolicy)
+		policyApi.DELETE("/", handlers.DeletePolicy)
+		policyApi.POST("/roles", handlers.AddRoleInheritance)
+		policyApi.DELETE("/roles", handlers.RemoveRoleInheritance)
+		policyApi.POST("/reload", handlers.ReloadPolicies)
+	}
+
+	auditApi := r.Group("/api/v1/audit")
+	auditApi.Use(auth.AuthMiddleware())
+	auditApi.Use(ratelimit.ByUser())
+	auditApi.Use(authz.CasbinMiddleware())
+	auditApi.Use(authz.RequireRole(authz.AdminRole))
+	{
+		auditApi.GET("/", handlers.ListAuditEntries)
+		auditApi.GET("/verify", handlers.VerifyAuditLog)
+	}
+
+	doc, err := openapi.Build(handlers.APISpec, r.Routes())
+	if err != nil {
+		zap.L().Warn("The OpenAPI document is out of date", zap.Error(err))
+	}
+	r.GET("/openapi.json", openapi.JSONHandler(doc))
+	r.GET("/docs", openapi.UIHandler("/openapi.json"))
+
+	return r
+}
@@ -0,0 +1,32 @@
+// pkg/store/redis.go
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Redis is the shared client, nil when REDIS_HOST isn't configured. Components that
+// can use Redis fall back to in-process state when it is nil.
+var Redis *redis.Client
+
+// InitRedis connects to Redis and checks the connection.
+func InitRedis(host string, port int, password string) error {
+	client := redis.NewClient(&redis.Options{
+		Addr:     fmt.Sprintf("%s:%d", host, port),
+		Password: password,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return fmt.Errorf("failed to connect to redis at %s:%d: %w", host, port, err)
+	}
+
+	Redis = client
+	return nil
+}
@@ -0,0 +1,85 @@
+// pkg/tracing/tracing.go
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// ExporterNone still creates spans, so logs carry trace IDs, but exports nothing.
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	// ExporterOTLP sends spans over OTLP/HTTP to OTEL_EXPORTER_OTLP_ENDPOINT,
+	// http://localhost:4318 by default.
+	ExporterOTLP = "otlp"
+)
+
+const instrumentationName = "github.com/walterfan/prompt-service"
+
+// tracer looks the tracer up on every use, since a tracer obtained before Init
+// would keep following the first provider installed.
+func tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Init installs the global tracer provider, sampling the given ratio of new traces
+// and following the caller's decision for propagated ones. The returned function
+// flushes the spans not exported yet.
+func Init(exporter, serviceName string, sampleRatio float64) (func(context.Context) error, error) {
+	opts := []sdktrace.TracerProviderOption{
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(sampleRatio))),
+	}
+	switch exporter {
+	case "", ExporterNone:
+	case ExporterStdout:
+		exp, err := stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, sdktrace.WithBatcher(exp))
+	case ExporterOTLP:
+		// the endpoint, headers and TLS come from the standard OTEL_EXPORTER_OTLP_* variables
+		exp, err := otlptracehttp.New(context.Background())
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, sdktrace.WithBatcher(exp))
+	default:
+		return nil, fmt.Errorf("unsupported tracing exporter: %s", exporter)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(semconv.ServiceName(serviceName)))
+	if err != nil {
+		return nil, err
+	}
+	provider := sdktrace.NewTracerProvider(append(opts, sdktrace.WithResource(res))...)
+	install(provider)
+	return provider.Shutdown, nil
+}
+
+// InitInMemory records every span in the returned exporter as soon as it ends, for tests.
+func InitInMemory() *tracetest.InMemoryExporter {
+	exp := tracetest.NewInMemoryExporter()
+	install(sdktrace.NewTracerProvider(
+		sdktrace.WithSampler(sdktrace.AlwaysSample()),
+		sdktrace.WithSyncer(exp),
+	))
+	return exp
+}
+
+func install(provider trace.TracerProvider) {
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+}
@@ -0,0 +1,144 @@
+package tracing_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/walterfan/prompt-service/internal/log"
+	"github.com/walterfan/prompt-service/pkg/audit"
+	"github.com/walterfan/prompt-service/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type item struct {
+	ID   uint
+	Name string
+}
+
+const (
+	traceID  = "4bf92f3577b34da6a3ce929d0e0e4736"
+	parentID = "00f067aa0ba902b7"
+)
+
+func setup(t *testing.T) (*gin.Engine, *tracetest.InMemoryExporter, *observer.ObservedLogs) {
+	gin.SetMode(gin.TestMode)
+	spans := tracing.InitInMemory()
+
+	core, logs := observer.New(zap.InfoLevel)
+	t.Cleanup(zap.ReplaceGlobals(zap.New(core)))
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "tracing.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Use(tracing.GormPlugin()); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&item{}); err != nil {
+		t.Fatal(err)
+	}
+	db.Create(&item{Name: "first"})
+
+	r := gin.New()
+	r.ContextWithFallback = true
+	r.Use(tracing.Middleware(), audit.RequestIDMiddleware(), log.Middleware())
+	r.GET("/items/:id", func(c *gin.Context) {
+		var it item
+		if err := db.WithContext(c).First(&it, c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		log.Ctx(c).Info("Found item")
+		c.JSON(http.StatusOK, it)
+	})
+	return r, spans, logs
+}
+
+func TestRequestSpans(t *testing.T) {
+	r, spans, logs := setup(t)
+	// the migration and insert above ran outside a trace
+	if n := len(spans.GetSpans()); n != 0 {
+		t.Fatalf("%d spans before the request, want 0", n)
+	}
+
+	req := httptest.NewRequest("GET", "/items/1", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-"+parentID+"-01")
+	req.Header.Set(audit.RequestIDHeader, "req-1")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+	}
+
+	ended := spans.GetSpans()
+	if len(ended) != 2 {
+		t.Fatalf("%d spans, want the query and the request: %v", len(ended), ended)
+	}
+	query, server := ended[0], ended[1]
+	if server.Name != "GET /items/:id" || server.Parent.SpanID().String() != parentID {
+		t.Errorf("server span %q with parent %s", server.Name, server.Parent.SpanID())
+	}
+	if server.SpanContext.TraceID().String() != traceID {
+		t.Errorf("trace %s, want the incoming %s", server.SpanContext.TraceID(), traceID)
+	}
+	attrs := attribute.NewSet(server.Attributes...)
+	if v, _ := attrs.Value("http.response.status_code"); v.AsInt64() != http.StatusOK {
+		t.Errorf("status attribute = %v", v)
+	}
+	if v, _ := attrs.Value("request.id"); v.AsString() != "req-1" {
+		t.Errorf("request.id attribute = %v", v)
+	}
+
+	if query.Name != "gorm.query" || query.Parent.SpanID() != server.SpanContext.SpanID() {
+		t.Errorf("query span %q with parent %s, want a child of %s", query.Name, query.Parent.SpanID(), server.SpanContext.SpanID())
+	}
+	queryAttrs := attribute.NewSet(query.Attributes...)
+	if v, _ := queryAttrs.Value("db.collection.name"); v.AsString() != "items" {
+		t.Errorf("db.collection.name = %v", v)
+	}
+
+	entries := logs.All()
+	if len(entries) != 2 || entries[0].Message != "Found item" || entries[1].Message != "Request" {
+		t.Fatalf("logged %v", entries)
+	}
+	for _, entry := range entries {
+		fields := entry.ContextMap()
+		if fields["trace_id"] != traceID || fields["request_id"] != "req-1" {
+			t.Errorf("%q logged with %v", entry.Message, fields)
+		}
+	}
+}
+
+func TestFailedRequestSpan(t *testing.T) {
+	r, spans, logs := setup(t)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/items/2", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d", w.Code)
+	}
+
+	ended := spans.GetSpans()
+	if len(ended) != 2 {
+		t.Fatalf("%d spans, want 2", len(ended))
+	}
+	// a missing row is not a query error
+	if ended[0].Status.Code == codes.Error {
+		t.Errorf("query span status = %v", ended[0].Status)
+	}
+	if ended[1].Status.Code != codes.Error || ended[1].Parent.IsValid() {
+		t.Errorf("server span status %v, parent %v; want an error on a new trace", ended[1].Status, ended[1].Parent)
+	}
+	if entries := logs.FilterMessage("Request").All(); len(entries) != 1 || entries[0].Level != zap.ErrorLevel {
+		t.Errorf("request logged as %v", entries)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+	"github.com/walterfan/prompt-service/internal/log"
+	"github.com/walterfan/prompt-service/pkg/audit"
+	"github.com/walterfan/prompt-service/pkg/cache"
+	"github.com/walterfan/prompt-service/pkg/config"
+	"github.com/walterfan/prompt-service/pkg/library"
+	"github.com/walterfan/prompt-service/pkg/ratelimit"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	reloadMu sync.Mutex
+	// seeds are the prompts of config.yaml as last read, by name
+	seeds map[string]library.Entry
+)
+
+// watchConfig applies the reloadable settings and the changed seed prompts again
+// whenever config.yaml changes. A change that doesn't validate is logged and the
+// previous settings stay in effect.
+func watchConfig() {
+	if _, err := seedPrompts(); err != nil {
+		logger.Warn("Invalid seed prompts in config file", zap.Error(err))
+	}
+	viper.OnConfigChange(func(e fsnotify.Event) {
+		reloadMu.Lock()
+		defer reloadMu.Unlock()
+
+		settings := config.LoadReloadable()
+		if err := applyReloadable(settings); err != nil {
+			logger.Error("Config reload rejected", zap.String("file", e.Name), zap.Error(err))
+			return
+		}
+		imported, err := reloadSeedPrompts()
+		if err != nil {
+			logger.Error("Seed prompts reload failed", zap.String("file", e.Name), zap.Error(err))
+		}
+		logger.Info("Config reloaded", zap.String("file", e.Name),
+			zap.String("log_level", settings.LogLevel), zap.Strings("prompts", imported))
+	})
+	viper.WatchConfig()
+}
+
+// applyReloadable validates settings before applying any of them.
+func applyReloadable(settings config.Reloadable) error {
+	limits, err := ratelimit.ParseLimits(settings.RateLimitIP, settings.RateLimitUser, settings.RateLimitRoutes)
+	if err != nil {
+		return err
+	}
+	if err := log.SetLevel(settings.LogLevel); err != nil {
+		return err
+	}
+	ratelimit.SetLimits(limits)
+	return nil
+}
+
+// seedPrompts reads the prompts key of config.yaml, remembering them as the last seen.
+func seedPrompts() (map[string]library.Entry, error) {
+	data, err := yaml.Marshal(viper.Get("prompts"))
+	if err != nil {
+		return nil, err
+	}
+	entries, err := library.Decode(bytes.NewReader(data), library.FormatYAML)
+	if err != nil {
+		return nil, err
+	}
+	previous := seeds
+	seeds = make(map[string]library.Entry, len(entries))
+	for _, e := range entries {
+		seeds[e.Name] = e
+	}
+	return previous, nil
+}
+
+// reloadSeedPrompts imports the seed prompts that were added or changed in the file,
+// like the import command, so prompts edited through the API are only overwritten
+// when their seed changes. Prompts removed from the file are kept.
+func reloadSeedPrompts() ([]string, error) {
+	previous, err := seedPrompts()
+	if err != nil {
+		return nil, err
+	}
+	var changed []library.Entry
+	for name, e := range seeds {
+		if old, ok := previous[name]; !ok || !reflect.DeepEqual(old, e) {
+			changed = append(changed, e)
+		}
+	}
+	if len(changed) == 0 {
+		return nil, nil
+	}
+
+	ctx := audit.WithActor(context.Background(), "config")
+	report, err := library.Import(ctx, changed, nil, "config", false)
+	if err != nil {
+		return nil, err
+	}
+	cache.Invalidate(ctx)
+	return append(report.Created, report.Updated...), nil
+}
@@ -1,209 +1,1467 @@
 package main
 
 import (
+	"bufio"
 	"context"
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha1"
 	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
 	"database/sql"
+	"encoding/base32"
 	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"math"
+	"math/big"
 	"net/http"
 	"os"
 	"os/exec"
 	"os/signal"
+	"os/user"
+	"runtime"
+	"sort"
+	"strings"
 	"syscall"
 	"time"
 
+	"github.com/ccojocar/zxcvbn-go"
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
 	_ "github.com/mattn/go-sqlite3"
+	"github.com/sethvargo/go-diceware/diceware"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/term"
 	"gopkg.in/natefinch/lumberjack.v2"
 )
 
-// Site represents a site object
+// maskedPassword is shown instead of passwords in listings; see sites reveal
+const maskedPassword = "********"
+
+// Site represents a site object. Password, the values of CustomFields and TOTPSeed are
+// stored encrypted.
 type Site struct {
-	ID       string `json:"id"`
-	Name     string `json:"name"`
-	Username string `json:"username"`
-	Password string `json:"password"`
-	Created  string `json:"created"`
-	Modified string `json:"modified"`
+	ID           string            `json:"id"`
+	Name         string            `json:"name"`
+	Username     string            `json:"username"`
+	Password     string            `json:"password,omitempty"`
+	URLs         []string          `json:"urls,omitempty"`
+	Notes        string            `json:"notes,omitempty"`
+	Tags         []string          `json:"tags,omitempty"`
+	CustomFields map[string]string `json:"custom_fields,omitempty"`
+	TOTPSeed     string            `json:"totp_seed,omitempty"`
+	Created      string            `json:"created"`
+	Modified     string            `json:"modified"`
+
+	// PasswordChanged is when the password was last set, for the password audit
+	PasswordChanged string `json:"password_changed,omitempty"`
+}
+
+// SiteFilter selects sites by tag and URL; the zero value selects all sites
+type SiteFilter struct {
+	Tags []string // sites must have all of them, compared case-insensitively
+	URL  string   // case-insensitive substring of one of the site's URLs
+}
+
+// SiteSecrets holds the decrypted secrets of a site
+type SiteSecrets struct {
+	Password     string            `json:"password"`
+	CustomFields map[string]string `json:"custom_fields,omitempty"`
+}
+
+// maskSecrets replaces the encrypted values of a site for output, see sites reveal
+func maskSecrets(site *Site) {
+	site.Password = maskedPassword
+	for name := range site.CustomFields {
+		site.CustomFields[name] = maskedPassword
+	}
+	if site.TOTPSeed != "" {
+		site.TOTPSeed = maskedPassword
+	}
+}
+
+// normalizeSite trims the URLs and tags of a site, dropping empty ones and tags
+// repeated in another case
+func normalizeSite(site *Site) {
+	var urls []string
+	for _, u := range site.URLs {
+		if u = strings.TrimSpace(u); u != "" {
+			urls = append(urls, u)
+		}
+	}
+	site.URLs = urls
+
+	var tags []string
+	seen := make(map[string]bool)
+	for _, tag := range site.Tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || seen[strings.ToLower(tag)] {
+			continue
+		}
+		seen[strings.ToLower(tag)] = true
+		tags = append(tags, tag)
+	}
+	site.Tags = tags
+}
+
+// TOTP parameters (RFC 6238). These are the defaults of authenticator apps and the only
+// ones they all support.
+const (
+	totpPeriod = 30 // seconds
+	totpDigits = 6
+)
+
+// normalizeTOTPSeed validates a base32 TOTP seed, dropping spaces, dashes and padding
+// and upper-casing it
+func normalizeTOTPSeed(seed string) (string, error) {
+	seed = strings.Map(func(r rune) rune {
+		if r == ' ' || r == '-' || r == '=' {
+			return -1
+		}
+		return r
+	}, strings.ToUpper(seed))
+
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(seed)
+	if err != nil || len(key) == 0 {
+		return "", fmt.Errorf("invalid TOTP seed: must be base32")
+	}
+	return seed, nil
+}
+
+// totpCode computes the TOTP code of a seed at t with HMAC-SHA1
+func totpCode(seed string, t time.Time) (string, error) {
+	seed, err := normalizeTOTPSeed(seed)
+	if err != nil {
+		return "", err
+	}
+	key, _ := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(seed)
+
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(t.Unix()/totpPeriod))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+
+	// Dynamic truncation, RFC 4226 section 5.3
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	modulus := uint32(1)
+	for i := 0; i < totpDigits; i++ {
+		modulus *= 10
+	}
+	return fmt.Sprintf("%0*d", totpDigits, value%modulus), nil
+}
+
+// passwordCharsets are the character sets of generated passwords, by name
+var passwordCharsets = map[string]string{
+	"lower":   "abcdefghijklmnopqrstuvwxyz",
+	"upper":   "ABCDEFGHIJKLMNOPQRSTUVWXYZ",
+	"digits":  "0123456789",
+	"symbols": "!#$%&*+-.:=?@^_~",
+}
+
+// PasswordOptions configures GeneratePassword
+type PasswordOptions struct {
+	Length    int      // characters of a password
+	Charsets  []string // names of passwordCharsets; a password has at least one of each
+	Words     int      // words of a passphrase; 0 generates a password
+	Separator string   // between the words of a passphrase
+}
+
+// GeneratePassword generates a random password, or a passphrase of words from the EFF
+// large word list (12.9 bits per word), with crypto/rand
+func GeneratePassword(opts PasswordOptions) (string, error) {
+	if opts.Words > 0 {
+		if opts.Words < 4 {
+			return "", fmt.Errorf("a passphrase needs at least 4 words")
+		}
+		words, err := diceware.Generate(opts.Words)
+		if err != nil {
+			return "", fmt.Errorf("failed to generate passphrase: %w", err)
+		}
+		return strings.Join(words, opts.Separator), nil
+	}
+
+	if opts.Length < 8 {
+		return "", fmt.Errorf("a password needs at least 8 characters")
+	}
+	if len(opts.Charsets) == 0 {
+		return "", fmt.Errorf("at least one charset is required")
+	}
+	var sets []string
+	for _, name := range opts.Charsets {
+		set, ok := passwordCharsets[name]
+		if !ok {
+			return "", fmt.Errorf("unknown charset %q, expected lower, upper, digits or symbols", name)
+		}
+		sets = append(sets, set)
+	}
+	if opts.Length < len(sets) {
+		return "", fmt.Errorf("a password of %d characters can't use %d charsets", opts.Length, len(sets))
+	}
+	alphabet := strings.Join(sets, "")
+
+	// Draw whole passwords until one has every charset, which keeps the characters
+	// uniformly distributed
+	password := make([]byte, opts.Length)
+	for {
+		for i := range password {
+			n, err := rand.Int(rand.Reader, big.NewInt(int64(len(alphabet))))
+			if err != nil {
+				return "", fmt.Errorf("failed to generate password: %w", err)
+			}
+			password[i] = alphabet[n.Int64()]
+		}
+
+		complete := true
+		for _, set := range sets {
+			if !strings.ContainsAny(string(password), set) {
+				complete = false
+				break
+			}
+		}
+		if complete {
+			return string(password), nil
+		}
+	}
+}
+
+// passwordEntropy estimates the entropy of a password in bits with zxcvbn, which
+// discounts dictionary words, keyboard patterns, dates and the given user inputs (such
+// as the site name and username)
+func passwordEntropy(password string, userInputs []string) float64 {
+	// zxcvbn is slow on long inputs, which are strong anyway
+	if runes := []rune(password); len(runes) > 100 {
+		password = string(runes[:100])
+	}
+	return zxcvbn.PasswordStrength(password, userInputs).Entropy
+}
+
+// Ciphertext formats. Legacy ciphertexts are base64(nonce + AES-GCM ciphertext) under
+// the SHA256 of a key secret. Version 2 and 3 ciphertexts are
+// "<version>:<key id>:<base64 wrapped data key>:<base64 nonce + AES-GCM ciphertext>", where
+// the secret is encrypted with a random per-record data key and the data key is
+// encrypted (wrapped) with the master key <key id>. Version 3 binds the secret to its
+// site and field as additional data, so a ciphertext copied into another row or column
+// fails to decrypt.
+const (
+	ciphertextV2 = "v2"
+	ciphertextV3 = "v3"
+)
+
+// Fields of a site whose secrets are encrypted; custom fields are named by customField
+const (
+	fieldPassword = "password"
+	fieldTOTPSeed = "totp_seed"
+)
+
+// customField is the field of a site's custom field name
+func customField(name string) string {
+	return "field:" + name
 }
 
-// EncryptionService handles password encryption/decryption
+// secretAAD is the additional data binding a version 3 ciphertext to a site's field.
+// The site ID is length-prefixed, since both parts may contain any character.
+func secretAAD(siteID, field string) []byte {
+	return []byte(fmt.Sprintf("%d:%s:%s", len(siteID), siteID, field))
+}
+
+// Argon2id parameters for deriving master keys from key secrets. They are stored with
+// each key's salt, so changing them only affects keys added later.
+const (
+	argon2Time    = 3
+	argon2Memory  = 64 * 1024 // KiB
+	argon2Threads = 4
+)
+
+// keyCheckPlaintext is encrypted with every master key when it is first used, to detect
+// a wrong secret before anything is encrypted with it
+const keyCheckPlaintext = "vault-key-check"
+
+// masterKey is a key of the keyring
+type masterKey struct {
+	id        string
+	key       []byte // derived with Argon2id
+	legacyKey []byte // SHA256 of the secret, for legacy ciphertexts
+}
+
+// EncryptionService handles password encryption/decryption with a keyring of master keys.
+// New passwords are encrypted with the current key; any key of the keyring decrypts.
 type EncryptionService struct {
-	key []byte
+	currentKeyID string
+	keys         map[string]*masterKey
+	keyIDs       []string // in config order, to try for legacy ciphertexts
 }
 
-// NewEncryptionService creates a new encryption service
-func NewEncryptionService() (*EncryptionService, error) {
+// keyConfig is a master key entry under encryption.keys in config.yaml
+type keyConfig struct {
+	ID     string `mapstructure:"id"`
+	Secret string `mapstructure:"secret"`
+}
+
+// NewEncryptionService creates a new encryption service from the encryption section of
+// the config, deriving the master keys with the salts stored in the database. Without
+// encryption.keys, the keyring holds the single key "default" from AES_KEY.
+func NewEncryptionService(config *viper.Viper, db *sql.DB) (*EncryptionService, error) {
 	// Load .env file if it exists
 	if err := godotenv.Load(); err != nil {
 		// .env file not found, continue with environment variables
 	}
 
-	// Load AES key from environment variable (from .env file or system env)
-	aesKeyString := os.Getenv("AES_KEY")
-	if aesKeyString == "" {
-		return nil, fmt.Errorf("AES_KEY environment variable is required (set in .env file or system environment)")
+	var keys []keyConfig
+	if err := config.UnmarshalKey("encryption.keys", &keys); err != nil {
+		return nil, fmt.Errorf("invalid encryption.keys: %w", err)
+	}
+	if len(keys) == 0 {
+		// Load AES key from environment variable (from .env file or system env)
+		aesKeyString := os.Getenv("AES_KEY")
+		if aesKeyString == "" {
+			return nil, fmt.Errorf("AES_KEY environment variable is required (set in .env file or system environment)")
+		}
+		keys = []keyConfig{{ID: "default", Secret: aesKeyString}}
+	}
+
+	es := &EncryptionService{
+		currentKeyID: config.GetString("encryption.current_key"),
+		keys:         map[string]*masterKey{},
+	}
+	for _, k := range keys {
+		if k.ID == "" || strings.Contains(k.ID, ":") {
+			return nil, fmt.Errorf("invalid encryption key id %q", k.ID)
+		}
+		if _, ok := es.keys[k.ID]; ok {
+			return nil, fmt.Errorf("duplicate encryption key id %q", k.ID)
+		}
+		secret := expandSecret(k.Secret)
+		if secret == "" {
+			return nil, fmt.Errorf("secret of encryption key %q is empty (%s)", k.ID, k.Secret)
+		}
+
+		key, err := deriveMasterKey(db, k.ID, secret)
+		if err != nil {
+			return nil, err
+		}
+		legacyKey := sha256.Sum256([]byte(secret))
+		es.keys[k.ID] = &masterKey{id: k.ID, key: key, legacyKey: legacyKey[:]}
+		es.keyIDs = append(es.keyIDs, k.ID)
 	}
 
-	// Hash the key string with SHA256 to get 32 bytes for AES-256
-	hash := sha256.Sum256([]byte(aesKeyString))
+	if es.currentKeyID == "" {
+		es.currentKeyID = es.keyIDs[0]
+	}
+	if _, ok := es.keys[es.currentKeyID]; !ok {
+		return nil, fmt.Errorf("encryption.current_key %q is not in encryption.keys", es.currentKeyID)
+	}
 
-	return &EncryptionService{
-		key: hash[:],
-	}, nil
+	return es, nil
+}
+
+// deriveMasterKey derives the master key id from secret with Argon2id. The salt is
+// generated and stored the first time the key is used, along with a key check value
+// that later derivations are verified against.
+func deriveMasterKey(db *sql.DB, id, secret string) ([]byte, error) {
+	query := `SELECT kdf, salt, check_value FROM encryption_keys WHERE id = ?`
+	var kdf, encodedSalt, encodedCheck string
+	err := db.QueryRow(query, id).Scan(&kdf, &encodedSalt, &encodedCheck)
+	if err == sql.ErrNoRows {
+		if err := storeMasterKeySalt(db, id, secret); err != nil {
+			return nil, err
+		}
+		err = db.QueryRow(query, id).Scan(&kdf, &encodedSalt, &encodedCheck)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load salt of encryption key %q: %w", id, err)
+	}
+
+	var t, m uint32
+	var p uint8
+	if _, err := fmt.Sscanf(kdf, "argon2id:t=%d,m=%d,p=%d", &t, &m, &p); err != nil {
+		return nil, fmt.Errorf("unsupported kdf %q for encryption key %q", kdf, id)
+	}
+	salt, err := base64.StdEncoding.DecodeString(encodedSalt)
+	if err != nil {
+		return nil, fmt.Errorf("invalid salt for encryption key %q: %w", id, err)
+	}
+	check, err := base64.StdEncoding.DecodeString(encodedCheck)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key check for encryption key %q: %w", id, err)
+	}
+
+	key := argon2.IDKey([]byte(secret), salt, t, m, p, 32)
+	if plaintext, err := openAESGCM(key, check, []byte(id)); err != nil || string(plaintext) != keyCheckPlaintext {
+		return nil, fmt.Errorf("wrong secret for encryption key %q", id)
+	}
+	return key, nil
+}
+
+// storeMasterKeySalt stores a new salt and key check value for the key id. An existing
+// row is kept, since another process may have stored it meanwhile.
+func storeMasterKeySalt(db *sql.DB, id, secret string) error {
+	salt := make([]byte, 16)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return fmt.Errorf("failed to generate salt: %w", err)
+	}
+	key := argon2.IDKey([]byte(secret), salt, argon2Time, argon2Memory, argon2Threads, 32)
+	check, err := sealAESGCM(key, []byte(keyCheckPlaintext), []byte(id))
+	if err != nil {
+		return err
+	}
+
+	query := `
+	INSERT OR IGNORE INTO encryption_keys (id, kdf, salt, check_value, created)
+	VALUES (?, ?, ?, ?, ?)`
+
+	kdf := fmt.Sprintf("argon2id:t=%d,m=%d,p=%d", argon2Time, argon2Memory, argon2Threads)
+	_, err = db.Exec(query, id, kdf, base64.StdEncoding.EncodeToString(salt),
+		base64.StdEncoding.EncodeToString(check), time.Now().Format(time.RFC3339))
+	if err != nil {
+		return fmt.Errorf("failed to store salt of encryption key %q: %w", id, err)
+	}
+	return nil
 }
 
-// EncryptPassword encrypts a password using AES-GCM
-func (es *EncryptionService) EncryptPassword(password string) (string, error) {
+// sealAESGCM encrypts plaintext with AES-GCM, returning nonce + ciphertext
+func sealAESGCM(key, plaintext, additionalData []byte) ([]byte, error) {
 	// Create a new AES cipher block
-	block, err := aes.NewCipher(es.key)
+	block, err := aes.NewCipher(key)
 	if err != nil {
-		return "", fmt.Errorf("failed to create cipher: %w", err)
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
 	}
 
 	// Create a new GCM mode
 	gcm, err := cipher.NewGCM(block)
 	if err != nil {
-		return "", fmt.Errorf("failed to create GCM: %w", err)
+		return nil, fmt.Errorf("failed to create GCM: %w", err)
 	}
 
 	// Generate a random nonce
 	nonce := make([]byte, gcm.NonceSize())
 	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
-		return "", fmt.Errorf("failed to generate nonce: %w", err)
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	return gcm.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+// openAESGCM decrypts nonce + ciphertext sealed by sealAESGCM
+func openAESGCM(key, ciphertext, additionalData []byte) ([]byte, error) {
+	// Create a new AES cipher block
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+
+	// Create a new GCM mode
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCM: %w", err)
+	}
+
+	// Extract nonce and ciphertext
+	nonceSize := gcm.NonceSize()
+	if len(ciphertext) < nonceSize {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+
+	nonce, ciphertext := ciphertext[:nonceSize], ciphertext[nonceSize:]
+
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, additionalData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt: %w", err)
+	}
+	return plaintext, nil
+}
+
+// EncryptPassword encrypts the secret field of the site siteID, e.g. its password,
+// with a new data key wrapped by the current key
+func (es *EncryptionService) EncryptPassword(siteID, field, password string) (string, error) {
+	// Generate a random data key for this record
+	dataKey := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return "", fmt.Errorf("failed to generate data key: %w", err)
 	}
 
 	// Encrypt the password
-	ciphertext := gcm.Seal(nonce, nonce, []byte(password), nil)
+	ciphertext, err := sealAESGCM(dataKey, []byte(password), secretAAD(siteID, field))
+	if err != nil {
+		return "", err
+	}
+
+	return es.wrap(ciphertextV3, dataKey, base64.StdEncoding.EncodeToString(ciphertext))
+}
+
+// wrap encrypts the data key with the current key and formats a ciphertext of version
+func (es *EncryptionService) wrap(version string, dataKey []byte, data string) (string, error) {
+	current := es.keys[es.currentKeyID]
+	wrapped, err := sealAESGCM(current.key, dataKey, []byte(current.id))
+	if err != nil {
+		return "", err
+	}
+
+	return strings.Join([]string{version, current.id, base64.StdEncoding.EncodeToString(wrapped), data}, ":"), nil
+}
+
+// ciphertextVersion returns the version of a ciphertext, empty for legacy ones
+func ciphertextVersion(encryptedPassword string) string {
+	for _, version := range []string{ciphertextV2, ciphertextV3} {
+		if strings.HasPrefix(encryptedPassword, version+":") {
+			return version
+		}
+	}
+	return ""
+}
+
+// unwrap decrypts the data key of a version 2 or 3 ciphertext, returning it with the
+// encrypted data
+func (es *EncryptionService) unwrap(encryptedPassword string) ([]byte, string, error) {
+	parts := strings.Split(encryptedPassword, ":")
+	if len(parts) != 4 {
+		return nil, "", fmt.Errorf("malformed ciphertext")
+	}
+	key, ok := es.keys[parts[1]]
+	if !ok {
+		return nil, "", fmt.Errorf("encryption key %q is not in the keyring", parts[1])
+	}
 
-	// Encode to base64 for storage
-	return base64.StdEncoding.EncodeToString(ciphertext), nil
+	wrapped, err := base64.StdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to decode base64: %w", err)
+	}
+	dataKey, err := openAESGCM(key.key, wrapped, []byte(key.id))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to unwrap data key: %w", err)
+	}
+	return dataKey, parts[3], nil
+}
+
+// DecryptPassword decrypts the secret field of the site siteID encrypted with any key
+// of the keyring. Version 2 and legacy ciphertexts aren't bound to a site and field.
+func (es *EncryptionService) DecryptPassword(siteID, field, encryptedPassword string) (string, error) {
+	version := ciphertextVersion(encryptedPassword)
+	if version == "" {
+		return es.decryptLegacy(encryptedPassword)
+	}
+
+	dataKey, data, err := es.unwrap(encryptedPassword)
+	if err != nil {
+		return "", err
+	}
+
+	// Decode from base64
+	ciphertext, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode base64: %w", err)
+	}
+
+	// Decrypt the password
+	var additionalData []byte
+	if version == ciphertextV3 {
+		additionalData = secretAAD(siteID, field)
+	}
+	plaintext, err := openAESGCM(dataKey, ciphertext, additionalData)
+	if err != nil {
+		return "", err
+	}
+
+	return string(plaintext), nil
 }
 
-// DecryptPassword decrypts a password using AES-GCM
-func (es *EncryptionService) DecryptPassword(encryptedPassword string) (string, error) {
+// decryptLegacy decrypts a ciphertext from before key IDs, trying every key's legacy key
+func (es *EncryptionService) decryptLegacy(encryptedPassword string) (string, error) {
 	// Decode from base64
 	ciphertext, err := base64.StdEncoding.DecodeString(encryptedPassword)
 	if err != nil {
 		return "", fmt.Errorf("failed to decode base64: %w", err)
 	}
 
-	// Create a new AES cipher block
-	block, err := aes.NewCipher(es.key)
+	for _, id := range es.keyIDs {
+		if plaintext, err := openAESGCM(es.keys[id].legacyKey, ciphertext, nil); err == nil {
+			return string(plaintext), nil
+		}
+	}
+	return "", fmt.Errorf("failed to decrypt: no key of the keyring matches")
+}
+
+// Rekey returns the secret field of the site siteID re-encrypted for the current key,
+// and whether it changed. Version 3 ciphertexts only get their data key re-wrapped;
+// version 2 and legacy ones are decrypted and encrypted again as version 3.
+func (es *EncryptionService) Rekey(siteID, field, encryptedPassword string) (string, bool, error) {
+	if ciphertextVersion(encryptedPassword) != ciphertextV3 {
+		password, err := es.DecryptPassword(siteID, field, encryptedPassword)
+		if err != nil {
+			return "", false, err
+		}
+		rekeyed, err := es.EncryptPassword(siteID, field, password)
+		return rekeyed, err == nil, err
+	}
+
+	if strings.HasPrefix(encryptedPassword, ciphertextV3+":"+es.currentKeyID+":") {
+		return encryptedPassword, false, nil
+	}
+	dataKey, data, err := es.unwrap(encryptedPassword)
+	if err != nil {
+		return "", false, err
+	}
+	rekeyed, err := es.wrap(ciphertextV3, dataKey, data)
+	return rekeyed, err == nil, err
+}
+
+// API scopes granted to credentials
+const (
+	ScopeRead  = "read"  // list and get sites, with masked passwords
+	ScopeWrite = "write" // everything else, including revealing passwords and commands
+)
+
+// principalKey is the gin context key of the authenticated Principal
+const principalKey = "principal"
+
+// Principal represents an authenticated API client
+type Principal struct {
+	Name   string
+	Method string // token, mtls, basic or none
+	Scopes []string
+}
+
+// HasScope reports whether the principal was granted scope. Write includes read.
+func (p *Principal) HasScope(scope string) bool {
+	for _, s := range p.Scopes {
+		if s == scope || (scope == ScopeRead && s == ScopeWrite) {
+			return true
+		}
+	}
+	return false
+}
+
+// credentialConfig is a credential entry under the auth section of config.yaml
+type credentialConfig struct {
+	Name         string   `mapstructure:"name"`
+	Token        string   `mapstructure:"token"`
+	Username     string   `mapstructure:"username"`
+	PasswordHash string   `mapstructure:"password_hash"`
+	CommonName   string   `mapstructure:"common_name"`
+	Scopes       []string `mapstructure:"scopes"`
+}
+
+// dummyPasswordHash is compared against when a basic auth username is unknown, so the
+// response takes as long as for a wrong password and doesn't reveal which users exist.
+// Its cost is that of hash-password.
+const dummyPasswordHash = "$2a$10$AXuw1naiZAqtbAwba/saQu2dmR22hOn9mxje4Ci3kz0a1FB0CHnuO"
+
+// basicUser is a user allowed to sign in with HTTP basic auth
+type basicUser struct {
+	hash      []byte
+	principal *Principal
+}
+
+// Authenticator checks the credentials of API requests
+type Authenticator struct {
+	enabled     bool
+	tokens      map[[32]byte]*Principal // by SHA256 of the token
+	basicUsers  map[string]*basicUser   // by username
+	clientCerts map[string]*Principal   // by client certificate common name
+}
+
+// NewAuthenticator creates an authenticator from the auth section of the config
+func NewAuthenticator(config *viper.Viper) (*Authenticator, error) {
+	auth := &Authenticator{
+		enabled:     config.GetBool("auth.enabled"),
+		tokens:      map[[32]byte]*Principal{},
+		basicUsers:  map[string]*basicUser{},
+		clientCerts: map[string]*Principal{},
+	}
+	if !auth.enabled {
+		return auth, nil
+	}
+
+	// Static bearer tokens and API keys
+	var tokens []credentialConfig
+	if err := config.UnmarshalKey("auth.tokens", &tokens); err != nil {
+		return nil, fmt.Errorf("invalid auth.tokens: %w", err)
+	}
+	for _, t := range tokens {
+		principal, err := newPrincipal(t.Name, "token", t.Scopes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid auth.tokens entry: %w", err)
+		}
+		token := expandSecret(t.Token)
+		if token == "" {
+			// The token comes from an unset environment variable
+			continue
+		}
+		auth.tokens[sha256.Sum256([]byte(token))] = principal
+	}
+
+	// HTTP basic fallback
+	if config.GetBool("auth.basic.enabled") {
+		var users []credentialConfig
+		if err := config.UnmarshalKey("auth.basic.users", &users); err != nil {
+			return nil, fmt.Errorf("invalid auth.basic.users: %w", err)
+		}
+		for _, u := range users {
+			principal, err := newPrincipal(u.Username, "basic", u.Scopes)
+			if err != nil {
+				return nil, fmt.Errorf("invalid auth.basic.users entry: %w", err)
+			}
+			hash := expandSecret(u.PasswordHash)
+			if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+				return nil, fmt.Errorf("invalid password_hash for basic user %q: %w", u.Username, err)
+			}
+			auth.basicUsers[u.Username] = &basicUser{hash: []byte(hash), principal: principal}
+		}
+	}
+
+	// mTLS client certificates, verified by the TLS listener
+	if config.GetBool("auth.mtls.enabled") {
+		var clients []credentialConfig
+		if err := config.UnmarshalKey("auth.mtls.clients", &clients); err != nil {
+			return nil, fmt.Errorf("invalid auth.mtls.clients: %w", err)
+		}
+		for _, c := range clients {
+			principal, err := newPrincipal(c.CommonName, "mtls", c.Scopes)
+			if err != nil {
+				return nil, fmt.Errorf("invalid auth.mtls.clients entry: %w", err)
+			}
+			auth.clientCerts[c.CommonName] = principal
+		}
+	}
+
+	if len(auth.tokens)+len(auth.basicUsers)+len(auth.clientCerts) == 0 {
+		return nil, fmt.Errorf("auth is enabled but no credentials are configured (set auth.enabled to false to run without authentication)")
+	}
+	return auth, nil
+}
+
+// newPrincipal validates the name and scopes of a configured credential
+func newPrincipal(name, method string, scopes []string) (*Principal, error) {
+	if name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+	if len(scopes) == 0 {
+		return nil, fmt.Errorf("%s: at least one scope is required", name)
+	}
+	for _, scope := range scopes {
+		if scope != ScopeRead && scope != ScopeWrite {
+			return nil, fmt.Errorf("%s: unknown scope %q", name, scope)
+		}
+	}
+	return &Principal{Name: name, Method: method, Scopes: scopes}, nil
+}
+
+// expandSecret reads a value of the form ${NAME} from the environment, so secrets
+// can be kept out of config.yaml. Other values are returned as they are.
+func expandSecret(value string) string {
+	if strings.HasPrefix(value, "${") && strings.HasSuffix(value, "}") {
+		return os.Getenv(value[2 : len(value)-1])
+	}
+	return value
+}
+
+// Authenticate returns the principal of the request's credentials. A verified client
+// certificate is checked first, then the Authorization header and then X-API-Key.
+func (a *Authenticator) Authenticate(r *http.Request) (*Principal, bool) {
+	if !a.enabled {
+		return &Principal{Name: "anonymous", Method: "none", Scopes: []string{ScopeRead, ScopeWrite}}, true
+	}
+
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+		if principal, ok := a.clientCerts[r.TLS.PeerCertificates[0].Subject.CommonName]; ok {
+			return principal, true
+		}
+	}
+
+	if header := r.Header.Get("Authorization"); header != "" {
+		scheme, credentials, _ := strings.Cut(header, " ")
+		switch {
+		case strings.EqualFold(scheme, "Bearer"):
+			return a.lookupToken(credentials)
+		case strings.EqualFold(scheme, "Basic") && len(a.basicUsers) > 0:
+			username, password, ok := r.BasicAuth()
+			if !ok {
+				return nil, false
+			}
+			user, ok := a.basicUsers[username]
+			if !ok {
+				bcrypt.CompareHashAndPassword([]byte(dummyPasswordHash), []byte(password))
+				return nil, false
+			}
+			if bcrypt.CompareHashAndPassword(user.hash, []byte(password)) != nil {
+				return nil, false
+			}
+			return user.principal, true
+		}
+		return nil, false
+	}
+
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return a.lookupToken(key)
+	}
+	return nil, false
+}
+
+// lookupToken finds the principal of a bearer token or API key. Comparing hashes
+// keeps the lookup time independent of how much of a token matches.
+func (a *Authenticator) lookupToken(token string) (*Principal, bool) {
+	principal, ok := a.tokens[sha256.Sum256([]byte(strings.TrimSpace(token)))]
+	return principal, ok
+}
+
+// challenge returns the WWW-Authenticate header for unauthenticated requests
+func (a *Authenticator) challenge() string {
+	if len(a.basicUsers) > 0 {
+		return `Bearer realm="vault", Basic realm="vault"`
+	}
+	return `Bearer realm="vault"`
+}
+
+// encryptCmd represents the encrypt command
+var encryptCmd = &cobra.Command{
+	Use:   "encrypt [password]",
+	Short: "Encrypt a password using AES-GCM",
+	Long: `Encrypt a password using AES-GCM with the current key of the keyring (encryption.keys in config.yaml, or AES_KEY).
+The ciphertext is bound to --site and --field and only decrypts with the same ones.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		password := args[0]
+
+		// Load .env file if it exists
+		if err := godotenv.Load(); err != nil {
+			// .env file not found, continue with environment variables
+		}
+
+		// Load configuration
+		config, err := loadConfig()
+		if err != nil {
+			fmt.Printf("Failed to load config: %v\n", err)
+			os.Exit(1)
+		}
+
+		// Setup logger
+		logger, err := setupLogger(config)
+		if err != nil {
+			fmt.Printf("Failed to setup logger: %v\n", err)
+			os.Exit(1)
+		}
+		defer logger.Sync()
+
+		// Create server instance for its keyring, whose salts are in the database
+		server, err := NewServer(config, logger)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		defer server.db.Close()
+		encryption := server.encryption
+
+		// Encrypt the password
+		siteID, _ := cmd.Flags().GetString("site")
+		field, _ := cmd.Flags().GetString("field")
+		encrypted, err := encryption.EncryptPassword(siteID, field, password)
+		if err != nil {
+			fmt.Printf("Error encrypting password: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("%s\n", encrypted)
+	},
+}
+
+// decryptCmd represents the decrypt command
+var decryptCmd = &cobra.Command{
+	Use:   "decrypt [encrypted_password]",
+	Short: "Decrypt a password using AES-GCM",
+	Long: `Decrypt a password using AES-GCM with any key of the keyring (encryption.keys in config.yaml, or AES_KEY).
+--site and --field must name the site and field the password was encrypted for.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		encryptedPassword := args[0]
+
+		// Load .env file if it exists
+		if err := godotenv.Load(); err != nil {
+			// .env file not found, continue with environment variables
+		}
+
+		// Load configuration
+		config, err := loadConfig()
+		if err != nil {
+			fmt.Printf("Failed to load config: %v\n", err)
+			os.Exit(1)
+		}
+
+		// Setup logger
+		logger, err := setupLogger(config)
+		if err != nil {
+			fmt.Printf("Failed to setup logger: %v\n", err)
+			os.Exit(1)
+		}
+		defer logger.Sync()
+
+		// Create server instance for its keyring, whose salts are in the database
+		server, err := NewServer(config, logger)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		defer server.db.Close()
+		encryption := server.encryption
+
+		// Decrypt the password
+		siteID, _ := cmd.Flags().GetString("site")
+		field, _ := cmd.Flags().GetString("field")
+		decrypted, err := encryption.DecryptPassword(siteID, field, encryptedPassword)
+		if err != nil {
+			fmt.Printf("Error decrypting password: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("%s\n", decrypted)
+	},
+}
+
+// rekeyCmd represents the rekey command
+var rekeyCmd = &cobra.Command{
+	Use:   "rekey",
+	Short: "Re-encrypt all stored passwords with the current key",
+	Long: `Re-encrypt the passwords of all sites with encryption.current_key. Passwords encrypted
+with another key only get their data key re-wrapped; passwords in an older format are
+decrypted and encrypted again. Afterwards, the old key can be removed from encryption.keys.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+
+		// Load .env file if it exists
+		if err := godotenv.Load(); err != nil {
+			// .env file not found, continue with environment variables
+		}
+
+		// Load configuration
+		config, err := loadConfig()
+		if err != nil {
+			fmt.Printf("Failed to load config: %v\n", err)
+			os.Exit(1)
+		}
+
+		// Setup logger
+		logger, err := setupLogger(config)
+		if err != nil {
+			fmt.Printf("Failed to setup logger: %v\n", err)
+			os.Exit(1)
+		}
+		defer logger.Sync()
+
+		// Create server instance
+		server, err := NewServer(config, logger)
+		if err != nil {
+			fmt.Printf("Failed to create server: %v\n", err)
+			os.Exit(1)
+		}
+		defer server.db.Close()
+
+		rekeyed, total, err := server.RekeySites(dryRun)
+		if err != nil {
+			fmt.Printf("Failed to rekey sites: %v\n", err)
+			os.Exit(1)
+		}
+
+		if dryRun {
+			fmt.Printf("%d of %d site(s) would be re-encrypted with key '%s'\n", rekeyed, total, server.encryption.currentKeyID)
+			return
+		}
+		fmt.Printf("Re-encrypted %d of %d site(s) with key '%s'\n", rekeyed, total, server.encryption.currentKeyID)
+	},
+}
+
+// ClipboardSink receives secrets copied with sites reveal --copy
+type ClipboardSink interface {
+	Name() string
+	Copy(secret string) error
+}
+
+// commandClipboard copies by writing to the stdin of a clipboard tool such as pbcopy
+type commandClipboard struct {
+	args []string
+}
+
+func (c *commandClipboard) Name() string { return c.args[0] }
+
+func (c *commandClipboard) Copy(secret string) error {
+	cmd := exec.Command(c.args[0], c.args[1:]...)
+	cmd.Stdin = strings.NewReader(secret)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s failed: %w: %s", c.args[0], err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// fileClipboard is a stand-in for headless environments that writes the secret to a
+// file only the current user can read
+type fileClipboard struct {
+	path string
+}
+
+func (f *fileClipboard) Name() string { return f.path }
+
+func (f *fileClipboard) Copy(secret string) error {
+	if err := os.WriteFile(f.path, []byte(secret), 0600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", f.path, err)
+	}
+	// WriteFile keeps the mode of an existing file
+	return os.Chmod(f.path, 0600)
+}
+
+// stdoutClipboard is a stand-in that prints the bare secret, e.g. to pipe it elsewhere
+type stdoutClipboard struct{}
+
+func (stdoutClipboard) Name() string { return "stdout" }
+
+func (stdoutClipboard) Copy(secret string) error {
+	_, err := fmt.Println(secret)
+	return err
+}
+
+// clipboardCommands are the clipboard tools tried by the auto sink, in order
+var clipboardCommands = [][]string{
+	{"pbcopy"},
+	{"wl-copy"},
+	{"xclip", "-selection", "clipboard"},
+	{"xsel", "--clipboard", "--input"},
+	{"clip.exe"},
+}
+
+// NewClipboardSink creates the sink configured by clipboard.sink: command, file,
+// stdout or auto. Auto uses the first clipboard tool found when a desktop session is
+// available, and otherwise the file sink if clipboard.file is set, or stdout.
+func NewClipboardSink(config *viper.Viper) (ClipboardSink, error) {
+	switch sink := config.GetString("clipboard.sink"); sink {
+	case "command":
+		args := strings.Fields(config.GetString("clipboard.command"))
+		if len(args) == 0 {
+			return nil, fmt.Errorf("clipboard.command is required for the command sink")
+		}
+		return &commandClipboard{args: args}, nil
+	case "file":
+		path := config.GetString("clipboard.file")
+		if path == "" {
+			return nil, fmt.Errorf("clipboard.file is required for the file sink")
+		}
+		return &fileClipboard{path: path}, nil
+	case "stdout":
+		return stdoutClipboard{}, nil
+	case "auto", "":
+		if args := strings.Fields(config.GetString("clipboard.command")); len(args) > 0 {
+			return &commandClipboard{args: args}, nil
+		}
+		// Clipboard tools on Linux need an X11 or Wayland session
+		if os.Getenv("DISPLAY") != "" || os.Getenv("WAYLAND_DISPLAY") != "" || runtime.GOOS != "linux" {
+			for _, args := range clipboardCommands {
+				if _, err := exec.LookPath(args[0]); err == nil {
+					return &commandClipboard{args: args}, nil
+				}
+			}
+		}
+		if path := config.GetString("clipboard.file"); path != "" {
+			return &fileClipboard{path: path}, nil
+		}
+		return stdoutClipboard{}, nil
+	default:
+		return nil, fmt.Errorf("unknown clipboard.sink %q", sink)
+	}
+}
+
+// hashPasswordCmd represents the hash-password command
+var hashPasswordCmd = &cobra.Command{
+	Use:   "hash-password",
+	Short: "Hash a password for HTTP basic auth",
+	Long: `Print the bcrypt hash of a password, to use as the password_hash of a user under auth.basic.users in config.yaml.
+The password is prompted for twice on a terminal, or read from the first line of stdin,
+so it never shows up in the shell history or the process list.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		password, err := readNewPassword(os.Stdin)
+		if err != nil {
+			fmt.Printf("Error reading password: %v\n", err)
+			os.Exit(1)
+		}
+
+		hash, err := bcrypt.GenerateFromPassword(password, bcrypt.DefaultCost)
+		if err != nil {
+			fmt.Printf("Error hashing password: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("%s\n", hash)
+	},
+}
+
+// readNewPassword prompts for a password and its confirmation without echo when in
+// is a terminal, and otherwise reads the first line of in
+func readNewPassword(in *os.File) ([]byte, error) {
+	fd := int(in.Fd())
+	if !term.IsTerminal(fd) {
+		line, err := bufio.NewReader(in).ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+		password := strings.TrimRight(line, "\r\n")
+		if password == "" {
+			return nil, errors.New("no password on stdin")
+		}
+		return []byte(password), nil
+	}
+
+	fmt.Fprint(os.Stderr, "Password: ")
+	password, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
 	if err != nil {
-		return "", fmt.Errorf("failed to create cipher: %w", err)
+		return nil, err
+	}
+	if len(password) == 0 {
+		return nil, errors.New("password is empty")
+	}
+	fmt.Fprint(os.Stderr, "Confirm password: ")
+	confirm, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return nil, err
+	}
+	if !hmac.Equal(password, confirm) {
+		return nil, errors.New("passwords don't match")
+	}
+	return password, nil
+}
+
+// sitesCmd represents the sites command
+var sitesCmd = &cobra.Command{
+	Use:   "sites",
+	Short: "Manage sites",
+	Long:  `Manage sites with CRUD operations via command line.`,
+}
+
+// addSiteFlags adds the flags for the optional details of a site to sites create and update
+func addSiteFlags(cmd *cobra.Command) {
+	cmd.Flags().StringSlice("url", nil, "URL of the site (repeatable, replaces all URLs)")
+	cmd.Flags().String("notes", "", "Free-form notes")
+	cmd.Flags().StringSlice("tag", nil, "Tag (repeatable, replaces all tags)")
+	cmd.Flags().StringArray("field", nil, "Encrypted custom field as name=value (repeatable, name= removes it)")
+	cmd.Flags().String("totp-seed", "", "Base32 TOTP seed (empty removes it)")
+}
+
+// applySiteFlags applies the flags added by addSiteFlags that were given to a site
+func applySiteFlags(cmd *cobra.Command, server *Server, site *Site) error {
+	flags := cmd.Flags()
+	if flags.Changed("url") {
+		site.URLs, _ = flags.GetStringSlice("url")
+	}
+	if flags.Changed("notes") {
+		site.Notes, _ = flags.GetString("notes")
+	}
+	if flags.Changed("tag") {
+		site.Tags, _ = flags.GetStringSlice("tag")
 	}
+	if flags.Changed("field") {
+		values, _ := flags.GetStringArray("field")
+		fields := make(map[string]string, len(values))
+		for _, field := range values {
+			name, value, ok := strings.Cut(field, "=")
+			if !ok {
+				return fmt.Errorf("invalid field %q, expected name=value", field)
+			}
+			fields[name] = value
+		}
+		if err := server.setCustomFields(site, fields); err != nil {
+			return err
+		}
+	}
+	if flags.Changed("totp-seed") {
+		seed, _ := flags.GetString("totp-seed")
+		if err := server.setTOTPSeed(site, seed); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// printSiteDetails prints the optional details of a site that are set, with the
+// values of custom fields masked
+func printSiteDetails(site *Site) {
+	if len(site.URLs) > 0 {
+		fmt.Printf("URLs: %s\n", strings.Join(site.URLs, ", "))
+	}
+	if len(site.Tags) > 0 {
+		fmt.Printf("Tags: %s\n", strings.Join(site.Tags, ", "))
+	}
+	if site.Notes != "" {
+		fmt.Printf("Notes: %s\n", site.Notes)
+	}
+	names := make([]string, 0, len(site.CustomFields))
+	for name := range site.CustomFields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Printf("Field %s: %s\n", name, maskedPassword)
+	}
+	if site.TOTPSeed != "" {
+		fmt.Printf("TOTP: configured, see sites totp\n")
+	}
+}
+
+// sitesListCmd represents the sites list command
+var sitesListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List all sites",
+	Long: `List all sites stored in the database, or with --tag and --url the sites with all
+of the tags and a URL containing the text. Passwords are masked, see sites reveal.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		tags, _ := cmd.Flags().GetStringSlice("tag")
+		url, _ := cmd.Flags().GetString("url")
+
+		// Load .env file if it exists
+		if err := godotenv.Load(); err != nil {
+			// .env file not found, continue with environment variables
+		}
 
-	// Create a new GCM mode
-	gcm, err := cipher.NewGCM(block)
-	if err != nil {
-		return "", fmt.Errorf("failed to create GCM: %w", err)
-	}
+		// Load configuration
+		config, err := loadConfig()
+		if err != nil {
+			fmt.Printf("Failed to load config: %v\n", err)
+			os.Exit(1)
+		}
 
-	// Extract nonce and ciphertext
-	nonceSize := gcm.NonceSize()
-	if len(ciphertext) < nonceSize {
-		return "", fmt.Errorf("ciphertext too short")
-	}
+		// Setup logger
+		logger, err := setupLogger(config)
+		if err != nil {
+			fmt.Printf("Failed to setup logger: %v\n", err)
+			os.Exit(1)
+		}
+		defer logger.Sync()
 
-	nonce, ciphertext := ciphertext[:nonceSize], ciphertext[nonceSize:]
+		// Create server instance
+		server, err := NewServer(config, logger)
+		if err != nil {
+			fmt.Printf("Failed to create server: %v\n", err)
+			os.Exit(1)
+		}
+		defer server.db.Close()
 
-	// Decrypt the password
-	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
-	if err != nil {
-		return "", fmt.Errorf("failed to decrypt: %w", err)
-	}
+		// Get matching sites
+		sites, err := server.FindSites(SiteFilter{Tags: tags, URL: url})
+		if err != nil {
+			fmt.Printf("Failed to get sites: %v\n", err)
+			os.Exit(1)
+		}
 
-	return string(plaintext), nil
+		if len(sites) == 0 {
+			fmt.Println("No sites found.")
+			return
+		}
+
+		fmt.Printf("Found %d site(s):\n\n", len(sites))
+		for _, site := range sites {
+			fmt.Printf("ID: %s\n", site.ID)
+			fmt.Printf("Name: %s\n", site.Name)
+			fmt.Printf("Username: %s\n", site.Username)
+			fmt.Printf("Password: %s\n", maskedPassword)
+			printSiteDetails(site)
+			fmt.Printf("Created: %s\n", site.Created)
+			fmt.Printf("Modified: %s\n", site.Modified)
+			fmt.Println("---")
+		}
+	},
 }
 
-// encryptCmd represents the encrypt command
-var encryptCmd = &cobra.Command{
-	Use:   "encrypt [password]",
-	Short: "Encrypt a password using AES-GCM",
-	Long:  `Encrypt a password using AES-GCM with the key from AES_KEY environment variable.`,
+// sitesGetCmd represents the sites get command
+var sitesGetCmd = &cobra.Command{
+	Use:   "get [id]",
+	Short: "Get a specific site by ID",
+	Long:  `Get a specific site by its ID. The password is masked, see sites reveal.`,
 	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		password := args[0]
+		siteID := args[0]
 
 		// Load .env file if it exists
 		if err := godotenv.Load(); err != nil {
 			// .env file not found, continue with environment variables
 		}
 
-		// Create encryption service
-		encryption, err := NewEncryptionService()
+		// Load configuration
+		config, err := loadConfig()
+		if err != nil {
+			fmt.Printf("Failed to load config: %v\n", err)
+			os.Exit(1)
+		}
+
+		// Setup logger
+		logger, err := setupLogger(config)
 		if err != nil {
-			fmt.Printf("Error: %v\n", err)
+			fmt.Printf("Failed to setup logger: %v\n", err)
 			os.Exit(1)
 		}
+		defer logger.Sync()
 
-		// Encrypt the password
-		encrypted, err := encryption.EncryptPassword(password)
+		// Create server instance
+		server, err := NewServer(config, logger)
 		if err != nil {
-			fmt.Printf("Error encrypting password: %v\n", err)
+			fmt.Printf("Failed to create server: %v\n", err)
+			os.Exit(1)
+		}
+		defer server.db.Close()
+
+		// Get site
+		site, err := server.GetSite(siteID)
+		if err != nil {
+			fmt.Printf("Site not found: %v\n", err)
 			os.Exit(1)
 		}
 
-		fmt.Printf("%s\n", encrypted)
+		fmt.Printf("ID: %s\n", site.ID)
+		fmt.Printf("Name: %s\n", site.Name)
+		fmt.Printf("Username: %s\n", site.Username)
+		fmt.Printf("Password: %s\n", maskedPassword)
+		printSiteDetails(site)
+		fmt.Printf("Created: %s\n", site.Created)
+		fmt.Printf("Modified: %s\n", site.Modified)
 	},
 }
 
-// decryptCmd represents the decrypt command
-var decryptCmd = &cobra.Command{
-	Use:   "decrypt [encrypted_password]",
-	Short: "Decrypt a password using AES-GCM",
-	Long:  `Decrypt a password using AES-GCM with the key from AES_KEY environment variable.`,
-	Args:  cobra.ExactArgs(1),
+// sitesRevealCmd represents the sites reveal command
+var sitesRevealCmd = &cobra.Command{
+	Use:   "reveal [id]",
+	Short: "Show the password and custom fields of a site",
+	Long: `Decrypt and show the password and custom fields of a site, recording an audit event.
+With --copy the password is copied to the clipboard sink configured under clipboard in
+config.yaml instead.`,
+	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		encryptedPassword := args[0]
+		siteID := args[0]
+		copyPassword, _ := cmd.Flags().GetBool("copy")
 
 		// Load .env file if it exists
 		if err := godotenv.Load(); err != nil {
 			// .env file not found, continue with environment variables
 		}
 
-		// Create encryption service
-		encryption, err := NewEncryptionService()
+		// Load configuration
+		config, err := loadConfig()
 		if err != nil {
-			fmt.Printf("Error: %v\n", err)
+			fmt.Printf("Failed to load config: %v\n", err)
 			os.Exit(1)
 		}
 
-		// Decrypt the password
-		decrypted, err := encryption.DecryptPassword(encryptedPassword)
+		// Setup logger
+		logger, err := setupLogger(config)
 		if err != nil {
-			fmt.Printf("Error decrypting password: %v\n", err)
+			fmt.Printf("Failed to setup logger: %v\n", err)
 			os.Exit(1)
 		}
+		defer logger.Sync()
 
-		fmt.Printf("%s\n", decrypted)
-	},
-}
+		// Create server instance
+		server, err := NewServer(config, logger)
+		if err != nil {
+			fmt.Printf("Failed to create server: %v\n", err)
+			os.Exit(1)
+		}
+		defer server.db.Close()
 
-// sitesCmd represents the sites command
-var sitesCmd = &cobra.Command{
-	Use:   "sites",
-	Short: "Manage sites",
-	Long:  `Manage sites with CRUD operations via command line.`,
+		// Resolve the clipboard first, so nothing is revealed when it is misconfigured
+		var sink ClipboardSink
+		if copyPassword {
+			sink, err = NewClipboardSink(config)
+			if err != nil {
+				fmt.Printf("Failed to setup clipboard: %v\n", err)
+				os.Exit(1)
+			}
+		}
+
+		event := cliAuditEvent()
+		if copyPassword {
+			event.Action = "copy"
+		}
+		site, err := server.GetSite(siteID)
+		if err != nil {
+			fmt.Printf("Site not found: %v\n", err)
+			os.Exit(1)
+		}
+		secrets, err := server.RevealSecrets(site, event)
+		if err != nil {
+			fmt.Printf("Failed to reveal password: %v\n", err)
+			os.Exit(1)
+		}
+
+		if sink == nil {
+			fmt.Printf("ID: %s\n", site.ID)
+			fmt.Printf("Username: %s\n", site.Username)
+			fmt.Printf("Password: %s\n", secrets.Password)
+			names := make([]string, 0, len(secrets.CustomFields))
+			for name := range secrets.CustomFields {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				fmt.Printf("Field %s: %s\n", name, secrets.CustomFields[name])
+			}
+			return
+		}
+		if err := sink.Copy(secrets.Password); err != nil {
+			fmt.Printf("Failed to copy password: %v\n", err)
+			os.Exit(1)
+		}
+		if sink.Name() != "stdout" {
+			fmt.Printf("Password of site '%s' copied to %s\n", site.ID, sink.Name())
+		}
+	},
 }
 
-// sitesListCmd represents the sites list command
-var sitesListCmd = &cobra.Command{
-	Use:   "list",
-	Short: "List all sites",
-	Long:  `List all sites stored in the database.`,
+// sitesCreateCmd represents the sites create command
+var sitesCreateCmd = &cobra.Command{
+	Use:   "create [id] [name] [username] [password]",
+	Short: "Create a new site",
+	Long: `Create a new site with the specified ID, name, username, and password, and optionally
+URLs, notes, tags, encrypted custom fields and a TOTP seed. With --generate the password
+argument is left out and a random password or, with --passphrase, a passphrase is generated.`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if generate, _ := cmd.Flags().GetBool("generate"); generate {
+			return cobra.ExactArgs(3)(cmd, args)
+		}
+		return cobra.ExactArgs(4)(cmd, args)
+	},
 	Run: func(cmd *cobra.Command, args []string) {
+		siteID := args[0]
+		siteName := args[1]
+		username := args[2]
+
+		var password string
+		generate, _ := cmd.Flags().GetBool("generate")
+		if generate {
+			var opts PasswordOptions
+			opts.Length, _ = cmd.Flags().GetInt("length")
+			opts.Charsets, _ = cmd.Flags().GetStringSlice("charset")
+			if passphrase, _ := cmd.Flags().GetBool("passphrase"); passphrase {
+				opts.Words, _ = cmd.Flags().GetInt("words")
+				opts.Separator, _ = cmd.Flags().GetString("separator")
+			}
+
+			var err error
+			password, err = GeneratePassword(opts)
+			if err != nil {
+				fmt.Printf("Failed to generate password: %v\n", err)
+				os.Exit(1)
+			}
+		} else {
+			password = args[3]
+		}
+
 		// Load .env file if it exists
 		if err := godotenv.Load(); err != nil {
 			// .env file not found, continue with environment variables
@@ -232,45 +1490,68 @@ var sitesListCmd = &cobra.Command{
 		}
 		defer server.db.Close()
 
-		// Get all sites
-		sites, err := server.GetAllSites()
+		// Check if site already exists
+		_, err = server.GetSite(siteID)
+		if err == nil {
+			fmt.Printf("Site with ID '%s' already exists\n", siteID)
+			os.Exit(1)
+		}
+
+		// Encrypt password
+		encryptedPassword, err := server.encryption.EncryptPassword(siteID, fieldPassword, password)
 		if err != nil {
-			fmt.Printf("Failed to get sites: %v\n", err)
+			fmt.Printf("Failed to encrypt password: %v\n", err)
 			os.Exit(1)
 		}
 
-		if len(sites) == 0 {
-			fmt.Println("No sites found.")
-			return
+		// Create site
+		now := time.Now().Format(time.RFC3339)
+		site := &Site{
+			ID:              siteID,
+			Name:            siteName,
+			Username:        username,
+			Password:        encryptedPassword,
+			Created:         now,
+			Modified:        now,
+			PasswordChanged: now,
+		}
+		if err := applySiteFlags(cmd, server, site); err != nil {
+			fmt.Printf("Invalid site details: %v\n", err)
+			os.Exit(1)
 		}
 
-		fmt.Printf("Found %d site(s):\n\n", len(sites))
-		for _, site := range sites {
-			// Decrypt password for display
-			decryptedPassword, err := server.encryption.DecryptPassword(site.Password)
-			if err != nil {
-				decryptedPassword = "[ENCRYPTED]"
-			}
+		err = server.SaveSite(site)
+		if err != nil {
+			fmt.Printf("Failed to save site: %v\n", err)
+			os.Exit(1)
+		}
 
-			fmt.Printf("ID: %s\n", site.ID)
-			fmt.Printf("Name: %s\n", site.Name)
-			fmt.Printf("Username: %s\n", site.Username)
-			fmt.Printf("Password: %s\n", decryptedPassword)
-			fmt.Printf("Created: %s\n", site.Created)
-			fmt.Printf("Modified: %s\n", site.Modified)
-			fmt.Println("---")
+		fmt.Printf("Site created successfully:\n")
+		fmt.Printf("ID: %s\n", site.ID)
+		fmt.Printf("Name: %s\n", site.Name)
+		fmt.Printf("Username: %s\n", site.Username)
+		if generate {
+			fmt.Printf("Password: %s (generated, see sites reveal)\n", maskedPassword)
+		} else {
+			fmt.Printf("Password: %s\n", maskedPassword)
 		}
+		printSiteDetails(site)
+		fmt.Printf("Created: %s\n", site.Created)
 	},
 }
 
-// sitesGetCmd represents the sites get command
-var sitesGetCmd = &cobra.Command{
-	Use:   "get [id]",
-	Short: "Get a specific site by ID",
-	Long:  `Get a specific site by its ID.`,
-	Args:  cobra.ExactArgs(1),
+// sitesUpdateCmd represents the sites update command
+var sitesUpdateCmd = &cobra.Command{
+	Use:   "update [id] [name] [username] [password]",
+	Short: "Update an existing site",
+	Long: `Update an existing site with the specified ID. Use empty strings for fields you don't want to change.
+URLs, notes, tags, custom fields and the TOTP seed change only when their flags are given.`,
+	Args: cobra.ExactArgs(4),
 	Run: func(cmd *cobra.Command, args []string) {
 		siteID := args[0]
+		siteName := args[1]
+		username := args[2]
+		password := args[3]
 
 		// Load .env file if it exists
 		if err := godotenv.Load(); err != nil {
@@ -300,39 +1581,62 @@ var sitesGetCmd = &cobra.Command{
 		}
 		defer server.db.Close()
 
-		// Get site
+		// Get existing site
 		site, err := server.GetSite(siteID)
 		if err != nil {
 			fmt.Printf("Site not found: %v\n", err)
 			os.Exit(1)
 		}
 
-		// Decrypt password for display
-		decryptedPassword, err := server.encryption.DecryptPassword(site.Password)
+		// Update fields if provided
+		if siteName != "" {
+			site.Name = siteName
+		}
+		if username != "" {
+			site.Username = username
+		}
+		if password != "" {
+			encryptedPassword, err := server.encryption.EncryptPassword(site.ID, fieldPassword, password)
+			if err != nil {
+				fmt.Printf("Failed to encrypt password: %v\n", err)
+				os.Exit(1)
+			}
+			site.Password = encryptedPassword
+		}
+		if err := applySiteFlags(cmd, server, site); err != nil {
+			fmt.Printf("Invalid site details: %v\n", err)
+			os.Exit(1)
+		}
+
+		site.Modified = time.Now().Format(time.RFC3339)
+		if password != "" {
+			site.PasswordChanged = site.Modified
+		}
+
+		err = server.SaveSite(site)
 		if err != nil {
-			decryptedPassword = "[ENCRYPTED]"
+			fmt.Printf("Failed to save site: %v\n", err)
+			os.Exit(1)
 		}
 
+		fmt.Printf("Site updated successfully:\n")
 		fmt.Printf("ID: %s\n", site.ID)
 		fmt.Printf("Name: %s\n", site.Name)
 		fmt.Printf("Username: %s\n", site.Username)
-		fmt.Printf("Password: %s\n", decryptedPassword)
-		fmt.Printf("Created: %s\n", site.Created)
+		fmt.Printf("Password: %s\n", maskedPassword)
+		printSiteDetails(site)
 		fmt.Printf("Modified: %s\n", site.Modified)
 	},
 }
-
-// sitesCreateCmd represents the sites create command
-var sitesCreateCmd = &cobra.Command{
-	Use:   "create [id] [name] [username] [password]",
-	Short: "Create a new site",
-	Long:  `Create a new site with the specified ID, name, username, and password.`,
-	Args:  cobra.ExactArgs(4),
+
+// sitesTOTPCmd represents the sites totp command
+var sitesTOTPCmd = &cobra.Command{
+	Use:   "totp [id]",
+	Short: "Show the current TOTP code of a site",
+	Long:  `Show the current TOTP code (RFC 6238) of a site with a TOTP seed, recording an audit event.`,
+	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		siteID := args[0]
-		siteName := args[1]
-		username := args[2]
-		password := args[3]
 
 		// Load .env file if it exists
 		if err := godotenv.Load(); err != nil {
@@ -362,57 +1666,31 @@ var sitesCreateCmd = &cobra.Command{
 		}
 		defer server.db.Close()
 
-		// Check if site already exists
-		_, err = server.GetSite(siteID)
-		if err == nil {
-			fmt.Printf("Site with ID '%s' already exists\n", siteID)
-			os.Exit(1)
-		}
-
-		// Encrypt password
-		encryptedPassword, err := server.encryption.EncryptPassword(password)
+		site, err := server.GetSite(siteID)
 		if err != nil {
-			fmt.Printf("Failed to encrypt password: %v\n", err)
+			fmt.Printf("Site not found: %v\n", err)
 			os.Exit(1)
 		}
-
-		// Create site
-		now := time.Now().Format(time.RFC3339)
-		site := &Site{
-			ID:       siteID,
-			Name:     siteName,
-			Username: username,
-			Password: encryptedPassword,
-			Created:  now,
-			Modified: now,
-		}
-
-		err = server.SaveSite(site)
+		code, validFor, err := server.TOTPCode(site, cliAuditEvent())
 		if err != nil {
-			fmt.Printf("Failed to save site: %v\n", err)
+			fmt.Printf("Failed to get TOTP code: %v\n", err)
 			os.Exit(1)
 		}
 
-		fmt.Printf("Site created successfully:\n")
-		fmt.Printf("ID: %s\n", site.ID)
-		fmt.Printf("Name: %s\n", site.Name)
-		fmt.Printf("Username: %s\n", site.Username)
-		fmt.Printf("Password: %s\n", password)
-		fmt.Printf("Created: %s\n", site.Created)
+		fmt.Printf("Code: %s\n", code)
+		fmt.Printf("Valid for: %s\n", validFor)
 	},
 }
 
-// sitesUpdateCmd represents the sites update command
-var sitesUpdateCmd = &cobra.Command{
-	Use:   "update [id] [name] [username] [password]",
-	Short: "Update an existing site",
-	Long:  `Update an existing site with the specified ID. Use empty strings for fields you don't want to change.`,
-	Args:  cobra.ExactArgs(4),
+// sitesAuditCmd represents the sites audit command
+var sitesAuditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Find weak, reused and old passwords",
+	Long: `Decrypt the passwords of all sites in memory and report those that are weak (estimated
+entropy below password_audit.min_entropy), reused by other sites, or older than
+password_audit.max_age_days. With --json the report is printed as JSON, as by GET /audit/passwords.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		siteID := args[0]
-		siteName := args[1]
-		username := args[2]
-		password := args[3]
+		asJSON, _ := cmd.Flags().GetBool("json")
 
 		// Load .env file if it exists
 		if err := godotenv.Load(); err != nil {
@@ -442,43 +1720,37 @@ var sitesUpdateCmd = &cobra.Command{
 		}
 		defer server.db.Close()
 
-		// Get existing site
-		site, err := server.GetSite(siteID)
+		report, err := server.AuditPasswords()
 		if err != nil {
-			fmt.Printf("Site not found: %v\n", err)
+			fmt.Printf("Failed to audit passwords: %v\n", err)
 			os.Exit(1)
 		}
 
-		// Update fields if provided
-		if siteName != "" {
-			site.Name = siteName
-		}
-		if username != "" {
-			site.Username = username
-		}
-		if password != "" {
-			encryptedPassword, err := server.encryption.EncryptPassword(password)
-			if err != nil {
-				fmt.Printf("Failed to encrypt password: %v\n", err)
-				os.Exit(1)
-			}
-			site.Password = encryptedPassword
+		if asJSON {
+			data, _ := json.MarshalIndent(report, "", "  ")
+			fmt.Printf("%s\n", data)
+			return
 		}
 
-		site.Modified = time.Now().Format(time.RFC3339)
-
-		err = server.SaveSite(site)
-		if err != nil {
-			fmt.Printf("Failed to save site: %v\n", err)
-			os.Exit(1)
+		fmt.Printf("Audited %d site(s): %d weak, %d reused, %d old\n", report.Sites, report.Weak, report.Reused, report.Old)
+		if len(report.Findings) == 0 {
+			fmt.Println("No weak, reused or old passwords found.")
+			return
 		}
 
-		fmt.Printf("Site updated successfully:\n")
-		fmt.Printf("ID: %s\n", site.ID)
-		fmt.Printf("Name: %s\n", site.Name)
-		fmt.Printf("Username: %s\n", site.Username)
-		fmt.Printf("Password: %s\n", password)
-		fmt.Printf("Modified: %s\n", site.Modified)
+		fmt.Println()
+		for _, finding := range report.Findings {
+			fmt.Printf("ID: %s\n", finding.SiteID)
+			fmt.Printf("Name: %s\n", finding.Name)
+			fmt.Printf("Username: %s\n", finding.Username)
+			fmt.Printf("Issues: %s\n", strings.Join(finding.Issues, ", "))
+			fmt.Printf("Entropy: %.1f bits (minimum %.0f)\n", finding.Entropy, report.MinEntropy)
+			fmt.Printf("Age: %d days\n", finding.AgeDays)
+			if len(finding.ReusedWith) > 0 {
+				fmt.Printf("Reused with: %s\n", strings.Join(finding.ReusedWith, ", "))
+			}
+			fmt.Println("---")
+		}
 	},
 }
 
@@ -605,6 +1877,7 @@ type Server struct {
 	httpServer *http.Server
 	logger     *zap.Logger
 	encryption *EncryptionService
+	auth       *Authenticator
 }
 
 // NewServer creates a new server instance
@@ -622,113 +1895,551 @@ func NewServer(config *viper.Viper, logger *zap.Logger) (*Server, error) {
 	dbPath := dataDir + "/sites.db"
 	db, err := initDatabase(dbPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to initialize database: %w", err)
+		return nil, fmt.Errorf("failed to initialize database: %w", err)
+	}
+
+	// Initialize encryption service
+	encryption, err := NewEncryptionService(config, db)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize encryption service: %w", err)
+	}
+
+	return &Server{
+		config:     config,
+		db:         db,
+		logger:     logger,
+		encryption: encryption,
+	}, nil
+}
+
+// initDatabase initializes the SQLite database
+func initDatabase(dbPath string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	// Create sites table if it doesn't exist; later columns are added by migrations
+	createTableSQL := `
+	CREATE TABLE IF NOT EXISTS sites (
+		id TEXT PRIMARY KEY,
+		name TEXT NOT NULL,
+		username TEXT NOT NULL,
+		password TEXT NOT NULL,
+		created TEXT NOT NULL,
+		modified TEXT NOT NULL
+	);`
+
+	_, err = db.Exec(createTableSQL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create table: %w", err)
+	}
+
+	// Create encryption_keys table for the KDF salts of the master keys
+	createKeysTableSQL := `
+	CREATE TABLE IF NOT EXISTS encryption_keys (
+		id TEXT PRIMARY KEY,
+		kdf TEXT NOT NULL,
+		salt TEXT NOT NULL,
+		check_value TEXT NOT NULL,
+		created TEXT NOT NULL
+	);`
+
+	_, err = db.Exec(createKeysTableSQL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create encryption_keys table: %w", err)
+	}
+
+	// Create audit_events table for reveals of passwords
+	createAuditTableSQL := `
+	CREATE TABLE IF NOT EXISTS audit_events (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		time TEXT NOT NULL,
+		action TEXT NOT NULL,
+		site_id TEXT NOT NULL,
+		actor TEXT NOT NULL,
+		method TEXT NOT NULL,
+		client TEXT NOT NULL
+	);`
+
+	_, err = db.Exec(createAuditTableSQL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create audit_events table: %w", err)
+	}
+
+	if err := migrateDatabase(db); err != nil {
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// migrations upgrade the schema of existing databases. Migration i brings a database
+// to user_version i+1; append new migrations, never change applied ones.
+var migrations = []string{
+	// 1: URLs, notes, tags, custom fields and TOTP seeds
+	`ALTER TABLE sites ADD COLUMN notes TEXT NOT NULL DEFAULT '';
+	ALTER TABLE sites ADD COLUMN totp_seed TEXT NOT NULL DEFAULT '';
+	CREATE TABLE site_urls (
+		site_id TEXT NOT NULL,
+		position INTEGER NOT NULL,
+		url TEXT NOT NULL,
+		PRIMARY KEY (site_id, position)
+	);
+	CREATE INDEX site_urls_url ON site_urls (url);
+	CREATE TABLE site_tags (
+		site_id TEXT NOT NULL,
+		tag TEXT NOT NULL COLLATE NOCASE,
+		PRIMARY KEY (site_id, tag)
+	);
+	CREATE INDEX site_tags_tag ON site_tags (tag);
+	CREATE TABLE site_fields (
+		site_id TEXT NOT NULL,
+		name TEXT NOT NULL,
+		value TEXT NOT NULL,
+		PRIMARY KEY (site_id, name)
+	);`,
+	// 2: when passwords were last set, for the password audit
+	`ALTER TABLE sites ADD COLUMN password_changed TEXT NOT NULL DEFAULT '';
+	UPDATE sites SET password_changed = modified;`,
+}
+
+// migrateDatabase applies the migrations a database is missing, each in a transaction
+// with the user_version it leads to
+func migrateDatabase(db *sql.DB) error {
+	// BEGIN IMMEDIATE is sent as a statement, so the transactions need a connection of their own
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin migration: %w", err)
+	}
+	defer conn.Close()
+
+	for {
+		done, err := migrateNext(ctx, conn)
+		if err != nil || done {
+			return err
+		}
+	}
+}
+
+// migrateNext applies the next migration the database is missing, reporting whether
+// it was up to date. The transaction takes the write lock before reading the version,
+// so processes starting together wait for each other instead of both migrating.
+func migrateNext(ctx context.Context, conn *sql.Conn) (bool, error) {
+	if _, err := conn.ExecContext(ctx, `BEGIN IMMEDIATE`); err != nil {
+		return false, fmt.Errorf("failed to begin migration: %w", err)
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			conn.ExecContext(ctx, `ROLLBACK`)
+		}
+	}()
+
+	// Read the version under the lock, another process may have migrated meanwhile
+	var version int
+	if err := conn.QueryRowContext(ctx, `PRAGMA user_version`).Scan(&version); err != nil {
+		return false, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	if version >= len(migrations) {
+		return true, nil
+	}
+
+	if _, err := conn.ExecContext(ctx, migrations[version]); err != nil {
+		return false, fmt.Errorf("failed to migrate database to version %d: %w", version+1, err)
+	}
+	if _, err := conn.ExecContext(ctx, fmt.Sprintf(`PRAGMA user_version = %d`, version+1)); err != nil {
+		return false, fmt.Errorf("failed to migrate database to version %d: %w", version+1, err)
+	}
+	if _, err := conn.ExecContext(ctx, `COMMIT`); err != nil {
+		return false, fmt.Errorf("failed to migrate database to version %d: %w", version+1, err)
+	}
+	committed = true
+	return false, nil
+}
+
+// LoadSites loads sites from the database (no-op for database)
+func (s *Server) LoadSites() error {
+	// Database is already initialized, no need to load
+	s.logger.Info("Database initialized, ready to serve sites")
+	return nil
+}
+
+// setCustomFields merges fields into the custom fields of a site, encrypting their
+// values; an empty value removes the field
+func (s *Server) setCustomFields(site *Site, fields map[string]string) error {
+	for name, value := range fields {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			return fmt.Errorf("custom field name is required")
+		}
+		if value == "" {
+			delete(site.CustomFields, name)
+			continue
+		}
+
+		encrypted, err := s.encryption.EncryptPassword(site.ID, customField(name), value)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt field '%s': %w", name, err)
+		}
+		if site.CustomFields == nil {
+			site.CustomFields = make(map[string]string)
+		}
+		site.CustomFields[name] = encrypted
+	}
+	return nil
+}
+
+// setTOTPSeed validates and encrypts the TOTP seed of a site; an empty seed removes it
+func (s *Server) setTOTPSeed(site *Site, seed string) error {
+	if seed == "" {
+		site.TOTPSeed = ""
+		return nil
+	}
+
+	seed, err := normalizeTOTPSeed(seed)
+	if err != nil {
+		return err
+	}
+	encrypted, err := s.encryption.EncryptPassword(site.ID, fieldTOTPSeed, seed)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt TOTP seed: %w", err)
+	}
+	site.TOTPSeed = encrypted
+	return nil
+}
+
+// setSecretDetails applies custom fields and, unless nil, a TOTP seed to a site
+func (s *Server) setSecretDetails(site *Site, fields map[string]string, seed *string) error {
+	if err := s.setCustomFields(site, fields); err != nil {
+		return err
+	}
+	if seed != nil {
+		return s.setTOTPSeed(site, *seed)
+	}
+	return nil
+}
+
+// SaveSite saves a single site with its URLs, tags and custom fields to the database
+func (s *Server) SaveSite(site *Site) error {
+	normalizeSite(site)
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+	INSERT OR REPLACE INTO sites (id, name, username, password, notes, totp_seed, created, modified, password_changed)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	_, err = tx.Exec(query, site.ID, site.Name, site.Username, site.Password, site.Notes, site.TOTPSeed, site.Created, site.Modified, site.PasswordChanged)
+	if err != nil {
+		return fmt.Errorf("failed to save site: %w", err)
+	}
+
+	if err := deleteSiteDetails(tx, site.ID); err != nil {
+		return err
+	}
+	for i, u := range site.URLs {
+		if _, err := tx.Exec(`INSERT INTO site_urls (site_id, position, url) VALUES (?, ?, ?)`, site.ID, i, u); err != nil {
+			return fmt.Errorf("failed to save site URLs: %w", err)
+		}
+	}
+	for _, tag := range site.Tags {
+		if _, err := tx.Exec(`INSERT INTO site_tags (site_id, tag) VALUES (?, ?)`, site.ID, tag); err != nil {
+			return fmt.Errorf("failed to save site tags: %w", err)
+		}
+	}
+	for name, value := range site.CustomFields {
+		if _, err := tx.Exec(`INSERT INTO site_fields (site_id, name, value) VALUES (?, ?, ?)`, site.ID, name, value); err != nil {
+			return fmt.Errorf("failed to save site fields: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	s.logger.Info("Saved site to database", zap.String("site_id", site.ID))
+	return nil
+}
+
+// deleteSiteDetails deletes the URLs, tags and custom fields of a site
+func deleteSiteDetails(tx *sql.Tx, id string) error {
+	for _, table := range []string{"site_urls", "site_tags", "site_fields"} {
+		if _, err := tx.Exec(`DELETE FROM `+table+` WHERE site_id = ?`, id); err != nil {
+			return fmt.Errorf("failed to delete from %s: %w", table, err)
+		}
+	}
+	return nil
+}
+
+// DeleteSite deletes a site from the database
+func (s *Server) DeleteSite(id string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `DELETE FROM sites WHERE id = ?`
+	result, err := tx.Exec(query, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete site: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("site not found")
+	}
+
+	if err := deleteSiteDetails(tx, id); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	s.logger.Info("Deleted site from database", zap.String("site_id", id))
+	return nil
+}
+
+// siteColumns are the columns of the sites table read by scanSite
+const siteColumns = `id, name, username, password, notes, totp_seed, created, modified, password_changed`
+
+// scanSite scans a row of siteColumns
+func scanSite(row interface{ Scan(...any) error }) (*Site, error) {
+	site := &Site{}
+	err := row.Scan(&site.ID, &site.Name, &site.Username, &site.Password, &site.Notes, &site.TOTPSeed, &site.Created, &site.Modified, &site.PasswordChanged)
+	return site, err
+}
+
+// GetSite retrieves a site from the database
+func (s *Server) GetSite(id string) (*Site, error) {
+	query := `SELECT ` + siteColumns + ` FROM sites WHERE id = ?`
+	site, err := scanSite(s.db.QueryRow(query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("site not found")
+		}
+		return nil, fmt.Errorf("failed to get site: %w", err)
+	}
+
+	if err := s.loadSiteDetails([]*Site{site}); err != nil {
+		return nil, err
+	}
+	return site, nil
+}
+
+// loadSiteDetails loads the URLs, tags and custom fields of sites. A single site is
+// looked up by ID, for more the tables are read whole.
+func (s *Server) loadSiteDetails(sites []*Site) error {
+	if len(sites) == 0 {
+		return nil
+	}
+	byID := make(map[string]*Site, len(sites))
+	for _, site := range sites {
+		byID[site.ID] = site
+	}
+	where, args := "", []any{}
+	if len(sites) == 1 {
+		where, args = ` WHERE site_id = ?`, []any{sites[0].ID}
+	}
+
+	err := s.eachRow(`SELECT site_id, url FROM site_urls`+where+` ORDER BY site_id, position`, args, func(cols []string) {
+		if site := byID[cols[0]]; site != nil {
+			site.URLs = append(site.URLs, cols[1])
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("failed to load site URLs: %w", err)
 	}
 
-	// Initialize encryption service
-	encryption, err := NewEncryptionService()
+	err = s.eachRow(`SELECT site_id, tag FROM site_tags`+where+` ORDER BY site_id, tag`, args, func(cols []string) {
+		if site := byID[cols[0]]; site != nil {
+			site.Tags = append(site.Tags, cols[1])
+		}
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to initialize encryption service: %w", err)
+		return fmt.Errorf("failed to load site tags: %w", err)
 	}
 
-	return &Server{
-		config:     config,
-		db:         db,
-		logger:     logger,
-		encryption: encryption,
-	}, nil
+	err = s.eachRow(`SELECT site_id, name, value FROM site_fields`+where, args, func(cols []string) {
+		if site := byID[cols[0]]; site != nil {
+			if site.CustomFields == nil {
+				site.CustomFields = make(map[string]string)
+			}
+			site.CustomFields[cols[1]] = cols[2]
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("failed to load site fields: %w", err)
+	}
+	return nil
 }
 
-// initDatabase initializes the SQLite database
-func initDatabase(dbPath string) (*sql.DB, error) {
-	db, err := sql.Open("sqlite3", dbPath)
+// eachRow runs a query of text columns and calls fn with the columns of each row
+func (s *Server) eachRow(query string, args []any, fn func(cols []string)) error {
+	rows, err := s.db.Query(query, args...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open database: %w", err)
+		return err
 	}
+	defer rows.Close()
 
-	// Create sites table if it doesn't exist
-	createTableSQL := `
-	CREATE TABLE IF NOT EXISTS sites (
-		id TEXT PRIMARY KEY,
-		name TEXT NOT NULL,
-		username TEXT NOT NULL,
-		password TEXT NOT NULL,
-		created TEXT NOT NULL,
-		modified TEXT NOT NULL
-	);`
-
-	_, err = db.Exec(createTableSQL)
+	names, err := rows.Columns()
 	if err != nil {
-		return nil, fmt.Errorf("failed to create table: %w", err)
+		return err
+	}
+	cols := make([]string, len(names))
+	dest := make([]any, len(names))
+	for i := range cols {
+		dest[i] = &cols[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return err
+		}
+		fn(cols)
 	}
+	return rows.Err()
+}
 
-	return db, nil
+// AuditEvent records an access to a secret
+type AuditEvent struct {
+	Time   string
+	Action string
+	SiteID string
+	Actor  string // principal name, or OS user for the CLI
+	Method string // how the actor authenticated: token, mtls, basic, none or cli
+	Client string // client IP, or host name for the CLI
 }
 
-// LoadSites loads sites from the database (no-op for database)
-func (s *Server) LoadSites() error {
-	// Database is already initialized, no need to load
-	s.logger.Info("Database initialized, ready to serve sites")
-	return nil
+// cliAuditEvent returns an audit event for the user running the CLI
+func cliAuditEvent() *AuditEvent {
+	actor := "unknown"
+	if u, err := user.Current(); err == nil {
+		actor = u.Username
+	}
+	host, _ := os.Hostname()
+	return &AuditEvent{Actor: actor, Method: "cli", Client: host}
 }
 
-// SaveSite saves a single site to the database
-func (s *Server) SaveSite(site *Site) error {
+// RecordAudit stores an audit event and logs it
+func (s *Server) RecordAudit(event *AuditEvent) error {
+	if event.Time == "" {
+		event.Time = time.Now().Format(time.RFC3339)
+	}
+
 	query := `
-	INSERT OR REPLACE INTO sites (id, name, username, password, created, modified)
+	INSERT INTO audit_events (time, action, site_id, actor, method, client)
 	VALUES (?, ?, ?, ?, ?, ?)`
 
-	_, err := s.db.Exec(query, site.ID, site.Name, site.Username, site.Password, site.Created, site.Modified)
+	_, err := s.db.Exec(query, event.Time, event.Action, event.SiteID, event.Actor, event.Method, event.Client)
 	if err != nil {
-		return fmt.Errorf("failed to save site: %w", err)
+		return fmt.Errorf("failed to record audit event: %w", err)
 	}
 
-	s.logger.Info("Saved site to database", zap.String("site_id", site.ID))
+	s.logger.Info("Audit event",
+		zap.String("action", event.Action),
+		zap.String("site_id", event.SiteID),
+		zap.String("actor", event.Actor),
+		zap.String("method", event.Method),
+		zap.String("client", event.Client),
+	)
 	return nil
 }
 
-// DeleteSite deletes a site from the database
-func (s *Server) DeleteSite(id string) error {
-	query := `DELETE FROM sites WHERE id = ?`
-	result, err := s.db.Exec(query, id)
+// RevealSecrets decrypts the password and custom fields of a site loaded by GetSite.
+// The reveal is recorded as an audit event first (action "reveal" unless set); the
+// secrets are not returned when that fails.
+func (s *Server) RevealSecrets(site *Site, event *AuditEvent) (*SiteSecrets, error) {
+	password, err := s.encryption.DecryptPassword(site.ID, fieldPassword, site.Password)
 	if err != nil {
-		return fmt.Errorf("failed to delete site: %w", err)
+		return nil, err
 	}
-
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return fmt.Errorf("failed to get rows affected: %w", err)
+	secrets := &SiteSecrets{Password: password}
+	for name, value := range site.CustomFields {
+		plaintext, err := s.encryption.DecryptPassword(site.ID, customField(name), value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt field '%s': %w", name, err)
+		}
+		if secrets.CustomFields == nil {
+			secrets.CustomFields = make(map[string]string)
+		}
+		secrets.CustomFields[name] = plaintext
 	}
 
-	if rowsAffected == 0 {
-		return fmt.Errorf("site not found")
+	if event.Action == "" {
+		event.Action = "reveal"
 	}
-
-	s.logger.Info("Deleted site from database", zap.String("site_id", id))
-	return nil
+	event.SiteID = site.ID
+	if err := s.RecordAudit(event); err != nil {
+		return nil, err
+	}
+	return secrets, nil
 }
 
-// GetSite retrieves a site from the database
-func (s *Server) GetSite(id string) (*Site, error) {
-	query := `SELECT id, name, username, password, created, modified FROM sites WHERE id = ?`
-	row := s.db.QueryRow(query, id)
+// TOTPCode computes the current TOTP code of a site loaded by GetSite and how long
+// it stays valid, recording an audit event with action "totp" first
+func (s *Server) TOTPCode(site *Site, event *AuditEvent) (string, time.Duration, error) {
+	if site.TOTPSeed == "" {
+		return "", 0, fmt.Errorf("site '%s' has no TOTP seed", site.ID)
+	}
 
-	site := &Site{}
-	err := row.Scan(&site.ID, &site.Name, &site.Username, &site.Password, &site.Created, &site.Modified)
+	seed, err := s.encryption.DecryptPassword(site.ID, fieldTOTPSeed, site.TOTPSeed)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("site not found")
-		}
-		return nil, fmt.Errorf("failed to get site: %w", err)
+		return "", 0, err
+	}
+	now := time.Now()
+	code, err := totpCode(seed, now)
+	if err != nil {
+		return "", 0, err
 	}
 
-	return site, nil
+	event.Action = "totp"
+	event.SiteID = site.ID
+	if err := s.RecordAudit(event); err != nil {
+		return "", 0, err
+	}
+	validFor := time.Duration(totpPeriod-now.Unix()%totpPeriod) * time.Second
+	return code, validFor, nil
 }
 
 // GetAllSites retrieves all sites from the database
 func (s *Server) GetAllSites() ([]*Site, error) {
-	query := `SELECT id, name, username, password, created, modified FROM sites ORDER BY created`
-	rows, err := s.db.Query(query)
+	return s.FindSites(SiteFilter{})
+}
+
+// FindSites retrieves the sites matching filter from the database
+func (s *Server) FindSites(filter SiteFilter) ([]*Site, error) {
+	var conditions []string
+	var args []any
+	for _, tag := range filter.Tags {
+		// tag is declared COLLATE NOCASE
+		conditions = append(conditions, `id IN (SELECT site_id FROM site_tags WHERE tag = ?)`)
+		args = append(args, strings.TrimSpace(tag))
+	}
+	if filter.URL != "" {
+		pattern := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(filter.URL)
+		conditions = append(conditions, `id IN (SELECT site_id FROM site_urls WHERE url LIKE ? ESCAPE '\')`)
+		args = append(args, "%"+pattern+"%")
+	}
+
+	query := `SELECT ` + siteColumns + ` FROM sites`
+	if len(conditions) > 0 {
+		query += ` WHERE ` + strings.Join(conditions, ` AND `)
+	}
+	query += ` ORDER BY created`
+
+	rows, err := s.db.Query(query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query sites: %w", err)
 	}
@@ -736,8 +2447,7 @@ func (s *Server) GetAllSites() ([]*Site, error) {
 
 	var sites []*Site
 	for rows.Next() {
-		site := &Site{}
-		err := rows.Scan(&site.ID, &site.Name, &site.Username, &site.Password, &site.Created, &site.Modified)
+		site, err := scanSite(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan site: %w", err)
 		}
@@ -748,9 +2458,187 @@ func (s *Server) GetAllSites() ([]*Site, error) {
 		return nil, fmt.Errorf("error iterating rows: %w", err)
 	}
 
+	if err := s.loadSiteDetails(sites); err != nil {
+		return nil, err
+	}
 	return sites, nil
 }
 
+// RekeySites re-encrypts the passwords, custom fields and TOTP seeds of all sites with
+// the current key in one transaction, returning how many sites changed. With dryRun
+// nothing is written.
+func (s *Server) RekeySites(dryRun bool) (int, int, error) {
+	sites, err := s.GetAllSites()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rekeyed := 0
+	for _, site := range sites {
+		type update struct {
+			query string
+			args  []any
+		}
+		var updates []update
+		password, changed, err := s.encryption.Rekey(site.ID, fieldPassword, site.Password)
+		if err != nil {
+			return 0, 0, fmt.Errorf("failed to rekey site '%s': %w", site.ID, err)
+		}
+		if changed {
+			updates = append(updates, update{`UPDATE sites SET password = ? WHERE id = ?`, []any{password, site.ID}})
+		}
+		if site.TOTPSeed != "" {
+			seed, changed, err := s.encryption.Rekey(site.ID, fieldTOTPSeed, site.TOTPSeed)
+			if err != nil {
+				return 0, 0, fmt.Errorf("failed to rekey TOTP seed of site '%s': %w", site.ID, err)
+			}
+			if changed {
+				updates = append(updates, update{`UPDATE sites SET totp_seed = ? WHERE id = ?`, []any{seed, site.ID}})
+			}
+		}
+		for name, value := range site.CustomFields {
+			value, changed, err := s.encryption.Rekey(site.ID, customField(name), value)
+			if err != nil {
+				return 0, 0, fmt.Errorf("failed to rekey field '%s' of site '%s': %w", name, site.ID, err)
+			}
+			if changed {
+				updates = append(updates, update{`UPDATE site_fields SET value = ? WHERE site_id = ? AND name = ?`, []any{value, site.ID, name}})
+			}
+		}
+
+		if len(updates) == 0 {
+			continue
+		}
+		rekeyed++
+		if dryRun {
+			continue
+		}
+
+		// The modified time stays, the site itself didn't change
+		for _, u := range updates {
+			if _, err := tx.Exec(u.query, u.args...); err != nil {
+				return 0, 0, fmt.Errorf("failed to save site '%s': %w", site.ID, err)
+			}
+		}
+	}
+
+	if dryRun {
+		return rekeyed, len(sites), nil
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	s.logger.Info("Rekeyed sites", zap.Int("rekeyed", rekeyed), zap.Int("total", len(sites)),
+		zap.String("key_id", s.encryption.currentKeyID))
+	return rekeyed, len(sites), nil
+}
+
+// PasswordFinding is a site flagged by the password audit
+type PasswordFinding struct {
+	SiteID     string   `json:"site_id"`
+	Name       string   `json:"name"`
+	Username   string   `json:"username"`
+	Issues     []string `json:"issues"` // weak, reused and/or old
+	Entropy    float64  `json:"entropy_bits"`
+	AgeDays    int      `json:"age_days"`
+	ReusedWith []string `json:"reused_with,omitempty"`
+}
+
+// PasswordReport is the result of the password audit
+type PasswordReport struct {
+	Time       string             `json:"time"`
+	MinEntropy float64            `json:"min_entropy_bits"`
+	MaxAgeDays int                `json:"max_age_days"`
+	Sites      int                `json:"sites"`
+	Weak       int                `json:"weak"`
+	Reused     int                `json:"reused"`
+	Old        int                `json:"old"`
+	Findings   []*PasswordFinding `json:"findings"`
+}
+
+// AuditPasswords checks the passwords of all sites. Passwords with less entropy than
+// password_audit.min_entropy are weak, passwords shared by sites are reused and
+// passwords set more than password_audit.max_age_days ago are old. The passwords are
+// only decrypted in memory; the report holds none of them.
+func (s *Server) AuditPasswords() (*PasswordReport, error) {
+	sites, err := s.GetAllSites()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	report := &PasswordReport{
+		Time:       now.Format(time.RFC3339),
+		MinEntropy: s.config.GetFloat64("password_audit.min_entropy"),
+		MaxAgeDays: s.config.GetInt("password_audit.max_age_days"),
+		Sites:      len(sites),
+		Findings:   []*PasswordFinding{},
+	}
+
+	// Reuse is found by the SHA256 of the passwords, so they needn't be kept around
+	findings := make([]*PasswordFinding, len(sites))
+	sums := make([][32]byte, len(sites))
+	sitesBySum := make(map[[32]byte][]string)
+	for i, site := range sites {
+		password, err := s.encryption.DecryptPassword(site.ID, fieldPassword, site.Password)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt site '%s': %w", site.ID, err)
+		}
+		sums[i] = sha256.Sum256([]byte(password))
+		sitesBySum[sums[i]] = append(sitesBySum[sums[i]], site.ID)
+
+		userInputs := append([]string{site.ID, site.Name, site.Username}, site.URLs...)
+		findings[i] = &PasswordFinding{
+			SiteID:   site.ID,
+			Name:     site.Name,
+			Username: site.Username,
+			Entropy:  math.Round(passwordEntropy(password, userInputs)*10) / 10,
+		}
+
+		changed := site.PasswordChanged
+		if changed == "" {
+			changed = site.Created
+		}
+		if t, err := time.Parse(time.RFC3339, changed); err == nil {
+			findings[i].AgeDays = int(now.Sub(t).Hours() / 24)
+		}
+	}
+
+	for i, finding := range findings {
+		if finding.Entropy < report.MinEntropy {
+			finding.Issues = append(finding.Issues, "weak")
+			report.Weak++
+		}
+		if ids := sitesBySum[sums[i]]; len(ids) > 1 {
+			for _, id := range ids {
+				if id != finding.SiteID {
+					finding.ReusedWith = append(finding.ReusedWith, id)
+				}
+			}
+			finding.Issues = append(finding.Issues, "reused")
+			report.Reused++
+		}
+		if report.MaxAgeDays > 0 && finding.AgeDays > report.MaxAgeDays {
+			finding.Issues = append(finding.Issues, "old")
+			report.Old++
+		}
+		if len(finding.Issues) > 0 {
+			report.Findings = append(report.Findings, finding)
+		}
+	}
+
+	s.logger.Info("Audited passwords", zap.Int("sites", report.Sites), zap.Int("weak", report.Weak),
+		zap.Int("reused", report.Reused), zap.Int("old", report.Old))
+	return report, nil
+}
+
 // SetupRoutes sets up the HTTP routes
 func (s *Server) SetupRoutes() {
 	// Create router with custom logger
@@ -761,21 +2649,56 @@ func (s *Server) SetupRoutes() {
 	s.router.Use(gin.Recovery())
 	s.router.Use(s.loggingMiddleware())
 
-	// Health check endpoint
+	// Health check endpoint, reachable without credentials
 	s.router.GET("/health", s.healthHandler)
 
 	// Sites CRUD endpoints
 	sites := s.router.Group("/sites")
 	{
-		sites.GET("", s.getSites)
-		sites.GET("/:id", s.getSite)
-		sites.POST("", s.createSite)
-		sites.PUT("/:id", s.updateSite)
-		sites.DELETE("/:id", s.deleteSite)
+		sites.GET("", s.requireScope(ScopeRead), s.getSites)
+		sites.GET("/:id", s.requireScope(ScopeRead), s.getSite)
+		sites.GET("/:id/secret", s.requireScope(ScopeWrite), s.getSiteSecret)
+		sites.GET("/:id/totp", s.requireScope(ScopeWrite), s.getSiteTOTP)
+		sites.POST("", s.requireScope(ScopeWrite), s.createSite)
+		sites.PUT("/:id", s.requireScope(ScopeWrite), s.updateSite)
+		sites.DELETE("/:id", s.requireScope(ScopeWrite), s.deleteSite)
 	}
 
+	// Reports over all sites, kept out of /sites so they can't shadow a site ID
+	s.router.GET("/audit/passwords", s.requireScope(ScopeWrite), s.getPasswordAudit)
+
 	// Commands endpoint
-	s.router.POST("/commands", s.executeCommand)
+	s.router.POST("/commands", s.requireScope(ScopeWrite), s.executeCommand)
+}
+
+// requireScope creates a middleware that authenticates the request and checks that
+// its credentials were granted scope
+func (s *Server) requireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := s.auth.Authenticate(c.Request)
+		if !ok {
+			s.logger.Warn("Authentication failed", zap.String("path", c.Request.URL.Path), zap.String("client_ip", c.ClientIP()))
+			c.Header("WWW-Authenticate", s.auth.challenge())
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+			return
+		}
+		c.Set(principalKey, principal)
+
+		if !principal.HasScope(scope) {
+			s.logger.Warn("Insufficient scope", zap.String("principal", principal.Name), zap.String("scope", scope))
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Insufficient scope", "required_scope": scope})
+			return
+		}
+		c.Next()
+	}
+}
+
+// currentPrincipal returns the principal authenticated by requireScope
+func currentPrincipal(c *gin.Context) *Principal {
+	if principal, ok := c.Get(principalKey); ok {
+		return principal.(*Principal)
+	}
+	return nil
 }
 
 // loggingMiddleware creates a middleware for HTTP request/response logging
@@ -799,10 +2722,16 @@ func (s *Server) loggingMiddleware() gin.HandlerFunc {
 			path = path + "?" + raw
 		}
 
+		principal := "-"
+		if p := currentPrincipal(c); p != nil {
+			principal = p.Name
+		}
+
 		s.logger.Info("HTTP Request",
 			zap.String("method", method),
 			zap.String("path", path),
 			zap.String("client_ip", clientIP),
+			zap.String("principal", principal),
 			zap.Int("status", statusCode),
 			zap.Duration("latency", latency),
 			zap.Int("body_size", bodySize),
@@ -817,24 +2746,18 @@ func (s *Server) healthHandler(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"status": "ok"})
 }
 
-// getSites returns all sites
+// getSites returns all sites, or those matching the tag and url query parameters
 func (s *Server) getSites(c *gin.Context) {
-	sites, err := s.GetAllSites()
+	sites, err := s.FindSites(SiteFilter{Tags: c.QueryArray("tag"), URL: c.Query("url")})
 	if err != nil {
 		s.logger.Error("Failed to get sites", zap.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve sites"})
 		return
 	}
 
-	// Decrypt passwords for response
+	// Mask secrets for response, see getSiteSecret
 	for _, site := range sites {
-		decryptedPassword, err := s.encryption.DecryptPassword(site.Password)
-		if err != nil {
-			s.logger.Error("Failed to decrypt password", zap.String("site_id", site.ID), zap.Error(err))
-			site.Password = "[ENCRYPTED]"
-		} else {
-			site.Password = decryptedPassword
-		}
+		maskSecrets(site)
 	}
 
 	s.logger.Info("Retrieved all sites", zap.Int("count", len(sites)))
@@ -851,19 +2774,84 @@ func (s *Server) getSite(c *gin.Context) {
 		return
 	}
 
-	// Decrypt password for response
-	decryptedPassword, err := s.encryption.DecryptPassword(site.Password)
-	if err != nil {
-		s.logger.Error("Failed to decrypt password", zap.String("site_id", id), zap.Error(err))
-		site.Password = "[ENCRYPTED]"
-	} else {
-		site.Password = decryptedPassword
-	}
+	// Mask secrets for response, see getSiteSecret
+	maskSecrets(site)
 
 	s.logger.Info("Retrieved site", zap.String("site_id", id))
 	c.JSON(http.StatusOK, site)
 }
 
+// getSiteSecret returns the decrypted password and custom fields of a site and records
+// an audit event
+func (s *Server) getSiteSecret(c *gin.Context) {
+	id := c.Param("id")
+	site, err := s.GetSite(id)
+	if err != nil {
+		s.logger.Warn("Site not found", zap.String("site_id", id), zap.Error(err))
+		c.JSON(http.StatusNotFound, gin.H{"error": "Site not found"})
+		return
+	}
+
+	principal := currentPrincipal(c)
+	secrets, err := s.RevealSecrets(site, &AuditEvent{
+		Actor:  principal.Name,
+		Method: principal.Method,
+		Client: c.ClientIP(),
+	})
+	if err != nil {
+		s.logger.Error("Failed to reveal password", zap.String("site_id", id), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reveal password"})
+		return
+	}
+
+	// Keep the secret out of browser and proxy caches
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, gin.H{"id": site.ID, "password": secrets.Password, "custom_fields": secrets.CustomFields})
+}
+
+// getSiteTOTP returns the current TOTP code of a site and records an audit event
+func (s *Server) getSiteTOTP(c *gin.Context) {
+	id := c.Param("id")
+	site, err := s.GetSite(id)
+	if err != nil {
+		s.logger.Warn("Site not found", zap.String("site_id", id), zap.Error(err))
+		c.JSON(http.StatusNotFound, gin.H{"error": "Site not found"})
+		return
+	}
+	if site.TOTPSeed == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Site has no TOTP seed"})
+		return
+	}
+
+	principal := currentPrincipal(c)
+	code, validFor, err := s.TOTPCode(site, &AuditEvent{
+		Actor:  principal.Name,
+		Method: principal.Method,
+		Client: c.ClientIP(),
+	})
+	if err != nil {
+		s.logger.Error("Failed to get TOTP code", zap.String("site_id", id), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get TOTP code"})
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, gin.H{"id": id, "code": code, "valid_for": int(validFor.Seconds())})
+}
+
+// getPasswordAudit returns the password audit report, see AuditPasswords
+func (s *Server) getPasswordAudit(c *gin.Context) {
+	report, err := s.AuditPasswords()
+	if err != nil {
+		s.logger.Error("Failed to audit passwords", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to audit passwords"})
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, report)
+}
+
 // createSite creates a new site
 func (s *Server) createSite(c *gin.Context) {
 	var site Site
@@ -887,11 +2875,8 @@ func (s *Server) createSite(c *gin.Context) {
 		return
 	}
 
-	// Store the original password for response
-	originalPassword := site.Password
-
-	// Encrypt the password before storing
-	encryptedPassword, err := s.encryption.EncryptPassword(site.Password)
+	// Encrypt the password, custom fields and TOTP seed before storing
+	encryptedPassword, err := s.encryption.EncryptPassword(site.ID, fieldPassword, site.Password)
 	if err != nil {
 		s.logger.Error("Failed to encrypt password", zap.String("site_id", site.ID), zap.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to encrypt password"})
@@ -899,9 +2884,18 @@ func (s *Server) createSite(c *gin.Context) {
 	}
 	site.Password = encryptedPassword
 
+	fields, seed := site.CustomFields, site.TOTPSeed
+	site.CustomFields = nil
+	if err := s.setSecretDetails(&site, fields, &seed); err != nil {
+		s.logger.Warn("Site creation failed: invalid details", zap.String("site_id", site.ID), zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	now := time.Now().Format(time.RFC3339)
 	site.Created = now
 	site.Modified = now
+	site.PasswordChanged = now
 
 	if err := s.SaveSite(&site); err != nil {
 		s.logger.Error("Failed to save site", zap.String("site_id", site.ID), zap.Error(err))
@@ -909,18 +2903,25 @@ func (s *Server) createSite(c *gin.Context) {
 		return
 	}
 
-	// Return the site with original password for response
-	siteResponse := Site{
-		ID:       site.ID,
-		Name:     site.Name,
-		Username: site.Username,
-		Password: originalPassword,
-		Created:  site.Created,
-		Modified: site.Modified,
-	}
+	// Return the site with masked secrets for response
+	maskSecrets(&site)
 
 	s.logger.Info("Site created successfully", zap.String("site_id", site.ID), zap.String("name", site.Name))
-	c.JSON(http.StatusCreated, &siteResponse)
+	c.JSON(http.StatusCreated, &site)
+}
+
+// siteUpdate is the body of PUT /sites/:id. Empty name, username and password and
+// missing urls, notes, tags and totp_seed are left unchanged; custom_fields are merged
+// into the existing ones, an empty value removes a field.
+type siteUpdate struct {
+	Name         string            `json:"name"`
+	Username     string            `json:"username"`
+	Password     string            `json:"password"`
+	URLs         []string          `json:"urls"`
+	Notes        *string           `json:"notes"`
+	Tags         []string          `json:"tags"`
+	CustomFields map[string]string `json:"custom_fields"`
+	TOTPSeed     *string           `json:"totp_seed"`
 }
 
 // updateSite updates an existing site
@@ -933,7 +2934,7 @@ func (s *Server) updateSite(c *gin.Context) {
 		return
 	}
 
-	var updateData Site
+	var updateData siteUpdate
 	if err := c.ShouldBindJSON(&updateData); err != nil {
 		s.logger.Error("Failed to bind JSON for site update", zap.String("site_id", id), zap.Error(err))
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -949,7 +2950,7 @@ func (s *Server) updateSite(c *gin.Context) {
 	}
 	if updateData.Password != "" {
 		// Encrypt the new password before storing
-		encryptedPassword, err := s.encryption.EncryptPassword(updateData.Password)
+		encryptedPassword, err := s.encryption.EncryptPassword(site.ID, fieldPassword, updateData.Password)
 		if err != nil {
 			s.logger.Error("Failed to encrypt password during update", zap.String("site_id", id), zap.Error(err))
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to encrypt password"})
@@ -957,8 +2958,25 @@ func (s *Server) updateSite(c *gin.Context) {
 		}
 		site.Password = encryptedPassword
 	}
+	if updateData.URLs != nil {
+		site.URLs = updateData.URLs
+	}
+	if updateData.Notes != nil {
+		site.Notes = *updateData.Notes
+	}
+	if updateData.Tags != nil {
+		site.Tags = updateData.Tags
+	}
+	if err := s.setSecretDetails(site, updateData.CustomFields, updateData.TOTPSeed); err != nil {
+		s.logger.Warn("Site update failed: invalid details", zap.String("site_id", id), zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	site.Modified = time.Now().Format(time.RFC3339)
+	if updateData.Password != "" {
+		site.PasswordChanged = site.Modified
+	}
 
 	if err := s.SaveSite(site); err != nil {
 		s.logger.Error("Failed to save site after update", zap.String("site_id", id), zap.Error(err))
@@ -966,14 +2984,8 @@ func (s *Server) updateSite(c *gin.Context) {
 		return
 	}
 
-	// Return the site with decrypted password for response
-	decryptedPassword, err := s.encryption.DecryptPassword(site.Password)
-	if err != nil {
-		s.logger.Error("Failed to decrypt password for response", zap.String("site_id", id), zap.Error(err))
-		site.Password = "[ENCRYPTED]"
-	} else {
-		site.Password = decryptedPassword
-	}
+	// Return the site with masked secrets for response
+	maskSecrets(site)
 
 	s.logger.Info("Site updated successfully", zap.String("site_id", id))
 	c.JSON(http.StatusOK, site)
@@ -1060,20 +3072,44 @@ func (s *Server) Start() error {
 		return fmt.Errorf("failed to load sites: %w", err)
 	}
 
+	// Setup authentication
+	auth, err := NewAuthenticator(s.config)
+	if err != nil {
+		return fmt.Errorf("failed to setup authentication: %w", err)
+	}
+	s.auth = auth
+	if !auth.enabled {
+		s.logger.Warn("Authentication is disabled, anyone who can reach the server can read and change sites")
+	}
+
 	// Setup routes
 	s.SetupRoutes()
 
+	// Setup TLS, with client certificates for mTLS
+	tlsConfig, err := s.tlsConfig()
+	if err != nil {
+		return fmt.Errorf("failed to setup TLS: %w", err)
+	}
+
 	// Create HTTP server
 	port := s.config.GetInt("server.port")
 	s.httpServer = &http.Server{
-		Addr:    fmt.Sprintf(":%d", port),
-		Handler: s.router,
+		Addr:      fmt.Sprintf(":%d", port),
+		Handler:   s.router,
+		TLSConfig: tlsConfig,
 	}
 
 	// Start server in a goroutine
 	go func() {
-		s.logger.Info("Server starting", zap.Int("port", port))
-		if err := s.httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		var err error
+		if tlsConfig != nil {
+			s.logger.Info("Server starting with TLS", zap.Int("port", port), zap.Bool("mtls", tlsConfig.ClientCAs != nil))
+			err = s.httpServer.ListenAndServeTLS(s.config.GetString("server.tls.cert_file"), s.config.GetString("server.tls.key_file"))
+		} else {
+			s.logger.Info("Server starting", zap.Int("port", port))
+			err = s.httpServer.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			s.logger.Error("Server error", zap.Error(err))
 		}
 	}()
@@ -1081,6 +3117,36 @@ func (s *Server) Start() error {
 	return nil
 }
 
+// tlsConfig returns the TLS configuration of the server, or nil to serve plain HTTP.
+// Client certificates are requested but optional, so token and basic auth keep working.
+func (s *Server) tlsConfig() (*tls.Config, error) {
+	certFile := s.config.GetString("server.tls.cert_file")
+	keyFile := s.config.GetString("server.tls.key_file")
+	mtls := s.config.GetBool("auth.enabled") && s.config.GetBool("auth.mtls.enabled")
+	if certFile == "" || keyFile == "" {
+		if mtls {
+			return nil, fmt.Errorf("auth.mtls requires server.tls.cert_file and server.tls.key_file")
+		}
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if mtls {
+		caFile := s.config.GetString("auth.mtls.client_ca_file")
+		caPEM, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("no certificates found in %s", caFile)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return tlsConfig, nil
+}
+
 // Shutdown gracefully shuts down the server
 func (s *Server) Shutdown(ctx context.Context) error {
 	if s.httpServer != nil {
@@ -1171,6 +3237,10 @@ func loadConfig() (*viper.Viper, error) {
 	viper.SetDefault("logging.file.max_age", 30)
 	viper.SetDefault("logging.file.max_backups", 10)
 	viper.SetDefault("logging.file.compress", true)
+	viper.SetDefault("auth.enabled", true)
+	viper.SetDefault("clipboard.sink", "auto")
+	viper.SetDefault("password_audit.min_entropy", 60)
+	viper.SetDefault("password_audit.max_age_days", 365)
 
 	// Read config file
 	if err := viper.ReadInConfig(); err != nil {
@@ -1188,14 +3258,36 @@ func loadConfig() (*viper.Viper, error) {
 
 func main() {
 	// Add subcommands
+	for _, cmd := range []*cobra.Command{encryptCmd, decryptCmd} {
+		cmd.Flags().String("site", "", "ID of the site the password belongs to")
+		cmd.Flags().String("field", fieldPassword, "Field of the site: password, totp_seed or field:<name>")
+	}
 	rootCmd.AddCommand(encryptCmd)
 	rootCmd.AddCommand(decryptCmd)
 	rootCmd.AddCommand(serverCmd)
+	rootCmd.AddCommand(hashPasswordCmd)
+	rekeyCmd.Flags().Bool("dry-run", false, "Only count the sites that would be re-encrypted")
+	rootCmd.AddCommand(rekeyCmd)
 
 	// Add sites subcommands
+	sitesListCmd.Flags().StringSlice("tag", nil, "Only list sites with this tag (repeatable)")
+	sitesListCmd.Flags().String("url", "", "Only list sites with a URL containing this text")
 	sitesCmd.AddCommand(sitesListCmd)
 	sitesCmd.AddCommand(sitesGetCmd)
+	sitesRevealCmd.Flags().Bool("copy", false, "Copy the password to the clipboard instead of printing it")
+	sitesCmd.AddCommand(sitesRevealCmd)
+	sitesCmd.AddCommand(sitesTOTPCmd)
+	sitesAuditCmd.Flags().Bool("json", false, "Print the report as JSON")
+	sitesCmd.AddCommand(sitesAuditCmd)
+	addSiteFlags(sitesCreateCmd)
+	sitesCreateCmd.Flags().Bool("generate", false, "Generate the password instead of passing it")
+	sitesCreateCmd.Flags().Int("length", 20, "Length of a generated password")
+	sitesCreateCmd.Flags().StringSlice("charset", []string{"lower", "upper", "digits", "symbols"}, "Charsets of a generated password: lower, upper, digits, symbols")
+	sitesCreateCmd.Flags().Bool("passphrase", false, "Generate a passphrase of words instead of a password")
+	sitesCreateCmd.Flags().Int("words", 6, "Words of a generated passphrase")
+	sitesCreateCmd.Flags().String("separator", "-", "Separator of the words of a generated passphrase")
 	sitesCmd.AddCommand(sitesCreateCmd)
+	addSiteFlags(sitesUpdateCmd)
 	sitesCmd.AddCommand(sitesUpdateCmd)
 	sitesCmd.AddCommand(sitesDeleteCmd)
 	rootCmd.AddCommand(sitesCmd)
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"database/sql"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// TestTOTPCode checks the SHA1 test vectors of RFC 6238, appendix B, cut to six digits
+func TestTOTPCode(t *testing.T) {
+	// base32 of the ASCII seed "12345678901234567890"
+	const seed = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	vectors := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, v := range vectors {
+		code, err := totpCode(seed, time.Unix(v.unix, 0))
+		if err != nil {
+			t.Fatalf("totpCode at %d: %v", v.unix, err)
+		}
+		if code != v.code {
+			t.Errorf("totpCode at %d = %s, want %s", v.unix, code, v.code)
+		}
+	}
+
+	// Seeds are accepted the way authenticator apps show them
+	code, err := totpCode("gezd gnbv-gy3t qojq gezd gnbv gy3t qojq====", time.Unix(59, 0))
+	if err != nil || code != "287082" {
+		t.Errorf("totpCode of a formatted seed = %q, %v, want 287082", code, err)
+	}
+	if _, err := totpCode("not base32!", time.Now()); err == nil {
+		t.Error("totpCode accepted an invalid seed")
+	}
+}
+
+// TestMigrateDatabase upgrades a database created before migrations (user_version 0)
+func TestMigrateDatabase(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "sites.db")
+	old, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = old.Exec(`
+	CREATE TABLE sites (
+		id TEXT PRIMARY KEY,
+		name TEXT NOT NULL,
+		username TEXT NOT NULL,
+		password TEXT NOT NULL,
+		created TEXT NOT NULL,
+		modified TEXT NOT NULL
+	);
+	INSERT INTO sites VALUES ('site-1', 'example', 'alice', 'secret', '2024-01-01T00:00:00Z', '2024-06-01T00:00:00Z');`)
+	old.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := initDatabase(dbPath)
+	if err != nil {
+		t.Fatalf("initDatabase: %v", err)
+	}
+	defer db.Close()
+
+	var version int
+	if err := db.QueryRow(`PRAGMA user_version`).Scan(&version); err != nil {
+		t.Fatal(err)
+	}
+	if version != len(migrations) {
+		t.Errorf("user_version = %d, want %d", version, len(migrations))
+	}
+
+	var notes, seed, modified, passwordChanged string
+	err = db.QueryRow(`SELECT notes, totp_seed, modified, password_changed FROM sites WHERE id = 'site-1'`).
+		Scan(&notes, &seed, &modified, &passwordChanged)
+	if err != nil {
+		t.Fatalf("migrated site: %v", err)
+	}
+	if notes != "" || seed != "" {
+		t.Errorf("notes, totp_seed = %q, %q, want empty", notes, seed)
+	}
+	if passwordChanged != modified {
+		t.Errorf("password_changed = %q, want modified %q", passwordChanged, modified)
+	}
+	for _, table := range []string{"site_urls", "site_tags", "site_fields"} {
+		if _, err := db.Exec(`SELECT COUNT(*) FROM ` + table); err != nil {
+			t.Errorf("table %s: %v", table, err)
+		}
+	}
+
+	// Migrating again is a no-op
+	if err := migrateDatabase(db); err != nil {
+		t.Errorf("migrateDatabase of an up to date database: %v", err)
+	}
+}
+
+// newTestEncryption creates an encryption service with the keyring ids, each with the
+// secret "secret-<id>", whose current key is current
+func newTestEncryption(t *testing.T, db *sql.DB, current string, ids ...string) *EncryptionService {
+	t.Helper()
+	var keys []map[string]interface{}
+	for _, id := range ids {
+		keys = append(keys, map[string]interface{}{"id": id, "secret": "secret-" + id})
+	}
+	config := viper.New()
+	config.Set("encryption.keys", keys)
+	config.Set("encryption.current_key", current)
+	es, err := NewEncryptionService(config, db)
+	if err != nil {
+		t.Fatalf("NewEncryptionService: %v", err)
+	}
+	return es
+}
+
+// TestEncryptRekeyDecrypt rotates the master key of v3, v2 and legacy ciphertexts
+func TestEncryptRekeyDecrypt(t *testing.T) {
+	db, err := initDatabase(filepath.Join(t.TempDir(), "sites.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	old := newTestEncryption(t, db, "old", "old")
+	v3, err := old.EncryptPassword("site-1", fieldPassword, "v3 password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(v3, "v3:old:") {
+		t.Errorf("ciphertext %q is not v3 under key old", v3)
+	}
+	if _, err := old.DecryptPassword("site-2", fieldPassword, v3); err == nil {
+		t.Error("v3 ciphertext decrypted for another site")
+	}
+	if _, err := old.DecryptPassword("site-1", fieldTOTPSeed, v3); err == nil {
+		t.Error("v3 ciphertext decrypted for another field")
+	}
+
+	// A v2 ciphertext isn't bound to a site and field
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := sealAESGCM(dataKey, []byte("v2 password"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v2, err := old.wrap(ciphertextV2, dataKey, base64.StdEncoding.EncodeToString(sealed))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A legacy ciphertext is encrypted directly with the SHA256 of the secret
+	legacyKey := sha256.Sum256([]byte("secret-old"))
+	sealed, err = sealAESGCM(legacyKey[:], []byte("legacy password"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy := base64.StdEncoding.EncodeToString(sealed)
+
+	ciphertexts := map[string]string{v3: "v3 password", v2: "v2 password", legacy: "legacy password"}
+
+	// Rotate: add the key new and make it current
+	rotating := newTestEncryption(t, db, "new", "old", "new")
+	rekeyed := map[string]string{}
+	for ciphertext, password := range ciphertexts {
+		got, err := rotating.DecryptPassword("site-1", fieldPassword, ciphertext)
+		if err != nil || got != password {
+			t.Fatalf("DecryptPassword before rekey = %q, %v, want %q", got, err, password)
+		}
+		result, changed, err := rotating.Rekey("site-1", fieldPassword, ciphertext)
+		if err != nil || !changed {
+			t.Fatalf("Rekey of %q = %v, %v, want changed", password, changed, err)
+		}
+		if !strings.HasPrefix(result, "v3:new:") {
+			t.Errorf("rekeyed %q is not v3 under key new: %q", password, result)
+		}
+		if _, changed, err := rotating.Rekey("site-1", fieldPassword, result); err != nil || changed {
+			t.Errorf("Rekey of rekeyed %q = %v, %v, want unchanged", password, changed, err)
+		}
+		rekeyed[result] = password
+	}
+
+	// Remove the key old: rekeyed secrets still decrypt, the originals no longer do
+	rotated := newTestEncryption(t, db, "new", "new")
+	for ciphertext, password := range rekeyed {
+		got, err := rotated.DecryptPassword("site-1", fieldPassword, ciphertext)
+		if err != nil || got != password {
+			t.Errorf("DecryptPassword after rekey = %q, %v, want %q", got, err, password)
+		}
+	}
+	for ciphertext, password := range ciphertexts {
+		if _, err := rotated.DecryptPassword("site-1", fieldPassword, ciphertext); err == nil {
+			t.Errorf("%q decrypted without the key old", password)
+		}
+	}
+}
+
+// clientCert returns the state of a TLS connection with a verified client certificate
+func clientCert(commonName string) *tls.ConnectionState {
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: commonName}}
+	return &tls.ConnectionState{
+		PeerCertificates: []*x509.Certificate{cert},
+		VerifiedChains:   [][]*x509.Certificate{{cert}},
+	}
+}
+
+// TestAuthentication sends requests with read-only and wrong credentials of every
+// auth method through the API routes
+func TestAuthentication(t *testing.T) {
+	hash := func(password string) string {
+		h, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(h)
+	}
+
+	config := viper.New()
+	config.Set("data.data_dir", t.TempDir())
+	config.Set("encryption.keys", []map[string]interface{}{{"id": "default", "secret": "test-secret"}})
+	config.Set("auth.enabled", true)
+	config.Set("auth.tokens", []map[string]interface{}{
+		{"name": "admin", "token": "admin-token", "scopes": []string{ScopeRead, ScopeWrite}},
+		{"name": "reader", "token": "read-token", "scopes": []string{ScopeRead}},
+	})
+	config.Set("auth.basic.enabled", true)
+	config.Set("auth.basic.users", []map[string]interface{}{
+		{"username": "reader", "password_hash": hash("read-password"), "scopes": []string{ScopeRead}},
+	})
+	config.Set("auth.mtls.enabled", true)
+	config.Set("auth.mtls.clients", []map[string]interface{}{
+		{"common_name": "backup-job", "scopes": []string{ScopeRead}},
+	})
+
+	server, err := NewServer(config, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.db.Close()
+	if server.auth, err = NewAuthenticator(config); err != nil {
+		t.Fatal(err)
+	}
+	server.SetupRoutes()
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		auth   func(r *http.Request)
+		status int
+	}{
+		{"no credentials", "GET", "/sites", func(r *http.Request) {}, http.StatusUnauthorized},
+
+		{"read token", "GET", "/sites", func(r *http.Request) { r.Header.Set("Authorization", "Bearer read-token") }, http.StatusOK},
+		{"read token writes", "POST", "/sites", func(r *http.Request) { r.Header.Set("Authorization", "Bearer read-token") }, http.StatusForbidden},
+		{"read API key reveals", "GET", "/sites/site-1/secret", func(r *http.Request) { r.Header.Set("X-API-Key", "read-token") }, http.StatusForbidden},
+		{"wrong token", "GET", "/sites", func(r *http.Request) { r.Header.Set("Authorization", "Bearer wrong-token") }, http.StatusUnauthorized},
+		{"wrong API key", "GET", "/sites", func(r *http.Request) { r.Header.Set("X-API-Key", "wrong-token") }, http.StatusUnauthorized},
+
+		{"read client certificate", "GET", "/sites", func(r *http.Request) { r.TLS = clientCert("backup-job") }, http.StatusOK},
+		{"read client certificate writes", "POST", "/sites", func(r *http.Request) { r.TLS = clientCert("backup-job") }, http.StatusForbidden},
+		{"unknown client certificate", "GET", "/sites", func(r *http.Request) { r.TLS = clientCert("intruder") }, http.StatusUnauthorized},
+		{"unverified client certificate", "GET", "/sites", func(r *http.Request) {
+			r.TLS = clientCert("backup-job")
+			r.TLS.VerifiedChains = nil
+		}, http.StatusUnauthorized},
+
+		{"read basic user", "GET", "/sites", func(r *http.Request) { r.SetBasicAuth("reader", "read-password") }, http.StatusOK},
+		{"read basic user writes", "POST", "/sites", func(r *http.Request) { r.SetBasicAuth("reader", "read-password") }, http.StatusForbidden},
+		{"wrong basic password", "GET", "/sites", func(r *http.Request) { r.SetBasicAuth("reader", "wrong-password") }, http.StatusUnauthorized},
+		{"unknown basic user", "GET", "/sites", func(r *http.Request) { r.SetBasicAuth("nobody", "read-password") }, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader("{}"))
+			req.Header.Set("Content-Type", "application/json")
+			tt.auth(req)
+			w := httptest.NewRecorder()
+			server.router.ServeHTTP(w, req)
+			if w.Code != tt.status {
+				t.Errorf("%s %s = %d, want %d: %s", tt.method, tt.path, w.Code, tt.status, w.Body)
+			}
+			if w.Code == http.StatusUnauthorized && !strings.Contains(w.Header().Get("WWW-Authenticate"), "Basic") {
+				t.Errorf("WWW-Authenticate = %q, want a Basic challenge", w.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
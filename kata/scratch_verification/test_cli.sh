@@ -6,6 +6,7 @@ echo "======================"
 
 # Set AES key
 export AES_KEY="my-secret-encryption-key-2024"
+export VAULT_ADMIN_TOKEN="${VAULT_ADMIN_TOKEN:-change-me-admin-token}"
 
 echo -e "\n1. Testing help command:"
 ./vault --help
@@ -1,7 +1,11 @@
 #!/bin/bash
 
 # Test script for the HTTP server
-# Make sure the server is running on port 8080 before running this script
+# Make sure the server is running on port 8080 before running this script,
+# with the same VAULT_ADMIN_TOKEN and VAULT_READ_TOKEN as below
+
+ADMIN_TOKEN="${VAULT_ADMIN_TOKEN:-change-me-admin-token}"
+READ_TOKEN="${VAULT_READ_TOKEN:-change-me-read-token}"
 
 echo "Testing HTTP Server API endpoints..."
 echo "=================================="
@@ -12,7 +16,7 @@ curl -s http://localhost:8080/health | jq .
 
 # Test create site
 echo -e "\n2. Creating a site:"
-curl -s -X POST http://localhost:8080/sites \
+curl -s -H "Authorization: Bearer $ADMIN_TOKEN" -X POST http://localhost:8080/sites \
   -H "Content-Type: application/json" \
   -d '{
     "id": "site1",
@@ -23,7 +27,7 @@ curl -s -X POST http://localhost:8080/sites \
 
 # Test create another site
 echo -e "\n3. Creating another site:"
-curl -s -X POST http://localhost:8080/sites \
+curl -s -H "Authorization: Bearer $ADMIN_TOKEN" -X POST http://localhost:8080/sites \
   -H "Content-Type: application/json" \
   -d '{
     "id": "site2",
@@ -34,15 +38,15 @@ curl -s -X POST http://localhost:8080/sites \
 
 # Test get all sites
 echo -e "\n4. Getting all sites:"
-curl -s http://localhost:8080/sites | jq .
+curl -s -H "Authorization: Bearer $ADMIN_TOKEN" http://localhost:8080/sites | jq .
 
 # Test get specific site
 echo -e "\n5. Getting site1:"
-curl -s http://localhost:8080/sites/site1 | jq .
+curl -s -H "Authorization: Bearer $ADMIN_TOKEN" http://localhost:8080/sites/site1 | jq .
 
 # Test update site
 echo -e "\n6. Updating site1:"
-curl -s -X PUT http://localhost:8080/sites/site1 \
+curl -s -H "Authorization: Bearer $ADMIN_TOKEN" -X PUT http://localhost:8080/sites/site1 \
   -H "Content-Type: application/json" \
   -d '{
     "name": "Updated Example Site 1",
@@ -51,39 +55,74 @@ curl -s -X PUT http://localhost:8080/sites/site1 \
 
 # Test get updated site
 echo -e "\n7. Getting updated site1:"
-curl -s http://localhost:8080/sites/site1 | jq .
+curl -s -H "Authorization: Bearer $ADMIN_TOKEN" http://localhost:8080/sites/site1 | jq .
 
 # Test command execution
 echo -e "\n8. Executing command 'pwd':"
-curl -s -X POST http://localhost:8080/commands \
+curl -s -H "Authorization: Bearer $ADMIN_TOKEN" -X POST http://localhost:8080/commands \
   -H "Content-Type: application/json" \
   -d '{"command": "pwd"}' | jq .
 
 # Test command execution with date
 echo -e "\n9. Executing command 'date':"
-curl -s -X POST http://localhost:8080/commands \
+curl -s -H "Authorization: Bearer $ADMIN_TOKEN" -X POST http://localhost:8080/commands \
   -H "Content-Type: application/json" \
   -d '{"command": "date"}' | jq .
 
 # Test command execution with ls
 echo -e "\n10. Executing command 'ls -la':"
-curl -s -X POST http://localhost:8080/commands \
+curl -s -H "Authorization: Bearer $ADMIN_TOKEN" -X POST http://localhost:8080/commands \
   -H "Content-Type: application/json" \
   -d '{"command": "ls -la"}' | jq .
 
 # Test forbidden command
 echo -e "\n11. Testing forbidden command (should fail):"
-curl -s -X POST http://localhost:8080/commands \
+curl -s -H "Authorization: Bearer $ADMIN_TOKEN" -X POST http://localhost:8080/commands \
   -H "Content-Type: application/json" \
   -d '{"command": "rm -rf /"}' | jq .
 
 # Test delete site
 echo -e "\n12. Deleting site2:"
-curl -s -X DELETE http://localhost:8080/sites/site2 | jq .
+curl -s -H "Authorization: Bearer $ADMIN_TOKEN" -X DELETE http://localhost:8080/sites/site2 | jq .
 
 # Test get all sites after deletion
 echo -e "\n13. Getting all sites after deletion:"
+curl -s -H "Authorization: Bearer $ADMIN_TOKEN" http://localhost:8080/sites | jq .
+
+# Test read-only token
+echo -e "\n14. Getting all sites with the read-only token:"
+curl -s -H "Authorization: Bearer $READ_TOKEN" http://localhost:8080/sites | jq .
+
+echo -e "\n15. Creating a site with the read-only token (should fail with 403):"
+curl -s -X POST -H "X-API-Key: $READ_TOKEN" http://localhost:8080/sites \
+  -H "Content-Type: application/json" \
+  -d '{"id": "site3", "name": "Example Site 3", "username": "user", "password": "secret789"}' | jq .
+
+echo -e "\n16. Getting all sites without credentials (should fail with 401):"
 curl -s http://localhost:8080/sites | jq .
 
+# Test revealing a password
+echo -e "\n17. Revealing the password of site1:"
+curl -s -H "Authorization: Bearer $ADMIN_TOKEN" http://localhost:8080/sites/site1/secret | jq .
+
+echo -e "\n18. Revealing the password of site1 with the read-only token (should fail with 403):"
+curl -s -H "Authorization: Bearer $READ_TOKEN" http://localhost:8080/sites/site1/secret | jq .
+
+# Test site details
+echo -e "\n19. Creating site3 with URLs, tags, a custom field and a TOTP seed:"
+curl -s -X POST -H "Authorization: Bearer $ADMIN_TOKEN" http://localhost:8080/sites \
+  -H "Content-Type: application/json" \
+  -d '{"id": "site3", "name": "Example Site 3", "username": "user", "password": "secret789", "urls": ["https://example.com/login"], "tags": ["work"], "custom_fields": {"pin": "1234"}, "totp_seed": "JBSWY3DPEHPK3PXP"}' | jq .
+
+echo -e "\n20. Searching sites by tag and URL:"
+curl -s -H "Authorization: Bearer $READ_TOKEN" "http://localhost:8080/sites?tag=work&url=example.com" | jq .
+
+echo -e "\n21. Getting the TOTP code of site3:"
+curl -s -H "Authorization: Bearer $ADMIN_TOKEN" http://localhost:8080/sites/site3/totp | jq .
+
+# Test the password audit
+echo -e "\n22. Auditing passwords:"
+curl -s -H "Authorization: Bearer $ADMIN_TOKEN" http://localhost:8080/audit/passwords | jq .
+
 echo -e "\n=================================="
 echo "Testing completed!"
@@ -29,25 +29,46 @@ echo -e "\n6. Testing sites update:"
 echo -e "\n7. Testing sites get (after update):"
 ./vault sites get "test-site-1"
 
-echo -e "\n8. Testing sites delete:"
+echo -e "\n8. Testing sites reveal (password should be shown):"
+./vault sites reveal "test-site-1"
+
+echo -e "\n9. Testing site details and search by tag and URL:"
+./vault sites create "test-site-3" "Test Site 3" "user3" "password3" \
+  --url "https://example.com/login" --tag work --tag Test --notes "Test notes" \
+  --field "pin=1234" --totp-seed "JBSWY3DPEHPK3PXP"
+./vault sites list --tag test
+./vault sites list --url example.com
+
+echo -e "\n10. Testing sites totp:"
+./vault sites totp "test-site-3"
+
+echo -e "\n11. Testing sites create with a generated passphrase:"
+./vault sites create "test-site-4" "Test Site 4" "user4" --generate --passphrase --words 6
+
+echo -e "\n12. Testing sites audit (the test passwords should be flagged as weak):"
+./vault sites audit
+
+echo -e "\n13. Testing sites delete:"
 ./vault sites delete "test-site-2"
 
-echo -e "\n9. Testing sites list (after deletion):"
+echo -e "\n14. Testing sites list (after deletion):"
 ./vault sites list
 
-echo -e "\n10. Testing error handling - get non-existent site:"
+echo -e "\n15. Testing error handling - get non-existent site:"
 ./vault sites get "nonexistent" || echo "Expected error: Site not found"
 
-echo -e "\n11. Testing error handling - create duplicate site:"
+echo -e "\n16. Testing error handling - create duplicate site:"
 ./vault sites create "test-site-1" "Duplicate" "user" "pass" || echo "Expected error: Site already exists"
 
-echo -e "\n12. Testing error handling - delete non-existent site:"
+echo -e "\n17. Testing error handling - delete non-existent site:"
 ./vault sites delete "nonexistent" || echo "Expected error: Site not found"
 
-echo -e "\n13. Final cleanup - delete remaining test sites:"
+echo -e "\n18. Final cleanup - delete remaining test sites:"
 ./vault sites delete "test-site-1"
+./vault sites delete "test-site-3"
+./vault sites delete "test-site-4"
 
-echo -e "\n14. Final sites list:"
+echo -e "\n19. Final sites list:"
 ./vault sites list
 
 echo -e "\n=============================="